@@ -0,0 +1,150 @@
+package app
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"os"
+)
+
+type ServerSettings struct {
+	Address         string `json:"address"`
+	DatabaseFile    string `json:"database_file"`
+	SoundsDirectory string `json:"sounds_directory"`
+	PublicURL       string `json:"public_url"`
+	PublicAddress   string `json:"public_address,omitempty"` // of the public page of sounds, apart from the dashboard
+	PageURL         string `json:"page_url,omitempty"`       // the public page of sounds is reached at
+}
+
+// Config holds what is needed before (and apart from) the settings, resolved with the precedence:
+// flags > environment variables (SPTB_*) > "server" section of the settings > defaults.
+type Config struct {
+	SettingsFile     string
+	SecretsFile      string
+	LocalesDirectory string
+	Address          string
+	DatabaseFile     string
+	SoundsDirectory  string
+	PublicURL        string
+	PublicAddress    string
+	PageURL          string
+	explicit         map[string]bool
+}
+
+type config_entry struct {
+	name         string
+	variable     string
+	usage        string
+	target       func(*Config) *string
+	fromSettings func(*ServerSettings) string // nil if not configurable within the settings
+}
+
+var config_entries = []config_entry{
+	{
+		name: "settings", variable: "SPTB_SETTINGS", usage: "path of the settings file",
+		target: func(r *Config) *string { return &r.SettingsFile },
+	},
+	{
+		name: "secrets", variable: "SPTB_SECRETS", usage: "path of the secrets file",
+		target: func(r *Config) *string { return &r.SecretsFile },
+	},
+	{
+		name: "locales", variable: "SPTB_LOCALES_DIR", usage: "directory of additional locale files",
+		target: func(r *Config) *string { return &r.LocalesDirectory },
+	},
+	{
+		name: "address", variable: "SPTB_ADDRESS", usage: "address the deployment & dashboard server listens on",
+		target:       func(r *Config) *string { return &r.Address },
+		fromSettings: func(s *ServerSettings) string { return s.Address },
+	},
+	{
+		name: "database", variable: "SPTB_DATABASE", usage: "path of the SQLite database",
+		target:       func(r *Config) *string { return &r.DatabaseFile },
+		fromSettings: func(s *ServerSettings) string { return s.DatabaseFile },
+	},
+	{
+		name: "sounds", variable: "SPTB_SOUNDS_DIR", usage: "directory uploaded sounds are stored in",
+		target:       func(r *Config) *string { return &r.SoundsDirectory },
+		fromSettings: func(s *ServerSettings) string { return s.SoundsDirectory },
+	},
+	{
+		name: "public-url", variable: "SPTB_PUBLIC_URL", usage: "url the server is reached at, used for OAuth redirects",
+		target:       func(r *Config) *string { return &r.PublicURL },
+		fromSettings: func(s *ServerSettings) string { return s.PublicURL },
+	},
+	{
+		name: "public-address", variable: "SPTB_PUBLIC_ADDRESS", usage: "address the public page of sounds listens on, apart from the dashboard",
+		target:       func(r *Config) *string { return &r.PublicAddress },
+		fromSettings: func(s *ServerSettings) string { return s.PublicAddress },
+	},
+	{
+		name: "page-url", variable: "SPTB_PAGE_URL", usage: "url the public page of sounds is reached at, linked within chat",
+		target:       func(r *Config) *string { return &r.PageURL },
+		fromSettings: func(s *ServerSettings) string { return s.PageURL },
+	},
+}
+
+func DefaultConfig() *Config {
+	return &Config{
+		SettingsFile:     "settings.json",
+		SecretsFile:      "secrets.json",
+		LocalesDirectory: "locales",
+		Address:          ":9999",
+		DatabaseFile:     "data.db",
+		SoundsDirectory:  "web/public/sounds",
+		PublicURL:        "http://localhost:9999",
+		PublicAddress:    ":9998",
+		PageURL:          "http://localhost:9998",
+		explicit:         make(map[string]bool),
+	}
+}
+
+// LoadConfig resolves the config from the defaults, environment variables and said arguments (flags),
+// returning the arguments left after the flags (e.g. a subcommand).
+func LoadConfig(args []string, output io.Writer) (*Config, []string, error) {
+	config := DefaultConfig()
+	for _, entry := range config_entries {
+		if value, ok := os.LookupEnv(entry.variable); ok && value != "" {
+			*entry.target(config) = value
+			config.explicit[entry.name] = true
+		}
+	}
+
+	flags := flag.NewFlagSet("sound-point-twitch-bot", flag.ContinueOnError)
+	flags.SetOutput(output)
+
+	values := make(map[string]*string)
+	for _, entry := range config_entries {
+		values[entry.name] = flags.String(
+			entry.name,
+			*entry.target(config),
+			fmt.Sprintf("%s (env %s)", entry.usage, entry.variable),
+		)
+	}
+
+	if err := flags.Parse(args); err != nil {
+		return nil, nil, err
+	}
+
+	flags.Visit(func(set *flag.Flag) {
+		config.explicit[set.Name] = true
+	})
+
+	for _, entry := range config_entries {
+		*entry.target(config) = *values[entry.name]
+	}
+	return config, flags.Args(), nil
+}
+
+// ApplySettings takes every value not given through flags or environment variables from said settings.
+func (r *Config) ApplySettings(settings *Settings) {
+	for _, entry := range config_entries {
+		if entry.fromSettings == nil || r.explicit[entry.name] {
+			continue
+		}
+
+		if value := entry.fromSettings(&settings.Server); value != "" {
+			*entry.target(r) = value
+		}
+	}
+}
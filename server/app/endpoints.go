@@ -0,0 +1,38 @@
+package app
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+func (r *Application) Handler(engine *gin.Engine) {
+	engine.GET("/settings/schema", func(ctx *gin.Context) {
+		ctx.JSON(http.StatusOK, Schema())
+	})
+
+	engine.GET("/stream", func(ctx *gin.Context) {
+		ctx.JSON(http.StatusOK, r.Stream.Status())
+	})
+
+	engine.POST("/settings/reload", func(ctx *gin.Context) {
+		err := r.ReloadSettings()
+		if err == nil {
+			ctx.String(http.StatusOK, "settings have been reloaded")
+			return
+		}
+
+		var problems SettingsErrors
+		if !errors.As(err, &problems) {
+			ctx.String(http.StatusInternalServerError, "failed reading settings")
+			return
+		}
+
+		messages := make([]string, len(problems))
+		for index, problem := range problems {
+			messages[index] = problem.Error()
+		}
+		ctx.JSON(http.StatusBadRequest, gin.H{"errors": messages})
+	})
+}
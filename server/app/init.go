@@ -1,15 +1,77 @@
 package app
 
 import (
+	"sync"
+
+	"github.com/imoliwer/sound-point-twitch-bot/server/locale"
 	"github.com/imoliwer/sound-point-twitch-bot/server/model"
+	"github.com/imoliwer/sound-point-twitch-bot/server/secret"
 	"github.com/uptrace/bun"
 )
 
+type SettingsListener = func(previous *Settings, current *Settings)
+
 type Application struct {
-	Database *bun.DB
-	Settings *Settings
+	Database  *bun.DB
+	Locales   *locale.Catalog
+	Secrets   *secret.Store
+	Stream    *StreamTracker
+	mutex     sync.RWMutex
+	settings  *Settings
+	listeners []SettingsListener
+	autosave  autosave
 }
 
 type ModelStructure struct {
 	User *model.User
 }
+
+func NewApplication(settings *Settings, locales *locale.Catalog, secrets *secret.Store) *Application {
+	return &Application{
+		Locales:  locales,
+		Secrets:  secrets,
+		Stream:   NewStreamTracker(),
+		settings: settings,
+	}
+}
+
+// Settings returns the settings currently in use.
+// The pointer must not be kept around, as it is replaced whenever the settings are reloaded.
+func (r *Application) Settings() *Settings {
+	r.mutex.RLock()
+	defer r.mutex.RUnlock()
+	return r.settings
+}
+
+// SwapSettings replaces the settings in use and notifies every listener.
+func (r *Application) SwapSettings(settings *Settings) *Settings {
+	r.mutex.Lock()
+	previous := r.settings
+	r.settings = settings
+	listeners := r.listeners
+	r.mutex.Unlock()
+
+	for _, listener := range listeners {
+		listener(previous, settings)
+	}
+	return previous
+}
+
+// UpdateSettings applies said change to a copy of the settings in use and swaps it in, without notifying listeners.
+// The maps and slices of the settings in use are never written, hence readable without a lock; said change may
+// write those of the copy it's given (yet not the slices within their values, which are shared still).
+func (r *Application) UpdateSettings(change func(settings *Settings)) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	settings := r.settings.clone()
+	change(settings)
+	r.settings = settings
+}
+
+// OnSettingsSwap registers a listener called after every swap of the settings.
+func (r *Application) OnSettingsSwap(listener SettingsListener) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	r.listeners = append(r.listeners, listener)
+}
@@ -0,0 +1,38 @@
+package app
+
+import (
+	"fmt"
+	"sync"
+	"testing"
+)
+
+func TestUpdateSettingsLeavesReadersAlone(t *testing.T) {
+	application := NewApplication(&Settings{Audio: AudioSettings{
+		References: map[string]AudioReference{"honk": {Price: 10}},
+	}}, nil, nil)
+
+	before := application.Settings()
+	var wait sync.WaitGroup
+	for index := 0; index < 50; index++ {
+		wait.Add(2)
+		go func(index int) {
+			defer wait.Done()
+			application.UpdateSettings(func(settings *Settings) {
+				settings.Audio.References[fmt.Sprintf("sound%d", index)] = AudioReference{Price: uint64(index)}
+			})
+		}(index)
+		go func() {
+			defer wait.Done()
+			for range application.Settings().Audio.References {
+			}
+		}()
+	}
+	wait.Wait()
+
+	if len(before.Audio.References) != 1 {
+		t.Errorf("settings read before were written, now of %d sounds", len(before.Audio.References))
+	}
+	if count := len(application.Settings().Audio.References); count != 51 {
+		t.Errorf("expected 51 sounds, got %d", count)
+	}
+}
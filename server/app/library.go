@@ -0,0 +1,151 @@
+package app
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// how a sound of a group is picked
+const (
+	SelectionRandom     = "random"      // at random, by the weights of the sounds
+	SelectionRoundRobin = "round_robin" // one after another, in order
+	SelectionShuffle    = "shuffle"     // at random, none repeating until every one has been picked
+)
+
+var Selections = []string{SelectionRandom, SelectionRoundRobin, SelectionShuffle}
+
+// SoundGroup is played by its name as any sound is, picking one of its sounds as it's played.
+// The sound picked is played as if played directly, at its own price, cooldowns and limits.
+type SoundGroup struct {
+	Sounds    []GroupMember `json:"sounds"`
+	Selection string        `json:"selection,omitempty"` // random when absent
+}
+
+type GroupMember struct {
+	Sound  string `json:"sound"`
+	Weight uint64 `json:"weight,omitempty"` // 1 when absent, only weighing when picked at random
+}
+
+func (r GroupMember) WeightOf() uint64 {
+	if r.Weight == 0 {
+		return 1
+	}
+	return r.Weight
+}
+
+func (r SoundGroup) SelectionOf() string {
+	if r.Selection == "" {
+		return SelectionRandom
+	}
+	return r.Selection
+}
+
+// SoundPack is a bundle of sounds (or groups) unlocked for good once bought with points,
+// the sounds within it being playable by those who unlocked it only.
+type SoundPack struct {
+	Sounds      []string `json:"sounds"`
+	Price       uint64   `json:"price"` // free to unlock if zero
+	Description string   `json:"description,omitempty"`
+}
+
+// PacksOf returns the packs containing said sound, directly or through a group, ordered by name.
+// A sound of no pack is playable by anyone.
+func (r AudioSettings) PacksOf(sound string) []string {
+	population := make([]string, 0)
+	for name, pack := range r.Packs {
+		for _, contained := range pack.Sounds {
+			if contained == sound || r.in_group(contained, sound) {
+				population = append(population, name)
+				break
+			}
+		}
+	}
+	sort.Strings(population)
+	return population
+}
+
+// Forget removes said sound (or group) from every group and pack, removing groups left empty as well.
+// It writes the maps of the settings, hence must be called on those given by Application.UpdateSettings.
+func (r AudioSettings) Forget(sound string) {
+	for name, group := range r.Groups {
+		members := make([]GroupMember, 0, len(group.Sounds))
+		for _, member := range group.Sounds {
+			if member.Sound != sound {
+				members = append(members, member)
+			}
+		}
+
+		if len(members) == len(group.Sounds) {
+			continue
+		}
+		if len(members) == 0 {
+			delete(r.Groups, name)
+			r.Forget(name)
+			continue
+		}
+		group.Sounds = members
+		r.Groups[name] = group
+	}
+
+	for name, pack := range r.Packs {
+		sounds := make([]string, 0, len(pack.Sounds))
+		for _, contained := range pack.Sounds {
+			if contained != sound {
+				sounds = append(sounds, contained)
+			}
+		}
+		pack.Sounds = sounds
+		r.Packs[name] = pack
+	}
+}
+
+//////////////////////
+// HELPER FUNCTIONS //
+//////////////////////
+
+func (r AudioSettings) in_group(group string, sound string) bool {
+	for _, member := range r.Groups[group].Sounds {
+		if member.Sound == sound {
+			return true
+		}
+	}
+	return false
+}
+
+func check_library(report func(string, string, ...any), audio AudioSettings) {
+	for name, group := range audio.Groups {
+		path := fmt.Sprintf("$.audio.groups.%s", name)
+		if name != strings.ToLower(name) {
+			report(path, "group names must be lowercase")
+		}
+		if _, ok := audio.References[name]; ok {
+			report(path, "shares its name with a sound")
+		}
+		if !contains(Selections, group.SelectionOf()) {
+			report(path+".selection", "unknown selection, known are %s", strings.Join(Selections, ", "))
+		}
+		if len(group.Sounds) == 0 {
+			report(path+".sounds", "must contain at least one sound")
+		}
+		for index, member := range group.Sounds {
+			if _, ok := audio.References[member.Sound]; !ok {
+				report(fmt.Sprintf("%s.sounds[%d].sound", path, index), "unknown sound %q", member.Sound)
+			}
+		}
+	}
+
+	for name, pack := range audio.Packs {
+		path := fmt.Sprintf("$.audio.packs.%s", name)
+		if name != strings.ToLower(name) {
+			report(path, "pack names must be lowercase")
+		}
+		for index, sound := range pack.Sounds {
+			_, isSound := audio.References[sound]
+			_, isGroup := audio.Groups[sound]
+			if !isSound && !isGroup {
+				report(fmt.Sprintf("%s.sounds[%d]", path, index), "unknown sound or group %q", sound)
+			}
+		}
+	}
+}
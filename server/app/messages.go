@@ -0,0 +1,68 @@
+package app
+
+import (
+	"regexp"
+	"strings"
+
+	"github.com/imoliwer/sound-point-twitch-bot/server/locale"
+)
+
+// format verbs of fmt, e.g. "%d" or "%-5s", yet not a percentage such as "50% off" (hence no space flag)
+var format_verb_regex = regexp.MustCompile(`%[-+#0-9.]*[a-zA-Z]`)
+
+// messages shipped by default before the locale catalog, formatted with fmt
+var legacy_messages = map[string]string{
+	"points_no_arg":             "You currently have %d points.",
+	"points_give_success":       "%s has been given %d points.",
+	"points_set_success":        "The points of %s has been set to %d.",
+	"specify_user":              "You must specify a user.",
+	"specify_amount":            "You must specify an amount.",
+	"must_specify_valid_amount": "You must specify a valid amount.",
+	"could_not_find_user":       "Could not find the user %s.",
+	"on_cooldown":               "This command is on cooldown, {remaining} seconds left.",
+}
+
+// placeholders of the legacy messages, in the order their format verbs were given values
+var legacy_arguments = map[string][]string{
+	"points_no_arg":       {"points"},
+	"points_give_success": {"target", "points"},
+	"points_set_success":  {"target", "points"},
+	"could_not_find_user": {"target"},
+}
+
+// MigrateMessages drops the overrides equal to the legacy defaults, as the catalog supersedes them,
+// and rewrites the format verbs of customised legacy messages into their placeholders.
+func (r *TwitchCommandSettings) MigrateMessages() {
+	for id, message := range r.Messages {
+		if legacy, ok := legacy_messages[id]; ok && len(message.Forms) == 1 && message.Form(locale.PluralOther) == legacy {
+			delete(r.Messages, id)
+			continue
+		}
+		if arguments, ok := legacy_arguments[id]; ok {
+			for category, text := range message.Forms {
+				message.Forms[category] = placeholders_of(text, arguments)
+			}
+		}
+	}
+}
+
+//////////////////////
+// HELPER FUNCTIONS //
+//////////////////////
+
+// said message with its format verbs replaced by said placeholders, in order
+func placeholders_of(message string, arguments []string) string {
+	index := 0
+	return format_verb_regex.ReplaceAllStringFunc(message, func(verb string) string {
+		if index >= len(arguments) {
+			return verb
+		}
+		placeholder := "{" + arguments[index] + "}"
+		index++
+		return placeholder
+	})
+}
+
+func has_format_verb(message string) bool {
+	return format_verb_regex.MatchString(strings.ReplaceAll(message, "%%", ""))
+}
@@ -0,0 +1,65 @@
+package app
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/imoliwer/sound-point-twitch-bot/server/locale"
+)
+
+func TestMigrateMessages(t *testing.T) {
+	command := TwitchCommandSettings{Messages: map[string]locale.Message{
+		"points_no_arg":       locale.NewMessage("You currently have %d points."),
+		"points_give_success": locale.NewMessage("%s got %d points!"),
+		"points_set_success":  {Forms: map[string]string{locale.PluralOne: "%s has %d point.", locale.PluralOther: "%s has %d points."}},
+		"specify_user":        locale.NewMessage("Who?"),
+	}}
+	command.MigrateMessages()
+
+	if _, ok := command.Messages["points_no_arg"]; ok {
+		t.Errorf("legacy default kept: %q", command.Messages["points_no_arg"].Forms)
+	}
+	if message := command.Messages["points_give_success"].Form(locale.PluralOther); message != "{target} got {points} points!" {
+		t.Errorf("legacy message rewritten as %q", message)
+	}
+	if message := command.Messages["points_set_success"].Form(locale.PluralOne); message != "{target} has {points} point." {
+		t.Errorf("plural form of a legacy message rewritten as %q", message)
+	}
+	if message := command.Messages["specify_user"].Form(locale.PluralOther); message != "Who?" {
+		t.Errorf("custom message changed to %q", message)
+	}
+}
+
+func TestMessageOverridesChecked(t *testing.T) {
+	for raw, problems := range map[string]int{
+		`"Who?"`:                                   0,
+		`{"one": "a point", "other": "points"}`:    0,
+		`{"one": "a point"}`:                       1,
+		`{"single": "a point", "other": "points"}`: 1,
+		`{"one": 1, "other": "points"}`:            1,
+		`42`:                                       1,
+	} {
+		var value any
+		if err := json.Unmarshal([]byte(raw), &value); err != nil {
+			t.Fatal(err)
+		}
+		if found := check_message(value, "$"); len(found) != problems {
+			t.Errorf("%s: %d problems (%v), expected %d", raw, len(found), found, problems)
+		}
+	}
+}
+
+func TestHasFormatVerb(t *testing.T) {
+	cases := map[string]bool{
+		"You have %d points.": true,
+		"%-5s joined":         true,
+		"50% off":             false,
+		"100%":                false,
+		"100%% sure":          false,
+	}
+	for message, expected := range cases {
+		if has_format_verb(message) != expected {
+			t.Errorf("has_format_verb(%q) != %t", message, expected)
+		}
+	}
+}
@@ -0,0 +1,97 @@
+package app
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/imoliwer/sound-point-twitch-bot/server/util"
+)
+
+const (
+	// SettingsPermission keeps the settings readable by the owner only, as they contain secrets.
+	SettingsPermission os.FileMode = 0600
+	// SettingsBackups is the amount of previous versions kept, as "settings.json.1" (newest) and onwards.
+	SettingsBackups = 5
+	// AutosaveDelay is how long mutations are gathered before being written at once.
+	AutosaveDelay = 2 * time.Second
+)
+
+var persist_mutex sync.Mutex
+
+type autosave struct {
+	mutex   sync.Mutex
+	pending *time.Timer
+}
+
+// RequestSave schedules a save of the current settings, gathering every request made within the autosave delay.
+func (r *Application) RequestSave() {
+	r.autosave.mutex.Lock()
+	defer r.autosave.mutex.Unlock()
+
+	if r.autosave.pending != nil {
+		r.autosave.pending.Stop()
+	}
+	r.autosave.pending = time.AfterFunc(AutosaveDelay, func() {
+		if err := r.Settings().Save(); err != nil {
+			util.Log("Settings", "Autosave failed: %s", err)
+		}
+	})
+}
+
+// Flush cancels any scheduled save and saves the current settings immediately.
+func (r *Application) Flush() error {
+	r.autosave.mutex.Lock()
+	if r.autosave.pending != nil {
+		r.autosave.pending.Stop()
+		r.autosave.pending = nil
+	}
+	r.autosave.mutex.Unlock()
+
+	return r.Settings().Save()
+}
+
+// atomically replace the file at path with said content, backing up what it replaces
+func persist(path string, content []byte) error {
+	persist_mutex.Lock()
+	defer persist_mutex.Unlock()
+
+	current, err := os.ReadFile(path)
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		return err
+	}
+
+	if current != nil && bytes.Equal(current, content) {
+		return nil
+	}
+
+	if current != nil {
+		if err := rotate_backups(path, current); err != nil {
+			util.Log("Settings", "Could not back up '%s': %s", path, err)
+		}
+	}
+
+	remember_content(content) // ensure the watcher ignores our own write
+	return util.WriteAtomically(path, content, SettingsPermission)
+}
+
+// shift every backup one step (dropping the oldest) and store said content as the newest
+func rotate_backups(path string, content []byte) error {
+	for index := SettingsBackups - 1; index >= 1; index-- {
+		from := fmt.Sprintf("%s.%d", path, index)
+		if _, err := os.Stat(from); err == nil {
+			os.Rename(from, fmt.Sprintf("%s.%d", path, index+1))
+		}
+	}
+	return os.WriteFile(fmt.Sprintf("%s.1", path), content, SettingsPermission)
+}
+
+// remove every backup of said path
+func discard_backups(path string) {
+	for index := 1; index <= SettingsBackups; index++ {
+		os.Remove(fmt.Sprintf("%s.%d", path, index))
+	}
+}
@@ -0,0 +1,187 @@
+package app
+
+import (
+	"fmt"
+	"math"
+	"strings"
+	"time"
+)
+
+// DefaultSurgeHalfLife is how long it takes for half of the surge of a sound to wear off, if not configured.
+const DefaultSurgeHalfLife = 10 * time.Minute
+
+// Pricing makes the price of a sound vary, each strategy being optional. Discounts apply one after another,
+// the surge (if any) applying before them.
+type Pricing struct {
+	Surge               *SurgePricing        `json:"surge,omitempty"`
+	SubscriberDiscounts *SubscriberDiscounts `json:"subscriber_discounts,omitempty"`
+	HappyHours          []HappyHour          `json:"happy_hours,omitempty"`
+	FirstPlayFree       bool                 `json:"first_play_free,omitempty"` // the first play of every stream
+}
+
+// IsFixed returns whether no strategy is configured, the price never varying.
+func (r Pricing) IsFixed() bool {
+	return r.Surge == nil && r.SubscriberDiscounts == nil && len(r.HappyHours) == 0 && !r.FirstPlayFree
+}
+
+// SurgePricing raises the price with every recent play, each play wearing off over time.
+type SurgePricing struct {
+	StepPercent uint64       `json:"step_percent"`          // added to the price by every recent play
+	MaxPercent  uint64       `json:"max_percent,omitempty"` // the most added altogether, unlimited if zero
+	HalfLife    Milliseconds `json:"half_life,omitempty"`   // until half of a play has worn off
+}
+
+func (r SurgePricing) HalfLifeOf() time.Duration {
+	if r.HalfLife == 0 {
+		return DefaultSurgeHalfLife
+	}
+	return r.HalfLife.Duration()
+}
+
+// Multiplier returns what the price is multiplied by, as said number of plays (worn off partially) are recent.
+func (r SurgePricing) Multiplier(recent float64) float64 {
+	added := float64(r.StepPercent) * recent
+	if r.MaxPercent > 0 {
+		added = math.Min(added, float64(r.MaxPercent))
+	}
+	return 1 + added/100
+}
+
+// SubscriberDiscounts take off said percent of the price for subscribers of each tier.
+type SubscriberDiscounts struct {
+	Tier1 uint64 `json:"tier1,omitempty"`
+	Tier2 uint64 `json:"tier2,omitempty"`
+	Tier3 uint64 `json:"tier3,omitempty"`
+}
+
+// PercentOf returns the discount of said tier (1 to 3), zero for those not subscribed.
+func (r SubscriberDiscounts) PercentOf(tier int) uint64 {
+	switch tier {
+	case 1:
+		return r.Tier1
+	case 2:
+		return r.Tier2
+	case 3:
+		return r.Tier3
+	}
+	return 0
+}
+
+// HappyHour takes off said percent of the price every day within said window, or on said days only.
+// A window ending before it starts spans midnight, belonging to the day it started on.
+type HappyHour struct {
+	Days            []string `json:"days,omitempty"` // e.g. "saturday", every day if empty
+	Start           string   `json:"start"`          // "15:04", inclusive
+	End             string   `json:"end"`            // "15:04", exclusive
+	Timezone        string   `json:"timezone,omitempty"`
+	DiscountPercent uint64   `json:"discount_percent"`
+}
+
+// Active returns whether said time is within the happy hour, in its timezone (that of the server if empty).
+func (r HappyHour) Active(now time.Time) bool {
+	start, startErr := minutes_of(r.Start)
+	end, endErr := minutes_of(r.End)
+	location, locationErr := r.location()
+	if startErr != nil || endErr != nil || locationErr != nil {
+		return false
+	}
+
+	now = now.In(location)
+	minute := now.Hour()*60 + now.Minute()
+	yesterday := now.AddDate(0, 0, -1).Weekday()
+
+	switch {
+	case start == end:
+		return r.on(now.Weekday())
+	case start < end:
+		return r.on(now.Weekday()) && minute >= start && minute < end
+	}
+	return (r.on(now.Weekday()) && minute >= start) || (r.on(yesterday) && minute < end)
+}
+
+// Problems returns what's wrong with the pricing, as the validation of the settings would report it.
+func (r Pricing) Problems(path string) []error {
+	problems := make([]error, 0)
+	check_pricing(func(path string, message string, args ...any) {
+		problems = append(problems, problem(path, message, args...))
+	}, path, r)
+	return problems
+}
+
+//////////////////////
+// HELPER FUNCTIONS //
+//////////////////////
+
+func check_pricing(report func(string, string, ...any), path string, pricing Pricing) {
+	if surge := pricing.Surge; surge != nil && surge.StepPercent == 0 {
+		report(path+".surge.step_percent", "must be at least 1")
+	}
+
+	if discounts := pricing.SubscriberDiscounts; discounts != nil {
+		for tier := 1; tier <= 3; tier++ {
+			if discounts.PercentOf(tier) > 100 {
+				report(fmt.Sprintf("%s.subscriber_discounts.tier%d", path, tier), "must be within 0 and 100")
+			}
+		}
+	}
+
+	for index, happyHour := range pricing.HappyHours {
+		happyPath := fmt.Sprintf("%s.happy_hours[%d]", path, index)
+		if _, err := minutes_of(happyHour.Start); err != nil {
+			report(happyPath+".start", "%s", err)
+		}
+		if _, err := minutes_of(happyHour.End); err != nil {
+			report(happyPath+".end", "%s", err)
+		}
+		if _, err := happyHour.location(); err != nil {
+			report(happyPath+".timezone", "unknown timezone %q", happyHour.Timezone)
+		}
+		if happyHour.DiscountPercent > 100 {
+			report(happyPath+".discount_percent", "must be within 0 and 100")
+		}
+		for dayIndex, day := range happyHour.Days {
+			if _, ok := weekdays[strings.ToLower(day)]; !ok {
+				report(fmt.Sprintf("%s.days[%d]", happyPath, dayIndex), "unknown day %q, expected e.g. \"monday\"", day)
+			}
+		}
+	}
+}
+
+var weekdays = map[string]time.Weekday{
+	"sunday":    time.Sunday,
+	"monday":    time.Monday,
+	"tuesday":   time.Tuesday,
+	"wednesday": time.Wednesday,
+	"thursday":  time.Thursday,
+	"friday":    time.Friday,
+	"saturday":  time.Saturday,
+}
+
+func (r HappyHour) on(day time.Weekday) bool {
+	if len(r.Days) == 0 {
+		return true
+	}
+
+	for _, name := range r.Days {
+		if weekday, ok := weekdays[strings.ToLower(name)]; ok && weekday == day {
+			return true
+		}
+	}
+	return false
+}
+
+func (r HappyHour) location() (*time.Location, error) {
+	if r.Timezone == "" {
+		return time.Local, nil
+	}
+	return time.LoadLocation(r.Timezone)
+}
+
+// the minutes since midnight of a time of day such as "15:04"
+func minutes_of(clock string) (int, error) {
+	parsed, err := time.Parse("15:04", clock)
+	if err != nil {
+		return 0, fmt.Errorf("must be a time of day such as \"18:30\", got %q", clock)
+	}
+	return parsed.Hour()*60 + parsed.Minute(), nil
+}
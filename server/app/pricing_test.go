@@ -0,0 +1,66 @@
+package app
+
+import (
+	"testing"
+	"time"
+)
+
+func TestSurgeMultiplier(t *testing.T) {
+	for _, test := range []struct {
+		surge    SurgePricing
+		recent   float64
+		expected float64
+	}{
+		{SurgePricing{StepPercent: 10}, 0, 1},
+		{SurgePricing{StepPercent: 10}, 2.5, 1.25},
+		{SurgePricing{StepPercent: 10}, 50, 6}, // unlimited
+		{SurgePricing{StepPercent: 10, MaxPercent: 30}, 2, 1.2},
+		{SurgePricing{StepPercent: 10, MaxPercent: 30}, 5, 1.3},
+	} {
+		if multiplier := test.surge.Multiplier(test.recent); multiplier != test.expected {
+			t.Errorf("%+v with %g recent multiplies by %g, expected %g", test.surge, test.recent, multiplier, test.expected)
+		}
+	}
+}
+
+func TestHappyHourActive(t *testing.T) {
+	saturday := time.Date(2022, time.October, 15, 0, 0, 0, 0, time.UTC) // a saturday
+
+	for _, test := range []struct {
+		happyHour HappyHour
+		at        time.Duration // since saturday began
+		active    bool
+	}{
+		{HappyHour{Start: "18:00", End: "20:00"}, 18 * time.Hour, true},
+		{HappyHour{Start: "18:00", End: "20:00"}, 20 * time.Hour, false},
+		{HappyHour{Start: "18:00", End: "20:00", Days: []string{"Saturday"}}, 19 * time.Hour, true},
+		{HappyHour{Start: "18:00", End: "20:00", Days: []string{"sunday"}}, 19 * time.Hour, false},
+		{HappyHour{Start: "22:00", End: "02:00", Days: []string{"saturday"}}, 23 * time.Hour, true},
+		{HappyHour{Start: "22:00", End: "02:00", Days: []string{"saturday"}}, 25 * time.Hour, true}, // sunday, yet begun on saturday
+		{HappyHour{Start: "22:00", End: "02:00", Days: []string{"saturday"}}, time.Hour, false},     // begun on friday
+		{HappyHour{Start: "00:00", End: "00:00", Days: []string{"saturday"}}, 12 * time.Hour, true},
+		{HappyHour{Start: "18:00", End: "20:00", Timezone: "Europe/Stockholm"}, 17 * time.Hour, true},
+		{HappyHour{Start: "8pm", End: "20:00"}, 19 * time.Hour, false},
+	} {
+		if active := test.happyHour.Active(saturday.Add(test.at)); active != test.active {
+			t.Errorf("%+v at %s is active: %t, expected %t", test.happyHour, saturday.Add(test.at), active, test.active)
+		}
+	}
+}
+
+func TestPricingProblems(t *testing.T) {
+	for _, test := range []struct {
+		pricing  Pricing
+		problems int
+	}{
+		{Pricing{}, 0},
+		{Pricing{Surge: &SurgePricing{}}, 1},
+		{Pricing{SubscriberDiscounts: &SubscriberDiscounts{Tier1: 100, Tier2: 101, Tier3: 200}}, 2},
+		{Pricing{HappyHours: []HappyHour{{Start: "18:00", End: "20:00", DiscountPercent: 100}}}, 0},
+		{Pricing{HappyHours: []HappyHour{{Start: "24:00", End: "20:00", DiscountPercent: 101, Days: []string{"caturday"}}}}, 3},
+	} {
+		if problems := test.pricing.Problems("$"); len(problems) != test.problems {
+			t.Errorf("%+v has %d problems (%v), expected %d", test.pricing, len(problems), problems, test.problems)
+		}
+	}
+}
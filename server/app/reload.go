@@ -0,0 +1,145 @@
+package app
+
+import (
+	"bytes"
+	"errors"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/fsnotify/fsnotify"
+	"github.com/imoliwer/sound-point-twitch-bot/server/util"
+)
+
+const reload_debounce = 500 * time.Millisecond
+
+type SettingsErrors []error
+
+var (
+	known_content_mutex sync.Mutex
+	known_content       []byte
+)
+
+func sorted_problems(problems []error) SettingsErrors {
+	sort.SliceStable(problems, func(i, j int) bool {
+		return problems[i].Error() < problems[j].Error()
+	})
+	return SettingsErrors(problems)
+}
+
+// ProblemsOf returns every problem contained within said error.
+func ProblemsOf(err error) []error {
+	var problems SettingsErrors
+	if errors.As(err, &problems) {
+		return problems
+	}
+	return []error{err}
+}
+
+// LogProblems logs every problem of said error on its own line.
+func LogProblems(err error) {
+	for _, problem := range ProblemsOf(err) {
+		log.Printf("  %s", problem)
+	}
+}
+
+func (r SettingsErrors) Error() string {
+	messages := make([]string, len(r))
+	for index, err := range r {
+		messages[index] = err.Error()
+	}
+	return strings.Join(messages, "; ")
+}
+
+// ReloadSettings reads and validates the settings file, swapping it in place of the current settings.
+// The current settings are kept if the file can't be decoded or is invalid.
+func (r *Application) ReloadSettings() error {
+	content, err := os.ReadFile(SettingsFile)
+	if err != nil {
+		return err
+	}
+
+	settings, err := ParseSettings(content, r.Locales)
+	if err != nil {
+		return err
+	}
+
+	remember_content(content)
+	// secrets are only read from the secret store at runtime
+	settings.TwitchAccessory = nil
+	settings.TwitchBot.AuthToken = ""
+	r.SwapSettings(settings)
+	return nil
+}
+
+// WatchSettings reloads the settings whenever the file changes on disk.
+// Changes originating from the bot itself (i.e. saving) are ignored.
+func (r *Application) WatchSettings() (*fsnotify.Watcher, error) {
+	watcher, err := fsnotify.NewWatcher()
+	if err != nil {
+		return nil, err
+	}
+
+	// editors tend to replace the file rather than write to it, hence the directory being watched
+	absolute, _ := filepath.Abs(SettingsFile)
+	if err := watcher.Add(filepath.Dir(absolute)); err != nil {
+		watcher.Close()
+		return nil, err
+	}
+
+	go func() {
+		var pending *time.Timer
+		for {
+			select {
+			case event, ok := <-watcher.Events:
+				if !ok {
+					return
+				}
+
+				if filepath.Base(event.Name) != filepath.Base(SettingsFile) || event.Op&(fsnotify.Write|fsnotify.Create|fsnotify.Rename) == 0 {
+					continue
+				}
+
+				if pending != nil {
+					pending.Stop()
+				}
+				pending = time.AfterFunc(reload_debounce, r.reload_if_changed)
+			case err, ok := <-watcher.Errors:
+				if !ok {
+					return
+				}
+				util.Log("Settings", "Watcher error: %s", err)
+			}
+		}
+	}()
+	return watcher, nil
+}
+
+func (r *Application) reload_if_changed() {
+	content, err := os.ReadFile(SettingsFile)
+	if err != nil || is_known_content(content) {
+		return
+	}
+
+	if err := r.ReloadSettings(); err != nil {
+		util.Log("Settings", "Kept current settings, the file is invalid: %s", err)
+		return
+	}
+	util.Log("Settings", "Reloaded from file.")
+}
+
+func remember_content(content []byte) {
+	known_content_mutex.Lock()
+	defer known_content_mutex.Unlock()
+	known_content = content
+}
+
+func is_known_content(content []byte) bool {
+	known_content_mutex.Lock()
+	defer known_content_mutex.Unlock()
+	return bytes.Equal(known_content, content)
+}
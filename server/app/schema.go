@@ -0,0 +1,265 @@
+package app
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"reflect"
+	"strconv"
+	"strings"
+
+	"github.com/imoliwer/sound-point-twitch-bot/server/locale"
+)
+
+// messages are either a plain string or an object of plural forms, as within the locale files
+var message_type = reflect.TypeOf(locale.Message{})
+
+type SettingsError struct {
+	Path    string
+	Message string
+}
+
+type json_field struct {
+	name  string
+	value reflect.Type
+}
+
+func (r SettingsError) Error() string {
+	return fmt.Sprintf("%s: %s", r.Path, r.Message)
+}
+
+func problem(path string, message string, args ...any) error {
+	return SettingsError{Path: path, Message: fmt.Sprintf(message, args...)}
+}
+
+// Schema returns the JSON Schema (draft-07) describing the settings file.
+func Schema() map[string]any {
+	schema := schema_of(reflect.TypeOf(Settings{}))
+	schema["$schema"] = "http://json-schema.org/draft-07/schema#"
+	schema["title"] = "Sound Point Twitch Bot settings"
+	return schema
+}
+
+// check the raw json against the structure of the settings, reporting unknown fields and mismatching types
+func check_structure(content []byte) []error {
+	decoder := json.NewDecoder(bytes.NewReader(content))
+	decoder.UseNumber()
+
+	var raw any
+	if err := decoder.Decode(&raw); err != nil {
+		return []error{syntax_problem(content, err)}
+	}
+	return check_value(raw, reflect.TypeOf(Settings{}), "$")
+}
+
+func check_value(raw any, t reflect.Type, path string) []error {
+	if raw == nil {
+		return nil // null leaves the value untouched, just as encoding/json does
+	}
+
+	if t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+	if t == message_type {
+		return check_message(raw, path)
+	}
+
+	switch t.Kind() {
+	case reflect.Struct:
+		object, ok := raw.(map[string]any)
+		if !ok {
+			return []error{problem(path, "expected an object")}
+		}
+
+		problems := make([]error, 0)
+		fields := fields_of(t)
+
+	keys:
+		for key, value := range object {
+			for _, field := range fields {
+				if strings.EqualFold(field.name, key) {
+					problems = append(problems, check_value(value, field.value, path+"."+key)...)
+					continue keys
+				}
+			}
+			problems = append(problems, problem(path+"."+key, "unknown field"))
+		}
+		return problems
+	case reflect.Map:
+		object, ok := raw.(map[string]any)
+		if !ok {
+			return []error{problem(path, "expected an object")}
+		}
+
+		problems := make([]error, 0)
+		for key, value := range object {
+			problems = append(problems, check_value(value, t.Elem(), path+"."+key)...)
+		}
+		return problems
+	case reflect.Slice:
+		array, ok := raw.([]any)
+		if !ok {
+			return []error{problem(path, "expected an array")}
+		}
+
+		problems := make([]error, 0)
+		for index, value := range array {
+			problems = append(problems, check_value(value, t.Elem(), fmt.Sprintf("%s[%d]", path, index))...)
+		}
+		return problems
+	case reflect.String:
+		if _, ok := raw.(string); !ok {
+			return []error{problem(path, "expected a string")}
+		}
+	case reflect.Bool:
+		if _, ok := raw.(bool); !ok {
+			return []error{problem(path, "expected a boolean")}
+		}
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		number, ok := raw.(json.Number)
+		if !ok {
+			return []error{problem(path, "expected a number")}
+		}
+
+		if _, err := strconv.ParseUint(number.String(), 10, t.Bits()); err != nil {
+			return []error{problem(path, "expected a whole, non-negative number within range")}
+		}
+	case reflect.Float32, reflect.Float64:
+		if _, ok := raw.(json.Number); !ok {
+			return []error{problem(path, "expected a number")}
+		}
+	}
+	return nil
+}
+
+func schema_of(t reflect.Type) map[string]any {
+	nullable := false
+	if t.Kind() == reflect.Pointer {
+		t = t.Elem()
+		nullable = true
+	}
+
+	if t == message_type {
+		return message_schema()
+	}
+
+	schema := map[string]any{}
+	switch t.Kind() {
+	case reflect.Struct:
+		properties := map[string]any{}
+		for _, field := range fields_of(t) {
+			properties[field.name] = schema_of(field.value)
+		}
+		schema["type"] = "object"
+		schema["properties"] = properties
+		schema["additionalProperties"] = false
+	case reflect.Map:
+		schema["type"] = "object"
+		schema["additionalProperties"] = schema_of(t.Elem())
+	case reflect.Slice:
+		schema["type"] = "array"
+		schema["items"] = schema_of(t.Elem())
+	case reflect.String:
+		schema["type"] = "string"
+	case reflect.Bool:
+		schema["type"] = "boolean"
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		schema["type"] = "integer"
+		schema["minimum"] = 0
+	case reflect.Float32, reflect.Float64:
+		schema["type"] = "number"
+	}
+
+	if nullable {
+		schema["type"] = []any{schema["type"], "null"}
+	}
+	return schema
+}
+
+func check_message(raw any, path string) []error {
+	if _, ok := raw.(string); ok {
+		return nil
+	}
+
+	forms, ok := raw.(map[string]any)
+	if !ok {
+		return []error{problem(path, "expected a string or an object of plural forms")}
+	}
+
+	problems := make([]error, 0)
+	if _, ok := forms[locale.PluralOther]; !ok {
+		problems = append(problems, problem(path, "missing the '%s' plural form", locale.PluralOther))
+	}
+	for category, text := range forms {
+		if !contains(locale.PluralCategories, category) {
+			problems = append(problems, problem(path+"."+category, "unknown plural form, known are %s", strings.Join(locale.PluralCategories, ", ")))
+		} else if _, ok := text.(string); !ok {
+			problems = append(problems, problem(path+"."+category, "expected a string"))
+		}
+	}
+	return problems
+}
+
+func message_schema() map[string]any {
+	forms := map[string]any{}
+	for _, category := range locale.PluralCategories {
+		forms[category] = map[string]any{"type": "string"}
+	}
+
+	return map[string]any{
+		"oneOf": []any{
+			map[string]any{"type": "string"},
+			map[string]any{
+				"type":                 "object",
+				"properties":           forms,
+				"required":             []any{locale.PluralOther},
+				"additionalProperties": false,
+			},
+		},
+	}
+}
+
+// fetch the json fields of a struct, flattening embedded structs the same way encoding/json does
+func fields_of(t reflect.Type) []json_field {
+	population := make([]json_field, 0, t.NumField())
+	for index := 0; index < t.NumField(); index++ {
+		field := t.Field(index)
+		tag := strings.Split(field.Tag.Get("json"), ",")[0]
+
+		if tag == "-" || !field.IsExported() {
+			continue
+		}
+
+		if field.Anonymous && tag == "" && field.Type.Kind() == reflect.Struct {
+			population = append(population, fields_of(field.Type)...)
+			continue
+		}
+
+		name := tag
+		if name == "" {
+			name = field.Name
+		}
+		population = append(population, json_field{name: name, value: field.Type})
+	}
+	return population
+}
+
+// convert a decoding error into a problem pointing at the line and column at fault
+func syntax_problem(content []byte, err error) error {
+	var syntaxErr *json.SyntaxError
+	if !errors.As(err, &syntaxErr) {
+		return problem("$", "%s", err)
+	}
+
+	line, column := 1, 1
+	for _, char := range content[:syntaxErr.Offset] {
+		if char == '\n' {
+			line++
+			column = 1
+			continue
+		}
+		column++
+	}
+	return problem("$", "invalid json at line %d, column %d: %s", line, column, syntaxErr)
+}
@@ -0,0 +1,97 @@
+package app
+
+import (
+	"log"
+	"os"
+
+	"github.com/imoliwer/sound-point-twitch-bot/server/secret"
+)
+
+// ReadSecrets opens the secret store, migrating any secrets still present within said settings.
+// A template is created if the store is absent and incomplete. Every problem is logged and nil returned
+// if the client credentials aren't usable, as unlike the tokens they can't be obtained by authorizing.
+func ReadSecrets(settings *Settings) *secret.Store {
+	store, err := secret.Open(SecretsFile, os.Getenv(secret.PassphraseVariable))
+	if err != nil {
+		log.Printf("Could not open secrets: %s", err)
+		return nil
+	}
+
+	migrated, err := MigrateSecrets(settings, store)
+	if err != nil {
+		log.Printf("Could not migrate secrets into '%s': %s", SecretsFile, err)
+		return nil
+	}
+
+	if migrated {
+		if err := settings.Save(); err != nil {
+			log.Printf("Could not remove migrated secrets from '%s': %s", SettingsFile, err)
+			return nil
+		}
+		discard_backups(SettingsFile) // every backup still contains the secrets in plain text
+		log.Printf("Moved secrets from '%s' into '%s'.", SettingsFile, SecretsFile)
+	}
+
+	problems := store.Validate()
+	if len(problems) == 0 {
+		return store
+	}
+
+	if !store.Existed() {
+		if err := store.Update(func(secrets *secret.Secrets) { *secrets = secret.Template() }); err != nil {
+			log.Printf("An error occurred during creation of '%s': %s", SecretsFile, err)
+			return nil
+		}
+		log.Printf("No '%s' file found. One was created for you, please modify it accordingly. Exiting...", SecretsFile)
+		return nil
+	}
+
+	if credentialProblems := store.ValidateCredentials(); len(credentialProblems) > 0 {
+		log.Printf("Secrets are invalid, please correct the following:")
+		for _, problem := range credentialProblems {
+			log.Printf("  %s", problem)
+		}
+		return nil
+	}
+
+	// tokens can be obtained by authorizing, hence only being reported
+	log.Printf("Some tokens are absent or invalid, they will be obtained by authorizing:")
+	for _, problem := range problems {
+		log.Printf("  %s", problem)
+	}
+	return store
+}
+
+// MigrateSecrets moves the secrets still present within the settings into said store,
+// without replacing those already stored. Returns whether the settings were modified.
+func MigrateSecrets(settings *Settings, store *secret.Store) (bool, error) {
+	accessory := settings.TwitchAccessory
+	botToken := settings.TwitchBot.AuthToken
+
+	if accessory == nil && botToken == "" {
+		return false, nil
+	}
+
+	err := store.Update(func(secrets *secret.Secrets) {
+		fill := func(target *string, value string) {
+			if *target == "" {
+				*target = value
+			}
+		}
+
+		fill(&secrets.BotAuthToken, botToken)
+		if accessory != nil {
+			fill(&secrets.TwitchClientID, accessory.ClientID)
+			fill(&secrets.TwitchClientSecret, accessory.ClientSecret)
+			fill(&secrets.TwitchAccessToken, accessory.AuthToken)
+			fill(&secrets.TwitchRefreshToken, accessory.RefreshToken)
+		}
+	})
+	if err != nil {
+		return false, err
+	}
+
+	settings.TwitchAccessory = nil
+	settings.TwitchBot.AuthToken = ""
+	return true, nil
+}
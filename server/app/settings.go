@@ -3,16 +3,46 @@ package app
 import (
 	"encoding/json"
 	"errors"
+	"fmt"
 	"io/ioutil"
 	"log"
+	"math"
 	"os"
+	"strings"
 	"time"
 
-	"github.com/imoliwer/sound-point-twitch-bot/server/request"
+	"github.com/imoliwer/sound-point-twitch-bot/server/locale"
 )
 
+type TwitchCommandCooldown struct {
+	Global          uint64 `json:"global_seconds"`
+	User            uint64 `json:"user_seconds"`
+	Channel         uint64 `json:"channel_seconds"`
+	ModeratorBypass bool   `json:"moderator_bypass"`
+	Reply           bool   `json:"reply"`
+}
+
+// Requirements a user must meet, zero requiring nothing.
+type Requirements struct {
+	WatchMinutes uint64 `json:"watch_minutes,omitempty"`
+	Messages     uint64 `json:"messages,omitempty"`
+	FollowDays   uint64 `json:"follow_days,omitempty"`
+}
+
 type TwitchCommandOption struct {
-	Enabled bool `json:"enabled"`
+	Enabled      bool                  `json:"enabled"`
+	Aliases      []string              `json:"aliases"`
+	Cooldown     TwitchCommandCooldown `json:"cooldown"`
+	Requirements *Requirements         `json:"requirements,omitempty"`
+}
+
+func (r *TwitchCommandOption) HasAlias(name string) bool {
+	for _, alias := range r.Aliases {
+		if strings.EqualFold(alias, name) {
+			return true
+		}
+	}
+	return false
 }
 
 type TwitchCommandPrimaryOption struct {
@@ -20,25 +50,24 @@ type TwitchCommandPrimaryOption struct {
 	Arguments map[string]TwitchCommandOption `json:"arguments"`
 }
 
-type TwitchCommandMessages struct {
-	PointsNoArg            string `json:"points_no_arg"`
-	PointsGiveSuccess      string `json:"points_give_success"`
-	PointsSetSuccess       string `json:"points_set_success"`
-	SpecifyUser            string `json:"specify_user"`
-	SpecifyAmount          string `json:"specify_amount"`
-	MustSpecifyValidAmount string `json:"must_specify_valid_amount"`
-	CouldNotFindUser       string `json:"could_not_find_user"`
+type TwitchCommandSettings struct {
+	Prefix         string                                `json:"prefix"`
+	Locale         string                                `json:"locale"`
+	ChannelLocales map[string]string                     `json:"channel_locales"`
+	Options        map[string]TwitchCommandPrimaryOption `json:"options"`
+	Messages       map[string]locale.Message             `json:"messages"` // overrides of the locale catalog, by message id
 }
 
-type TwitchCommandSettings struct {
-	Prefix   string                                `json:"prefix"`
-	Options  map[string]TwitchCommandPrimaryOption `json:"options"`
-	Messages TwitchCommandMessages                 `json:"messages"`
+func (r *TwitchCommandSettings) LocaleOf(channel string) string {
+	if locale, ok := r.ChannelLocales[strings.ToLower(channel)]; ok && locale != "" {
+		return locale
+	}
+	return r.Locale
 }
 
 type TwitchBotSettings struct {
 	Name      string                `json:"name"`
-	AuthToken string                `json:"auth_token"`
+	AuthToken string                `json:"auth_token,omitempty"` // legacy, migrated into the secret store
 	Channel   string                `json:"channel_to_join"`
 	Command   TwitchCommandSettings `json:"command"`
 }
@@ -50,86 +79,489 @@ type TempTwitchAccessSettings struct {
 	RefreshToken string `json:"refresh_token"`
 }
 
+// limits twitch imposes on custom rewards
+const (
+	MaxRewardTitle  = 45
+	MaxRewardPrompt = 200
+)
+
+// AudioReward mirrors a sound as a Channel Points custom reward, deploying it once redeemed.
+type AudioReward struct {
+	Enabled  bool   `json:"enabled"`
+	Title    string `json:"title,omitempty"` // defaults to "Sound: <name>"
+	Cost     uint64 `json:"cost"`
+	Prompt   string `json:"prompt,omitempty"`
+	RewardID string `json:"reward_id,omitempty"` // assigned once created on Twitch
+}
+
+// TitleOf returns the title of the reward mirroring the sound of said name.
+func (r AudioReward) TitleOf(name string) string {
+	if r.Title != "" {
+		return r.Title
+	}
+	return fmt.Sprintf("Sound: %s", name)
+}
+
+// permission levels, each permitting those below it as well
+const (
+	PermissionEveryone    = "everyone"
+	PermissionSubscriber  = "subscriber"
+	PermissionVIP         = "vip"
+	PermissionModerator   = "moderator"
+	PermissionBroadcaster = "broadcaster"
+)
+
+// PermissionLevels in ascending order.
+var PermissionLevels = []string{PermissionEveryone, PermissionSubscriber, PermissionVIP, PermissionModerator, PermissionBroadcaster}
+
+// PermissionRank returns the rank of said level within the permission levels, -1 if unknown.
+// An empty level is that of everyone.
+func PermissionRank(level string) int {
+	if level == "" {
+		return 0
+	}
+	for rank, known := range PermissionLevels {
+		if known == level {
+			return rank
+		}
+	}
+	return -1
+}
+
+// Milliseconds is a duration as the dashboard writes it, the unit of every cooldown of a sound.
+type Milliseconds uint64
+
+func (r Milliseconds) Duration() time.Duration {
+	return time.Duration(r) * time.Millisecond
+}
+
+// MaxVolume is the most a sound may be amplified, in percent.
+const MaxVolume = 200
+
+// processing statuses of a sound
+const (
+	ProcessingPending = "pending"
+	ProcessingRunning = "processing"
+	ProcessingDone    = "done"
+	ProcessingFailed  = "failed"
+)
+
+// MinTargetLoudness is the quietest sounds may be normalized to, in LUFS (that of the absolute gate).
+const MinTargetLoudness = -70
+
 type AudioReference struct {
-	Price    uint64 `json:"price"`
-	FileName string `json:"file_name"`
-	Cooldown uint64 `json:"cooldown"`
-	LastUsed uint64 `json:"last_used"`
+	Price             uint64        `json:"price"`
+	FileName          string        `json:"file_name"`
+	Cooldown          Milliseconds  `json:"cooldown"`                // before anyone may play it again
+	UserCooldown      Milliseconds  `json:"user_cooldown,omitempty"` // before the same user may play it again
+	LastUsed          uint64        `json:"last_used"`               // in unix milliseconds, as of older versions (now kept in the database)
+	Reward            *AudioReward  `json:"reward,omitempty"`
+	Requirements      *Requirements `json:"requirements,omitempty"`
+	Volume            *uint64       `json:"volume,omitempty"`           // in percent, 100 when absent
+	Duration          float64       `json:"duration_seconds,omitempty"` // detected on upload
+	Tags              []string      `json:"tags,omitempty"`
+	Description       string        `json:"description,omitempty"`
+	Enabled           *bool         `json:"enabled,omitempty"`    // true when absent
+	Permission        string        `json:"permission,omitempty"` // the minimum level, everyone when absent
+	MaxPlaysPerStream uint64        `json:"max_plays_per_stream,omitempty"`
+	Pricing           *Pricing      `json:"pricing,omitempty"`            // the price is fixed when absent
+	Format            string        `json:"format,omitempty"`             // detected on upload, as are those below
+	SampleRate        uint32        `json:"sample_rate,omitempty"`        // in hz
+	Bitrate           uint32        `json:"bitrate,omitempty"`            // in bits per second
+	Loudness          *float64      `json:"loudness_lufs,omitempty"`      // absent if it couldn't be measured
+	OriginalFileName  string        `json:"original_file_name,omitempty"` // as uploaded, once processed
+	Processing        string        `json:"processing,omitempty"`         // the status of processing, if ever processed
+	ProcessingError   string        `json:"processing_error,omitempty"`
+}
+
+// VolumePercent returns the volume to play the sound at, in percent.
+func (r AudioReference) VolumePercent() uint64 {
+	if r.Volume == nil {
+		return 100
+	}
+	return *r.Volume
+}
+
+// PlaybackVolume returns the volume to play the sound at, in percent, amplified or attenuated to reach said
+// target loudness if both it and that of the sound are known.
+func (r AudioReference) PlaybackVolume(target *float64) uint64 {
+	return PlaybackVolumeOf(r.VolumePercent(), r.Loudness, target)
+}
+
+// PlaybackVolumeOf returns said volume (in percent) of audio of said loudness, amplified or attenuated to reach
+// said target loudness if both are known.
+func PlaybackVolumeOf(percent uint64, loudness *float64, target *float64) uint64 {
+	volume := float64(percent)
+	if target != nil && loudness != nil {
+		volume *= math.Pow(10, (*target-*loudness)/20)
+	}
+	return uint64(math.Min(math.Round(volume), MaxVolume))
+}
+
+func (r AudioReference) IsEnabled() bool {
+	return r.Enabled == nil || *r.Enabled
+}
+
+// PointsConversion is a Channel Points custom reward converting into bot points once redeemed.
+type PointsConversion struct {
+	Title    string `json:"title"`
+	Cost     uint64 `json:"cost"`
+	Points   uint64 `json:"points"`
+	Prompt   string `json:"prompt,omitempty"`
+	RewardID string `json:"reward_id,omitempty"` // assigned once created on Twitch
+}
+
+type ChannelPointsSettings struct {
+	Enabled     bool               `json:"enabled"`
+	Conversions []PointsConversion `json:"conversions"`
+}
+
+// EventPointsSettings awards bot points for what happens on the channel, zero awarding none.
+type EventPointsSettings struct {
+	Follow          uint64 `json:"follow"`
+	Subscribe       uint64 `json:"subscribe"`
+	CheerPer100Bits uint64 `json:"cheer_per_100_bits"`
+}
+
+// Default limits of uploaded sounds.
+const (
+	DefaultMaxUploadKB       = 5 * 1024
+	DefaultMaxUploadDuration = 30 * time.Second
+)
+
+// UploadSettings limit the sounds uploaded, zero applying the default.
+type UploadSettings struct {
+	MaxSizeKB          uint64 `json:"max_size_kb,omitempty"`
+	MaxDurationSeconds uint64 `json:"max_duration_seconds,omitempty"`
+}
+
+// MaxSize returns the most bytes an uploaded sound may take.
+func (r UploadSettings) MaxSize() int64 {
+	if r.MaxSizeKB == 0 {
+		return DefaultMaxUploadKB * 1024
+	}
+	return int64(r.MaxSizeKB) * 1024
+}
+
+// MaxDuration returns the longest an uploaded sound may play for.
+func (r UploadSettings) MaxDuration() time.Duration {
+	if r.MaxDurationSeconds == 0 {
+		return DefaultMaxUploadDuration
+	}
+	return time.Duration(r.MaxDurationSeconds) * time.Second
+}
+
+// DefaultSilenceThreshold is what's considered silent when trimming, in dBFS.
+const DefaultSilenceThreshold = -50.
+
+// ProcessingSettings configure converting uploaded sounds into a single format played by the overlay,
+// trimming their silence and applying the gain to reach the target loudness, if any.
+type ProcessingSettings struct {
+	Enabled          bool     `json:"enabled"`
+	FFmpegPath       string   `json:"ffmpeg_path,omitempty"` // looked up within the PATH when absent
+	TrimSilence      bool     `json:"trim_silence"`
+	SilenceThreshold *float64 `json:"silence_threshold_db,omitempty"`
+}
+
+// SilenceThresholdOf returns the loudest a sample may be to be considered silent, in dBFS.
+func (r ProcessingSettings) SilenceThresholdOf() float64 {
+	if r.SilenceThreshold == nil {
+		return DefaultSilenceThreshold
+	}
+	return *r.SilenceThreshold
+}
+
+// engines reading messages aloud, the fake one playing a tone rather than speech (e.g. to try out the overlay)
+const (
+	TTSEspeak = "espeak"
+	TTSPiper  = "piper"
+	TTSFake   = "fake"
+)
+
+var TTSEngines = []string{TTSEspeak, TTSPiper, TTSFake}
+
+// DefaultTTSMaxCharacters is the longest a message read aloud may be, if not configured.
+const DefaultTTSMaxCharacters = 200
+
+// TTSSettings configure reading messages of chatters aloud through the overlay, paid for with points by the character.
+type TTSSettings struct {
+	Enabled           bool     `json:"enabled"`
+	Engine            string   `json:"engine,omitempty"`      // espeak when absent
+	BinaryPath        string   `json:"binary_path,omitempty"` // looked up within the PATH when absent
+	Voice             string   `json:"voice,omitempty"`       // of espeak, e.g. "en-us"
+	Model             string   `json:"model,omitempty"`       // of piper, the path of its .onnx voice
+	BasePrice         uint64   `json:"base_price,omitempty"`
+	PricePerCharacter uint64   `json:"price_per_character"`
+	MaxCharacters     uint64   `json:"max_characters,omitempty"`
+	Volume            *uint64  `json:"volume,omitempty"`     // in percent, 100 when absent
+	Permission        string   `json:"permission,omitempty"` // the minimum level, everyone when absent
+	AllowLinks        bool     `json:"allow_links,omitempty"`
+	BlockedWords      []string `json:"blocked_words,omitempty"` // words or phrases, in addition to those blocked by default
+}
+
+func (r TTSSettings) EngineOf() string {
+	if r.Engine == "" {
+		return TTSEspeak
+	}
+	return r.Engine
+}
+
+func (r TTSSettings) MaxCharactersOf() uint64 {
+	if r.MaxCharacters == 0 {
+		return DefaultTTSMaxCharacters
+	}
+	return r.MaxCharacters
+}
+
+// PriceOf returns the price of reading a message of said number of characters aloud.
+func (r TTSSettings) PriceOf(characters uint64) uint64 {
+	return r.BasePrice + r.PricePerCharacter*characters
+}
+
+func (r TTSSettings) VolumePercent() uint64 {
+	if r.Volume == nil {
+		return 100
+	}
+	return *r.Volume
 }
 
 type AudioSettings struct {
-	References map[string]AudioReference `json:"references"`
+	References     map[string]AudioReference `json:"references"`
+	Groups         map[string]SoundGroup     `json:"groups,omitempty"` // played by their names, as sounds are
+	Packs          map[string]SoundPack      `json:"packs,omitempty"`
+	Upload         UploadSettings            `json:"upload"`
+	Processing     ProcessingSettings        `json:"processing"`
+	TTS            TTSSettings               `json:"tts"`
+	UserCooldown   Milliseconds              `json:"user_cooldown,omitempty"`        // before a user may play any sound again
+	TargetLoudness *float64                  `json:"target_loudness_lufs,omitempty"` // sounds measured are played at it, if set
 }
 
 type Settings struct {
+	Server          ServerSettings            `json:"server"`
 	TwitchBot       TwitchBotSettings         `json:"twitch_chat_bot"`
-	TwitchAccessory *TempTwitchAccessSettings `json:"twitch_accessories"` // temporary
-	Audio           AudioSettings
+	TwitchAccessory *TempTwitchAccessSettings `json:"twitch_accessories,omitempty"` // legacy, migrated into the secret store
+	Audio           AudioSettings             `json:"audio"`
+	ChannelPoints   ChannelPointsSettings     `json:"channel_points"`
+	EventPoints     EventPointsSettings       `json:"event_points"`
+}
+
+// paths of the settings and secrets, replaced by those of the config at startup
+var (
+	SettingsFile = "settings.json"
+	SecretsFile  = "secrets.json"
+)
+
+// Save atomically writes the settings to disk, keeping a backup of what it replaces.
+func (r *Settings) Save() error {
+	bytes, err := json.MarshalIndent(r, "", "  ")
+	if err != nil {
+		return err
+	}
+	return persist(SettingsFile, bytes)
+}
+
+// a copy of the settings, sharing none of the maps and slices written at runtime
+func (r *Settings) clone() *Settings {
+	settings := *r
+	settings.Audio.References = clone_map(r.Audio.References)
+	settings.Audio.Groups = clone_map(r.Audio.Groups)
+	settings.Audio.Packs = clone_map(r.Audio.Packs)
+	settings.ChannelPoints.Conversions = append([]PointsConversion(nil), r.ChannelPoints.Conversions...)
+	return &settings
 }
 
-func (r *Settings) Save() {
-	profile := request.Profiles.Twitch
-	r.TwitchAccessory = &TempTwitchAccessSettings{
-		ClientID:     profile.ClientID,
-		ClientSecret: profile.ClientSecret,
-		AuthToken:    profile.OAuthToken,
-		RefreshToken: profile.RefreshToken,
+func clone_map[V any](values map[string]V) map[string]V {
+	clone := make(map[string]V, len(values))
+	for key, value := range values {
+		clone[key] = value
+	}
+	return clone
+}
+
+// DecodeSettings strictly decodes said content, reporting unknown fields and mismatching types by their path.
+func DecodeSettings(content []byte) (*Settings, error) {
+	if problems := check_structure(content); len(problems) > 0 {
+		return nil, sorted_problems(problems)
 	}
 
-	bytes, _ := json.MarshalIndent(r, "", "  ")
-	os.WriteFile("settings.json", bytes, 0)
+	var settings Settings
+	if err := json.Unmarshal(content, &settings); err != nil {
+		return nil, SettingsErrors{problem("$", "%s", err)}
+	}
+	settings.TwitchBot.Command.MigrateMessages()
+	return &settings, nil
 }
 
-func ReadSettings() *Settings {
+// ParseSettings decodes and validates said content, returning every problem found at once.
+func ParseSettings(content []byte, locales *locale.Catalog) (*Settings, error) {
+	settings, err := DecodeSettings(content)
+	if err != nil {
+		return nil, err
+	}
+
+	if problems := settings.Validate(locales); len(problems) > 0 {
+		return nil, sorted_problems(problems)
+	}
+	return settings, nil
+}
+
+// ReadSettings reads, decodes and validates the settings file, creating a template if absent.
+// Every problem is logged and nil returned if the settings aren't usable.
+func ReadSettings(locales *locale.Catalog) *Settings {
 	// bytes read from the settings file
 	var settingsContent []byte
 
 	// create file if it doesn't exist
-	if _, settingsError := os.Stat("settings.json"); errors.Is(settingsError, os.ErrNotExist) {
-		created, createdError := os.Create("settings.json")
+	if _, settingsError := os.Stat(SettingsFile); errors.Is(settingsError, os.ErrNotExist) {
+		created, createdError := os.OpenFile(SettingsFile, os.O_CREATE|os.O_WRONLY|os.O_EXCL, SettingsPermission)
 		if createdError != nil {
 			log.Panic("An error occurred during creation of 'settings.json' file. Try creating it manually.")
 			return nil
 		}
 		settingsContent = []byte(`{
+	"server": {
+		"address": ":9999",
+		"database_file": "data.db",
+		"sounds_directory": "web/public/sounds",
+		"public_url": "http://localhost:9999",
+		"public_address": ":9998",
+		"page_url": "http://localhost:9998"
+	},
   "twitch_chat_bot": {
 		"name": "<bot_username>",
-		"auth_token": "<bot_auth_token>",
 		"channel_to_join": "<your_channel_name>",
 		"command": {
 			"prefix": "!",
+			"locale": "en",
+			"channel_locales": {},
 			"options": {
 				"points": {
 					"enabled": true,
+					"aliases": ["pts"],
+					"cooldown": {
+						"global_seconds": 0,
+						"user_seconds": 5,
+						"channel_seconds": 0,
+						"moderator_bypass": true,
+						"reply": false
+					},
 					"arguments": {
 						"set": {
-							"enabled": true
+							"enabled": true,
+							"aliases": []
 						},
 						"give": {
-							"enabled": true
+							"enabled": true,
+							"aliases": []
+						}
+					}
+				},
+				"watchtime": {
+					"enabled": true,
+					"aliases": ["wt"],
+					"cooldown": {
+						"global_seconds": 0,
+						"user_seconds": 10,
+						"channel_seconds": 0,
+						"moderator_bypass": true,
+						"reply": false
+					},
+					"arguments": {}
+				},
+				"followage": {
+					"enabled": true,
+					"aliases": [],
+					"cooldown": {
+						"global_seconds": 0,
+						"user_seconds": 10,
+						"channel_seconds": 0,
+						"moderator_bypass": true,
+						"reply": false
+					},
+					"arguments": {}
+				},
+				"sounds": {
+					"enabled": true,
+					"aliases": ["sfx"],
+					"cooldown": {
+						"global_seconds": 5,
+						"user_seconds": 15,
+						"channel_seconds": 0,
+						"moderator_bypass": true,
+						"reply": false
+					},
+					"arguments": {}
+				},
+				"sound": {
+					"enabled": true,
+					"aliases": ["play"],
+					"cooldown": {
+						"global_seconds": 0,
+						"user_seconds": 3,
+						"channel_seconds": 0,
+						"moderator_bypass": false,
+						"reply": false
+					},
+					"arguments": {}
+				},
+				"tts": {
+					"enabled": true,
+					"aliases": [],
+					"cooldown": {
+						"global_seconds": 10,
+						"user_seconds": 30,
+						"channel_seconds": 0,
+						"moderator_bypass": false,
+						"reply": true
+					},
+					"arguments": {}
+				},
+				"packs": {
+					"enabled": true,
+					"aliases": [],
+					"cooldown": {
+						"global_seconds": 0,
+						"user_seconds": 5,
+						"channel_seconds": 0,
+						"moderator_bypass": true,
+						"reply": false
+					},
+					"arguments": {
+						"unlock": {
+							"enabled": true,
+							"aliases": ["buy"]
 						}
 					}
 				}
 			},
-			"messages": {
-				"points_no_arg": "You currently have %d points.",
-				"points_give_success": "%s has been given %d points.",
-				"points_set_success": "The points of %s has been set to %d.",
-				"specify_user": "You must specify a user.",
-				"specify_amount": "You must specify an amount.",
-				"must_specify_valid_amount": "You must specify a valid amount.",
-				"could_not_find_user": "Could not find the user %s."
-			}
+			"messages": {}
 		}
 	},
-	"twitch_accessories": {
-		"client_id": "<your_client_id>",
-		"client_secret": "<your_client_secret>",
-		"auth_token": "<your_auth_token>",
-		"refresh_token": "<your_refresh_token>",
-	},
 	"audio": {
-		"references": {}
+		"references": {},
+		"upload": {
+			"max_size_kb": 5120,
+			"max_duration_seconds": 30
+		},
+		"processing": {
+			"enabled": false,
+			"trim_silence": true
+		},
+		"tts": {
+			"enabled": false,
+			"engine": "espeak",
+			"price_per_character": 1,
+			"max_characters": 200
+		}
+	},
+	"channel_points": {
+		"enabled": false,
+		"conversions": []
+	},
+	"event_points": {
+		"follow": 0,
+		"subscribe": 0,
+		"cheer_per_100_bits": 0
 	}
 }`)
 		created.Write(settingsContent)
@@ -140,13 +572,22 @@ func ReadSettings() *Settings {
 	}
 
 	// fetch settings from file
-	contentRead, _ := ioutil.ReadFile("settings.json")
+	contentRead, readErr := ioutil.ReadFile(SettingsFile)
+	if readErr != nil {
+		log.Printf("Could not read '%s': %s", SettingsFile, readErr)
+		return nil
+	}
 	settingsContent = contentRead
 
-	// unmarshal json into our var with corresponding struct
-	var settings Settings
-	json.Unmarshal(settingsContent, &settings)
+	// decode and validate the content, reporting every problem at once
+	settings, err := ParseSettings(settingsContent, locales)
+	if err != nil {
+		log.Printf("'%s' is invalid, please correct the following:", SettingsFile)
+		LogProblems(err)
+		return nil
+	}
 
 	// return the settings accordingly
-	return &settings
+	remember_content(settingsContent)
+	return settings
 }
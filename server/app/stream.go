@@ -0,0 +1,209 @@
+package app
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/imoliwer/sound-point-twitch-bot/server/request"
+	"github.com/imoliwer/sound-point-twitch-bot/server/scheduler"
+	"github.com/imoliwer/sound-point-twitch-bot/server/util"
+)
+
+const (
+	// StreamPollInterval is how often the stream is polled, catching what the events missed (if any).
+	StreamPollInterval = 2 * time.Minute
+	// how far the streams endpoint may lag behind the events, which are trusted over it until then
+	stream_lag     = 3 * time.Minute
+	stream_timeout = 30 * time.Second
+)
+
+type StreamListener = func(status StreamStatus)
+
+type StreamStatus struct {
+	Live      bool      `json:"live"`
+	StartedAt time.Time `json:"started_at"`
+	Game      string    `json:"game"`
+	Title     string    `json:"title"`
+	Viewers   uint64    `json:"viewers"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+// Uptime returns for how long the stream has been live, zero when offline.
+func (r StreamStatus) Uptime() time.Duration {
+	if !r.Live || r.StartedAt.IsZero() {
+		return 0
+	}
+	return time.Since(r.StartedAt)
+}
+
+// StreamTracker tracks whether the broadcaster is live (and with what title and game), notifying the
+// listeners of every transition between online and offline.
+type StreamTracker struct {
+	mutex    sync.RWMutex
+	status   StreamStatus
+	pushedAt time.Time // of the last event telling whether live
+	online   []StreamListener
+	offline  []StreamListener
+	task     *scheduler.RepeatingTask
+}
+
+func NewStreamTracker() *StreamTracker {
+	return &StreamTracker{}
+}
+
+func (r *StreamTracker) Status() StreamStatus {
+	r.mutex.RLock()
+	defer r.mutex.RUnlock()
+	return r.status
+}
+
+func (r *StreamTracker) Live() bool {
+	r.mutex.RLock()
+	defer r.mutex.RUnlock()
+	return r.status.Live
+}
+
+// OnOnline registers a listener called whenever the stream goes live.
+func (r *StreamTracker) OnOnline(listener StreamListener) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	r.online = append(r.online, listener)
+}
+
+// OnOffline registers a listener called whenever the stream goes offline.
+func (r *StreamTracker) OnOffline(listener StreamListener) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	r.offline = append(r.offline, listener)
+}
+
+// Online marks the stream live since said time, as told by an event.
+func (r *StreamTracker) Online(startedAt time.Time) {
+	r.push(func(status *StreamStatus) {
+		status.Live = true
+		status.StartedAt = startedAt
+	}, true)
+}
+
+// Offline marks the stream offline, as told by an event.
+func (r *StreamTracker) Offline() {
+	r.push(func(status *StreamStatus) {
+		status.Live = false
+		status.StartedAt = time.Time{}
+		status.Viewers = 0
+	}, true)
+}
+
+// Channel updates the title and game, as told by an event.
+func (r *StreamTracker) Channel(title string, game string) {
+	r.push(func(status *StreamStatus) {
+		status.Title = title
+		status.Game = game
+	}, false)
+}
+
+// Poll fetches the stream (and channel) of the broadcaster, updating the status accordingly.
+func (r *StreamTracker) Poll(ctx context.Context) error {
+	broadcasterID := request.Profiles.Twitch.UserID()
+
+	stream, err := request.StreamOf(ctx, broadcasterID)
+	if err != nil {
+		return err
+	}
+
+	status := StreamStatus{UpdatedAt: time.Now()}
+	if stream != nil {
+		status.Live = true
+		status.StartedAt = stream.StartedAt
+		status.Game = stream.GameName
+		status.Title = stream.Title
+		status.Viewers = stream.ViewerCount
+	} else {
+		channel, err := request.ChannelInformationOf(ctx, broadcasterID)
+		if err != nil {
+			return err
+		}
+		if channel != nil {
+			status.Game = channel.GameName
+			status.Title = channel.Title
+		}
+	}
+
+	r.mutex.Lock()
+	previous := r.status
+
+	// an event more recent than the lag of the streams endpoint is trusted over it
+	if time.Since(r.pushedAt) < stream_lag && status.Live != previous.Live {
+		status.Live, status.StartedAt, status.Viewers = previous.Live, previous.StartedAt, previous.Viewers
+	}
+
+	r.status = status
+	listeners := r.listeners_of(previous, status)
+	r.mutex.Unlock()
+
+	notify(listeners, status)
+	return nil
+}
+
+// Start polls the stream every said interval until stopped.
+func (r *StreamTracker) Start(interval time.Duration) {
+	go r.poll()
+	r.task = scheduler.Every(interval, func(_ *scheduler.RepeatingTask) {
+		r.poll()
+	})
+}
+
+func (r *StreamTracker) Stop() {
+	if r.task != nil {
+		r.task.Cancel()
+	}
+}
+
+//////////////////////
+// HELPER FUNCTIONS //
+//////////////////////
+
+func (r *StreamTracker) poll() {
+	ctx, cancel := context.WithTimeout(context.Background(), stream_timeout)
+	defer cancel()
+
+	if err := r.Poll(ctx); err != nil {
+		util.Log("Stream", "Could not poll the stream: %s", err)
+	}
+}
+
+func (r *StreamTracker) push(update func(status *StreamStatus), tellsLive bool) {
+	r.mutex.Lock()
+	previous := r.status
+	update(&r.status)
+	r.status.UpdatedAt = time.Now()
+	if tellsLive {
+		r.pushedAt = r.status.UpdatedAt
+	}
+
+	status := r.status
+	listeners := r.listeners_of(previous, status)
+	r.mutex.Unlock()
+
+	notify(listeners, status)
+}
+
+// must be called while holding the mutex
+func (r *StreamTracker) listeners_of(previous StreamStatus, current StreamStatus) []StreamListener {
+	switch {
+	case !previous.Live && current.Live:
+		util.Log("Stream", "The stream is live.")
+		return append([]StreamListener{}, r.online...)
+	case previous.Live && !current.Live:
+		util.Log("Stream", "The stream is offline.")
+		return append([]StreamListener{}, r.offline...)
+	}
+	return nil
+}
+
+func notify(listeners []StreamListener, status StreamStatus) {
+	for _, listener := range listeners {
+		listener(status)
+	}
+}
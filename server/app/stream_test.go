@@ -0,0 +1,67 @@
+package app
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/imoliwer/sound-point-twitch-bot/server/mock"
+	"github.com/imoliwer/sound-point-twitch-bot/server/request"
+)
+
+func TestStreamTransitions(t *testing.T) {
+	helix := mock.NewHelix(mock.NewTwitchID("client", "secret"))
+	mock.UseHelix(t, helix, "streamer", "42")
+	startedAt := time.Now().Add(-time.Hour).UTC().Truncate(time.Second)
+
+	online := func(tracker *StreamTracker) { tracker.Online(startedAt) }
+	offline := func(tracker *StreamTracker) { tracker.Offline() }
+	outdated := func(tracker *StreamTracker) { tracker.pushedAt = time.Now().Add(-stream_lag) }
+	polled := func(live bool) func(*StreamTracker) {
+		return func(tracker *StreamTracker) {
+			if live {
+				helix.GoLive(request.TwitchStream{UserID: "42", UserLogin: "streamer", Title: "Chess", StartedAt: startedAt})
+			} else {
+				helix.GoOffline("42")
+			}
+			if err := tracker.Poll(context.Background()); err != nil {
+				t.Fatal(err)
+			}
+		}
+	}
+
+	for name, test := range map[string]struct {
+		steps   []func(*StreamTracker)
+		live    bool
+		online  int
+		offline int
+	}{
+		"live by event":                     {[]func(*StreamTracker){online}, true, 1, 0},
+		"live by event twice":               {[]func(*StreamTracker){online, online}, true, 1, 0},
+		"offline by event":                  {[]func(*StreamTracker){online, offline}, false, 1, 1},
+		"offline from the start":            {[]func(*StreamTracker){offline, polled(false)}, false, 0, 0},
+		"live by polling":                   {[]func(*StreamTracker){polled(true), polled(true)}, true, 1, 0},
+		"offline by polling":                {[]func(*StreamTracker){polled(true), polled(false)}, false, 1, 1},
+		"event trusted over a lagging poll": {[]func(*StreamTracker){online, polled(false)}, true, 1, 0},
+		"poll trusted over an old event":    {[]func(*StreamTracker){online, outdated, polled(false)}, false, 1, 1},
+	} {
+		tracker := NewStreamTracker()
+		var wentOnline, wentOffline int
+		tracker.OnOnline(func(StreamStatus) { wentOnline++ })
+		tracker.OnOffline(func(StreamStatus) { wentOffline++ })
+
+		for _, step := range test.steps {
+			step(tracker)
+		}
+
+		if tracker.Live() != test.live {
+			t.Errorf("%s: live is %t, expected %t", name, tracker.Live(), test.live)
+		}
+		if wentOnline != test.online || wentOffline != test.offline {
+			t.Errorf("%s: went online %d and offline %d times, expected %d and %d", name, wentOnline, wentOffline, test.online, test.offline)
+		}
+		if status := tracker.Status(); test.live && !status.StartedAt.Equal(startedAt) {
+			t.Errorf("%s: live since %s, expected %s", name, status.StartedAt, startedAt)
+		}
+	}
+}
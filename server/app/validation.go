@@ -0,0 +1,237 @@
+package app
+
+import (
+	"fmt"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"unicode"
+
+	"github.com/imoliwer/sound-point-twitch-bot/server/locale"
+)
+
+var login_regex = regexp.MustCompile(`^[a-zA-Z0-9_]{3,25}$`)
+var template_regex = regexp.MustCompile(`^<.+>$`)
+
+// Validate returns every problem found within the settings, an empty slice meaning they're usable.
+// Locale related values are checked against said catalog, or the built-in one if nil.
+func (r *Settings) Validate(locales *locale.Catalog) []error {
+	if locales == nil {
+		locales = locale.NewCatalog()
+	}
+
+	problems := make([]error, 0)
+	report := func(path string, message string, args ...any) {
+		problems = append(problems, problem(path, message, args...))
+	}
+
+	bot := r.TwitchBot
+	check_login(report, "$.twitch_chat_bot.name", bot.Name)
+	check_login(report, "$.twitch_chat_bot.channel_to_join", bot.Channel)
+
+	// legacy secrets are migrated into the secret store, yet should be usable when they are
+	if bot.AuthToken != "" && !is_template(bot.AuthToken) && !strings.HasPrefix(bot.AuthToken, "oauth:") {
+		report("$.twitch_chat_bot.auth_token", "must start with \"oauth:\"")
+	}
+
+	command := bot.Command
+	if prefix := []rune(command.Prefix); len(prefix) != 1 || prefix[0] > unicode.MaxASCII || unicode.IsSpace(prefix[0]) {
+		report("$.twitch_chat_bot.command.prefix", "must consist of ONE non-whitespace ASCII character, got %q", command.Prefix)
+	}
+
+	if command.Locale != "" && !locales.Has(command.Locale) {
+		report("$.twitch_chat_bot.command.locale", "unknown locale %q, known are %s", command.Locale, strings.Join(locales.Locales(), ", "))
+	}
+
+	for channel, channelLocale := range command.ChannelLocales {
+		path := fmt.Sprintf("$.twitch_chat_bot.command.channel_locales.%s", channel)
+		check_login(report, path, channel)
+		if !locales.Has(channelLocale) {
+			report(path, "unknown locale %q, known are %s", channelLocale, strings.Join(locales.Locales(), ", "))
+		}
+	}
+
+	check_aliases(report, command.Options)
+
+	for id, message := range command.Messages {
+		path := fmt.Sprintf("$.twitch_chat_bot.command.messages.%s", id)
+		known := locales.Placeholders(id)
+
+		if known == nil {
+			report(path, "unknown message id, known are %s", strings.Join(locales.IDs(), ", "))
+			continue
+		}
+
+		for category, text := range message.Forms {
+			formPath := path
+			if len(message.Forms) > 1 {
+				formPath += "." + category
+			}
+
+			for _, placeholder := range locale.PlaceholdersOf(text) {
+				if !contains(known, placeholder) {
+					report(formPath, "unknown placeholder {%s}, available are %s", placeholder, braced(known))
+				}
+			}
+
+			if has_format_verb(text) {
+				report(formPath, "format verbs such as %%d are not supported, use placeholders instead (%s)", braced(known))
+			}
+		}
+	}
+
+	for id, reference := range r.Audio.References {
+		path := fmt.Sprintf("$.audio.references.%s", id)
+		if id != strings.ToLower(id) {
+			report(path, "sound names must be lowercase")
+		}
+		if reference.FileName == "" || filepath.Base(reference.FileName) != reference.FileName {
+			report(path+".file_name", "must be a plain file name within the sounds directory")
+		}
+		if reward := reference.Reward; reward != nil {
+			check_reward(report, path+".reward", reward.TitleOf(id), reward.Cost, reward.Prompt)
+		}
+		if reference.VolumePercent() > MaxVolume {
+			report(path+".volume", "must be within 0 and %d", MaxVolume)
+		}
+		if PermissionRank(reference.Permission) < 0 {
+			report(path+".permission", "unknown permission level, known are %s", strings.Join(PermissionLevels, ", "))
+		}
+		if reference.Pricing != nil {
+			check_pricing(report, path+".pricing", *reference.Pricing)
+		}
+		for index, tag := range reference.Tags {
+			if strings.TrimSpace(tag) == "" {
+				report(fmt.Sprintf("%s.tags[%d]", path, index), "must not be empty")
+			}
+		}
+	}
+
+	check_library(report, r.Audio)
+
+	tts := r.Audio.TTS
+	if !contains(TTSEngines, tts.EngineOf()) {
+		report("$.audio.tts.engine", "unknown engine, known are %s", strings.Join(TTSEngines, ", "))
+	}
+	if tts.EngineOf() == TTSPiper && tts.Model == "" {
+		report("$.audio.tts.model", "must be set for piper")
+	}
+	if tts.VolumePercent() > MaxVolume {
+		report("$.audio.tts.volume", "must be within 0 and %d", MaxVolume)
+	}
+	if PermissionRank(tts.Permission) < 0 {
+		report("$.audio.tts.permission", "unknown permission level, known are %s", strings.Join(PermissionLevels, ", "))
+	}
+
+	if target := r.Audio.TargetLoudness; target != nil && (*target < MinTargetLoudness || *target > 0) {
+		report("$.audio.target_loudness_lufs", "must be within %d and 0", MinTargetLoudness)
+	}
+
+	if threshold := r.Audio.Processing.SilenceThresholdOf(); threshold < MinTargetLoudness || threshold >= 0 {
+		report("$.audio.processing.silence_threshold_db", "must be within %d and 0 (exclusive)", MinTargetLoudness)
+	}
+
+	for index, conversion := range r.ChannelPoints.Conversions {
+		path := fmt.Sprintf("$.channel_points.conversions[%d]", index)
+		if conversion.Title == "" {
+			report(path+".title", "must be set")
+		}
+		if conversion.Points == 0 {
+			report(path+".points", "must be at least 1")
+		}
+		check_reward(report, path, conversion.Title, conversion.Cost, conversion.Prompt)
+	}
+	return problems
+}
+
+//////////////////////
+// HELPER FUNCTIONS //
+//////////////////////
+
+func check_login(report func(string, string, ...any), path string, value string) {
+	switch {
+	case is_template(value):
+		report(path, "still contains the template value %s", value)
+	case !login_regex.MatchString(value):
+		report(path, "must be a Twitch login (3-25 letters, digits or underscores), got %q", value)
+	}
+}
+
+// ensure a custom reward is accepted by twitch
+func check_reward(report func(string, string, ...any), path string, title string, cost uint64, prompt string) {
+	if len([]rune(title)) > MaxRewardTitle {
+		report(path+".title", "must be at most %d characters", MaxRewardTitle)
+	}
+	if cost == 0 {
+		report(path+".cost", "must be at least 1")
+	}
+	if len([]rune(prompt)) > MaxRewardPrompt {
+		report(path+".prompt", "must be at most %d characters", MaxRewardPrompt)
+	}
+}
+
+// ensure no alias is shared between commands, or shadows the name of another command
+func check_aliases(report func(string, string, ...any), options map[string]TwitchCommandPrimaryOption) {
+	primaryOwners := make(map[string]string)
+	for name := range options {
+		primaryOwners[strings.ToLower(name)] = name
+	}
+
+	for name, option := range options {
+		path := fmt.Sprintf("$.twitch_chat_bot.command.options.%s", name)
+		check_alias_list(report, path, name, option.Aliases, primaryOwners)
+
+		childOwners := make(map[string]string)
+		for childName := range option.Arguments {
+			childOwners[strings.ToLower(childName)] = childName
+		}
+
+		for childName, childOption := range option.Arguments {
+			childPath := fmt.Sprintf("%s.arguments.%s", path, childName)
+			check_alias_list(report, childPath, childName, childOption.Aliases, childOwners)
+		}
+	}
+}
+
+func check_alias_list(report func(string, string, ...any), path string, owner string, aliases []string, owners map[string]string) {
+	for index, alias := range aliases {
+		aliasPath := fmt.Sprintf("%s.aliases[%d]", path, index)
+		lowered := strings.ToLower(alias)
+
+		if alias == "" || strings.IndexFunc(alias, unicode.IsSpace) != -1 {
+			report(aliasPath, "must be a single word")
+			continue
+		}
+
+		if existing, ok := owners[lowered]; ok && existing != owner {
+			report(aliasPath, "%q is already used by %q", alias, existing)
+			continue
+		}
+		owners[lowered] = owner
+	}
+}
+
+func is_template(value string) bool {
+	return template_regex.MatchString(value)
+}
+
+func contains(values []string, value string) bool {
+	for _, entry := range values {
+		if entry == value {
+			return true
+		}
+	}
+	return false
+}
+
+func braced(placeholders []string) string {
+	if len(placeholders) == 0 {
+		return "none"
+	}
+
+	population := make([]string, len(placeholders))
+	for index, placeholder := range placeholders {
+		population[index] = fmt.Sprintf("{%s}", placeholder)
+	}
+	return strings.Join(population, ", ")
+}
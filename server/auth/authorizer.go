@@ -0,0 +1,242 @@
+package auth
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/imoliwer/sound-point-twitch-bot/server/app"
+	"github.com/imoliwer/sound-point-twitch-bot/server/request"
+	"github.com/imoliwer/sound-point-twitch-bot/server/util"
+)
+
+const (
+	AccountBroadcaster = "broadcaster"
+	AccountBot         = "bot"
+	state_lifetime     = 10 * time.Minute
+)
+
+// Scopes requested per account.
+var Scopes = map[string][]string{
+	AccountBroadcaster: {
+		"bits:read",
+		"channel:read:redemptions",
+		"channel:manage:redemptions",
+		"channel:read:hype_train",
+		"channel:read:vips",
+		"channel:read:subscriptions",
+		"moderation:read",
+		"moderator:read:chatters",
+		"moderator:read:followers",
+	},
+	AccountBot: {
+		"chat:read",
+		"chat:edit",
+	},
+}
+
+// MissingScopes returns the scopes requested for said account which its token wasn't granted, such as those
+// requested since it was authorized. Nothing is missing while the scopes granted are unknown, not validated yet.
+func MissingScopes(account string, health request.TokenHealth) []string {
+	if health.Scopes == nil {
+		return nil
+	}
+
+	granted := make(map[string]bool, len(health.Scopes))
+	for _, scope := range health.Scopes {
+		granted[scope] = true
+	}
+
+	missing := make([]string, 0)
+	for _, scope := range Scopes[account] {
+		if !granted[scope] {
+			missing = append(missing, scope)
+		}
+	}
+	if len(missing) == 0 {
+		return nil
+	}
+	return missing
+}
+
+type AuthorizedListener = func(account string, login string)
+
+type pending_state struct {
+	account   string
+	expiresAt time.Time
+}
+
+type Authorizer struct {
+	app       *app.Application
+	publicURL string
+	mutex     sync.Mutex
+	states    map[string]pending_state
+	listeners []AuthorizedListener
+}
+
+func NewAuthorizer(application *app.Application, publicURL string) *Authorizer {
+	return &Authorizer{
+		app:       application,
+		publicURL: strings.TrimSuffix(publicURL, "/"),
+		states:    make(map[string]pending_state),
+	}
+}
+
+// OnAuthorized registers a listener called after every account that has been authorized and stored.
+func (r *Authorizer) OnAuthorized(listener AuthorizedListener) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	r.listeners = append(r.listeners, listener)
+}
+
+// LoginURL returns the url said account has to visit to authorize the bot.
+func (r *Authorizer) LoginURL(account string) string {
+	return fmt.Sprintf("%s/auth/twitch/login?account=%s", r.publicURL, account)
+}
+
+func (r *Authorizer) Handler(engine *gin.Engine) {
+	engine.GET("/auth/status", func(ctx *gin.Context) {
+		statuses := make([]account_status, 0, 2)
+		for _, account := range []string{AccountBroadcaster, AccountBot} {
+			health := manager_of(account).Health()
+			missing := MissingScopes(account, health)
+			statuses = append(statuses, account_status{
+				TokenHealth:          health,
+				MissingScopes:        missing,
+				NeedsReauthorization: health.Status == request.TokenInvalid || len(missing) > 0,
+				LoginURL:             r.LoginURL(account),
+			})
+		}
+		ctx.JSON(http.StatusOK, statuses)
+	})
+
+	engine.GET("/auth/twitch/login", func(ctx *gin.Context) {
+		account := ctx.DefaultQuery("account", AccountBroadcaster)
+		scopes, ok := Scopes[account]
+		if !ok {
+			ctx.String(http.StatusBadRequest, "unknown account, must be either 'broadcaster' or 'bot'")
+			return
+		}
+
+		state, err := r.new_state(account)
+		if err != nil {
+			ctx.String(http.StatusInternalServerError, "failed generating state")
+			return
+		}
+		ctx.Redirect(http.StatusFound, request.TwitchAuthorizeURL(r.redirect_uri(), state, scopes))
+	})
+
+	engine.GET("/auth/twitch/callback", func(ctx *gin.Context) {
+		if reason := ctx.Query("error"); reason != "" {
+			ctx.String(http.StatusBadRequest, "authorization was declined: %s", ctx.DefaultQuery("error_description", reason))
+			return
+		}
+
+		account, ok := r.consume_state(ctx.Query("state"))
+		if !ok {
+			ctx.String(http.StatusBadRequest, "unknown or expired state, please start over")
+			return
+		}
+
+		code := ctx.Query("code")
+		if code == "" {
+			ctx.String(http.StatusBadRequest, "missing code")
+			return
+		}
+
+		tokens, err := request.ExchangeTwitchCode(code, r.redirect_uri())
+		if err != nil {
+			ctx.String(http.StatusBadGateway, "failed exchanging the code with Twitch: %s", err)
+			return
+		}
+
+		validation, err := request.ValidateTwitchToken(tokens.AccessToken)
+		if err != nil {
+			ctx.String(http.StatusBadGateway, "failed validating the token with Twitch: %s", err)
+			return
+		}
+
+		if expected := r.expected_login(account); !strings.EqualFold(validation.Login, expected) {
+			request.RevokeTwitchToken(tokens.AccessToken)
+			ctx.String(http.StatusBadRequest, "authorized as '%s', but the %s is configured as '%s'", validation.Login, account, expected)
+			return
+		}
+
+		if err := r.store(account, tokens); err != nil {
+			ctx.String(http.StatusInternalServerError, "failed storing the tokens: %s", err)
+			return
+		}
+
+		util.Log("Auth", "Authorized '%s' as the %s.", validation.Login, account)
+		r.notify(account, validation.Login)
+		ctx.String(http.StatusOK, "Authorized '%s' as the %s. You may close this page.", validation.Login, account)
+	})
+}
+
+func (r *Authorizer) store(account string, tokens *request.TwitchOAuthRefresh) error {
+	return manager_of(account).Authorize(tokens)
+}
+
+func (r *Authorizer) notify(account string, login string) {
+	r.mutex.Lock()
+	listeners := r.listeners
+	r.mutex.Unlock()
+
+	for _, listener := range listeners {
+		listener(account, login)
+	}
+}
+
+func (r *Authorizer) expected_login(account string) string {
+	bot := r.app.Settings().TwitchBot
+	if account == AccountBot {
+		return bot.Name
+	}
+	return bot.Channel
+}
+
+func (r *Authorizer) redirect_uri() string {
+	return fmt.Sprintf("%s/auth/twitch/callback", r.publicURL)
+}
+
+func (r *Authorizer) new_state(account string) (string, error) {
+	raw := make([]byte, 16)
+	if _, err := rand.Read(raw); err != nil {
+		return "", err
+	}
+
+	state := hex.EncodeToString(raw)
+	now := time.Now()
+
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	for key, pending := range r.states {
+		if now.After(pending.expiresAt) {
+			delete(r.states, key)
+		}
+	}
+	r.states[state] = pending_state{account: account, expiresAt: now.Add(state_lifetime)}
+	return state, nil
+}
+
+func (r *Authorizer) consume_state(state string) (string, bool) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	pending, ok := r.states[state]
+	if !ok {
+		return "", false
+	}
+
+	delete(r.states, state)
+	if time.Now().After(pending.expiresAt) {
+		return "", false
+	}
+	return pending.account, true
+}
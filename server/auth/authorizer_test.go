@@ -0,0 +1,141 @@
+package auth
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"reflect"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/imoliwer/sound-point-twitch-bot/server/app"
+	"github.com/imoliwer/sound-point-twitch-bot/server/mock"
+	"github.com/imoliwer/sound-point-twitch-bot/server/request"
+)
+
+func TestAuthorizationCodeExchange(t *testing.T) {
+	engine, id, authorizer := authorizer_of(t)
+	id.AuthorizeAs("streamer", "42")
+
+	var authorized string
+	authorizer.OnAuthorized(func(account string, login string) {
+		authorized = account + ":" + login
+	})
+
+	recorder := serve(engine, authorize(t, engine, AccountBroadcaster))
+	if recorder.Code != http.StatusOK {
+		t.Fatalf("callback responded %d: %s", recorder.Code, recorder.Body)
+	}
+
+	login, scopes, ok := id.Grant(request.Profiles.Twitch.Profile().OAuthToken)
+	if !ok || login != "streamer" {
+		t.Fatalf("stored token is not one issued for the broadcaster (login %q, valid %t)", login, ok)
+	}
+	if !reflect.DeepEqual(scopes, Scopes[AccountBroadcaster]) {
+		t.Errorf("granted %v, requested %v", scopes, Scopes[AccountBroadcaster])
+	}
+	if request.Profiles.Twitch.Profile().RefreshToken == "" {
+		t.Error("refresh token was not stored")
+	}
+	if authorized != "broadcaster:streamer" {
+		t.Errorf("listeners were told %q", authorized)
+	}
+}
+
+func TestAuthorizationOfAnotherAccount(t *testing.T) {
+	engine, id, _ := authorizer_of(t)
+	id.AuthorizeAs("someone_else", "7")
+
+	recorder := serve(engine, authorize(t, engine, AccountBot))
+	if recorder.Code != http.StatusBadRequest {
+		t.Fatalf("callback responded %d, expected %d", recorder.Code, http.StatusBadRequest)
+	}
+	if token := request.Profiles.Bot.Profile().OAuthToken; token != "" {
+		t.Errorf("token of another account was stored: %q", token)
+	}
+}
+
+func TestCallbackOfUnknownState(t *testing.T) {
+	engine, _, _ := authorizer_of(t)
+
+	recorder := serve(engine, "/auth/twitch/callback?code=anything&state=unknown")
+	if recorder.Code != http.StatusBadRequest {
+		t.Errorf("callback responded %d, expected %d", recorder.Code, http.StatusBadRequest)
+	}
+}
+
+func TestStatusOfTokenLackingScopes(t *testing.T) {
+	engine, id, _ := authorizer_of(t)
+
+	// authorized before the scope of moderators reading followers was requested
+	granted := Scopes[AccountBroadcaster][:len(Scopes[AccountBroadcaster])-1]
+	accessToken, refreshToken := id.Issue("streamer", "42", granted...)
+	if err := request.Profiles.Twitch.Authorize(&request.TwitchOAuthRefresh{AccessToken: accessToken, RefreshToken: refreshToken, ExpiresIn: 3600}); err != nil {
+		t.Fatalf("authorizing: %s", err)
+	}
+	request.Profiles.Twitch.Check()
+
+	recorder := serve(engine, "/auth/status")
+	var statuses []account_status
+	if err := json.Unmarshal(recorder.Body.Bytes(), &statuses); err != nil {
+		t.Fatalf("status unreadable: %s", err)
+	}
+
+	broadcaster := statuses[0]
+	if broadcaster.Status != request.TokenValid || !broadcaster.NeedsReauthorization {
+		t.Errorf("token is %s and needs reauthorization %t, expected valid yet needing it", broadcaster.Status, broadcaster.NeedsReauthorization)
+	}
+	if !reflect.DeepEqual(broadcaster.MissingScopes, []string{"moderator:read:followers"}) {
+		t.Errorf("missing %v, expected the scope of reading followers", broadcaster.MissingScopes)
+	}
+}
+
+//////////////////////
+// HELPER FUNCTIONS //
+//////////////////////
+
+func authorizer_of(t *testing.T) (*gin.Engine, *mock.TwitchID, *Authorizer) {
+	gin.SetMode(gin.TestMode)
+
+	id := mock.NewTwitchID("client", "secret")
+	mock.UseTwitchID(t, id)
+
+	application := app.NewApplication(&app.Settings{
+		TwitchBot: app.TwitchBotSettings{Name: "soundbot", Channel: "streamer"},
+	}, nil, nil)
+
+	engine := gin.New()
+	authorizer := NewAuthorizer(application, "http://localhost:9999")
+	authorizer.Handler(engine)
+	return engine, id, authorizer
+}
+
+// go through the login of said account, returning the path (and query) twitch redirects back to
+func authorize(t *testing.T, engine *gin.Engine, account string) string {
+	login := serve(engine, "/auth/twitch/login?account="+account)
+	if login.Code != http.StatusFound {
+		t.Fatalf("login responded %d", login.Code)
+	}
+
+	client := &http.Client{CheckRedirect: func(*http.Request, []*http.Request) error {
+		return http.ErrUseLastResponse
+	}}
+	res, err := client.Get(login.Header().Get("Location"))
+	if err != nil {
+		t.Fatalf("authorizing: %s", err)
+	}
+	res.Body.Close()
+
+	callback, err := url.Parse(res.Header.Get("Location"))
+	if err != nil || callback.Path != "/auth/twitch/callback" {
+		t.Fatalf("twitch redirected to %q", res.Header.Get("Location"))
+	}
+	return callback.RequestURI()
+}
+
+func serve(engine *gin.Engine, target string) *httptest.ResponseRecorder {
+	recorder := httptest.NewRecorder()
+	engine.ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, target, nil))
+	return recorder
+}
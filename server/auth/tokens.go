@@ -0,0 +1,62 @@
+package auth
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/imoliwer/sound-point-twitch-bot/server/app"
+	"github.com/imoliwer/sound-point-twitch-bot/server/request"
+	"github.com/imoliwer/sound-point-twitch-bot/server/secret"
+)
+
+type account_status struct {
+	request.TokenHealth
+	MissingScopes        []string `json:"missing_scopes,omitempty"`
+	NeedsReauthorization bool     `json:"needs_reauthorization"` // as the token is invalid or lacks scopes
+	LoginURL             string   `json:"login_url"`
+}
+
+// NewProfiles creates the token managers of both accounts from the stored secrets,
+// persisting every pair of tokens obtained back into them.
+func NewProfiles(application *app.Application) *request.RequestProfiles {
+	stored := application.Secrets.Get()
+	return &request.RequestProfiles{
+		Twitch: request.NewTokenManager(
+			AccountBroadcaster,
+			request.TwitchRequestProfile{
+				ClientID:     stored.TwitchClientID,
+				ClientSecret: stored.TwitchClientSecret,
+				OAuthToken:   stored.TwitchAccessToken,
+				RefreshToken: stored.TwitchRefreshToken,
+			},
+			func(tokens *request.TwitchOAuthRefresh) error {
+				return application.Secrets.Update(func(secrets *secret.Secrets) {
+					secrets.TwitchAccessToken = tokens.AccessToken
+					secrets.TwitchRefreshToken = tokens.RefreshToken
+				})
+			},
+		),
+		Bot: request.NewTokenManager(
+			AccountBot,
+			request.TwitchRequestProfile{
+				ClientID:     stored.TwitchClientID,
+				ClientSecret: stored.TwitchClientSecret,
+				OAuthToken:   strings.TrimPrefix(stored.BotAuthToken, "oauth:"),
+				RefreshToken: stored.BotRefreshToken,
+			},
+			func(tokens *request.TwitchOAuthRefresh) error {
+				return application.Secrets.Update(func(secrets *secret.Secrets) {
+					secrets.BotAuthToken = fmt.Sprintf("oauth:%s", tokens.AccessToken)
+					secrets.BotRefreshToken = tokens.RefreshToken
+				})
+			},
+		),
+	}
+}
+
+func manager_of(account string) *request.TokenManager {
+	if account == AccountBot {
+		return request.Profiles.Bot
+	}
+	return request.Profiles.Twitch
+}
@@ -0,0 +1,73 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+
+	"github.com/imoliwer/sound-point-twitch-bot/server/app"
+	"github.com/imoliwer/sound-point-twitch-bot/server/locale"
+	"github.com/imoliwer/sound-point-twitch-bot/server/secret"
+)
+
+func run_subcommand(config *app.Config, name string, args []string) {
+	switch name {
+	case "validate-config":
+		validate_config(config, args)
+	case "settings-schema":
+		encoder := json.NewEncoder(os.Stdout)
+		encoder.SetIndent("", "  ")
+		encoder.Encode(app.Schema())
+	default:
+		fmt.Fprintf(os.Stderr, "Unknown command '%s'. Available: validate-config [file], settings-schema\n", name)
+		os.Exit(2)
+	}
+}
+
+// validate the settings file (or the one passed) without starting the bot, printing every problem by its json path
+func validate_config(config *app.Config, args []string) {
+	path := app.SettingsFile
+	if len(args) > 0 {
+		path = args[0]
+	}
+
+	catalog, err := locale.Load(config.LocalesDirectory)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Could not load locales: %s\n", err)
+		os.Exit(1)
+	}
+
+	content, err := os.ReadFile(path)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Could not read '%s': %s\n", path, err)
+		os.Exit(1)
+	}
+
+	valid := true
+	if _, err := app.ParseSettings(content, catalog); err != nil {
+		valid = false
+		fmt.Printf("'%s' is invalid:\n", path)
+		for _, problem := range app.ProblemsOf(err) {
+			fmt.Printf("  %s\n", problem)
+		}
+	}
+
+	secrets, err := secret.Open(app.SecretsFile, os.Getenv(secret.PassphraseVariable))
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Could not open secrets: %s\n", err)
+		os.Exit(1)
+	}
+
+	if problems := secrets.Validate(); len(problems) > 0 {
+		valid = false
+		fmt.Printf("Secrets ('%s' and environment) are invalid:\n", app.SecretsFile)
+		for _, problem := range problems {
+			fmt.Printf("  %s\n", problem)
+		}
+	}
+
+	if !valid {
+		os.Exit(1)
+	}
+	fmt.Printf("'%s' and secrets are valid.\n", path)
+}
@@ -0,0 +1,179 @@
+package command
+
+import (
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/imoliwer/sound-point-twitch-bot/server/app"
+	"github.com/imoliwer/sound-point-twitch-bot/server/util"
+)
+
+type CooldownState struct {
+	Global   uint64            `json:"global"`
+	Channels map[string]uint64 `json:"channels"`
+	Users    map[string]uint64 `json:"users"`
+}
+
+type CooldownTracker struct {
+	mutex    sync.Mutex
+	global   map[string]time.Time
+	channels map[string]map[string]time.Time
+	users    map[string]map[string]time.Time
+}
+
+func NewCooldownTracker() *CooldownTracker {
+	return &CooldownTracker{
+		global:   make(map[string]time.Time),
+		channels: make(map[string]map[string]time.Time),
+		users:    make(map[string]map[string]time.Time),
+	}
+}
+
+// Attempt checks every cooldown of said command (key) and starts them all if none are active.
+// When one or more are active, the longest remaining duration is returned instead.
+func (r *CooldownTracker) Attempt(key string, channel string, user string, option app.TwitchCommandCooldown) (time.Duration, bool) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	now := time.Now()
+	if remaining := r.remaining(key, channel, user, now); remaining > 0 {
+		return remaining, false
+	}
+
+	if option.Global > 0 {
+		r.global[key] = now.Add(seconds(option.Global))
+	}
+	if option.Channel > 0 {
+		expire_entries(r.channels, key, now)[channel] = now.Add(seconds(option.Channel))
+	}
+	if option.User > 0 {
+		expire_entries(r.users, key, now)[user] = now.Add(seconds(option.User))
+	}
+	return 0, true
+}
+
+// Remaining returns the longest remaining duration of the cooldowns of said command (key), without starting them.
+func (r *CooldownTracker) Remaining(key string, channel string, user string) time.Duration {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	return r.remaining(key, channel, user, time.Now())
+}
+
+// Reset removes every active cooldown of said command (key), including those of its children.
+func (r *CooldownTracker) Reset(key string) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	for _, entries := range []map[string]map[string]time.Time{r.channels, r.users} {
+		for name := range entries {
+			if is_command_of(name, key) {
+				delete(entries, name)
+			}
+		}
+	}
+	for name := range r.global {
+		if is_command_of(name, key) {
+			delete(r.global, name)
+		}
+	}
+}
+
+// State returns the remaining seconds of every active cooldown, by command.
+func (r *CooldownTracker) State() map[string]CooldownState {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	now := time.Now()
+	population := make(map[string]CooldownState)
+	entry := func(key string) CooldownState {
+		state, ok := population[key]
+		if !ok {
+			state = CooldownState{
+				Channels: make(map[string]uint64),
+				Users:    make(map[string]uint64),
+			}
+		}
+		return state
+	}
+
+	for key, expiresAt := range r.global {
+		if remaining := until(now, expiresAt); remaining > 0 {
+			state := entry(key)
+			state.Global = util.WholeSeconds(remaining)
+			population[key] = state
+		}
+	}
+
+	for key := range r.channels {
+		for channel, expiresAt := range expire_entries(r.channels, key, now) {
+			state := entry(key)
+			state.Channels[channel] = util.WholeSeconds(until(now, expiresAt))
+			population[key] = state
+		}
+	}
+
+	for key := range r.users {
+		for user, expiresAt := range expire_entries(r.users, key, now) {
+			state := entry(key)
+			state.Users[user] = util.WholeSeconds(until(now, expiresAt))
+			population[key] = state
+		}
+	}
+	return population
+}
+
+//////////////////////
+// HELPER FUNCTIONS //
+//////////////////////
+
+// must be called while holding the mutex
+func (r *CooldownTracker) remaining(key string, channel string, user string, now time.Time) time.Duration {
+	return max_duration(
+		until(now, r.global[key]),
+		until(now, r.channels[key][channel]),
+		until(now, r.users[key][user]),
+	)
+}
+
+// whether said key is that of said command or one of its children, keyed as "<command> <child>"
+func is_command_of(name string, key string) bool {
+	return name == key || strings.HasPrefix(name, key+" ")
+}
+
+// drop the expired entries of said key and return what's left (creating it if absent)
+func expire_entries(entries map[string]map[string]time.Time, key string, now time.Time) map[string]time.Time {
+	keyed, ok := entries[key]
+	if !ok {
+		keyed = make(map[string]time.Time)
+		entries[key] = keyed
+	}
+
+	for name, expiresAt := range keyed {
+		if !expiresAt.After(now) {
+			delete(keyed, name)
+		}
+	}
+	return keyed
+}
+
+func until(now time.Time, expiresAt time.Time) time.Duration {
+	if expiresAt.IsZero() {
+		return 0
+	}
+	return expiresAt.Sub(now)
+}
+
+func max_duration(durations ...time.Duration) time.Duration {
+	var highest time.Duration
+	for _, duration := range durations {
+		if duration > highest {
+			highest = duration
+		}
+	}
+	return highest
+}
+
+func seconds(amount uint64) time.Duration {
+	return time.Duration(amount) * time.Second
+}
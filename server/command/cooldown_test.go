@@ -0,0 +1,75 @@
+package command
+
+import (
+	"testing"
+	"time"
+
+	"github.com/imoliwer/sound-point-twitch-bot/server/app"
+)
+
+func TestCooldownsAttempted(t *testing.T) {
+	for name, test := range map[string]struct {
+		option  app.TwitchCommandCooldown
+		channel string
+		user    string
+		ok      bool
+	}{
+		"global, another user":        {app.TwitchCommandCooldown{Global: 30}, "streamer", "8", false},
+		"channel, same channel":       {app.TwitchCommandCooldown{Channel: 30}, "streamer", "8", false},
+		"channel, another channel":    {app.TwitchCommandCooldown{Channel: 30}, "other", "7", true},
+		"user, same user":             {app.TwitchCommandCooldown{User: 30}, "other", "7", false},
+		"user, another user":          {app.TwitchCommandCooldown{User: 30}, "streamer", "8", true},
+		"none, same user and channel": {app.TwitchCommandCooldown{}, "streamer", "7", true},
+	} {
+		cooldowns := NewCooldownTracker()
+		if _, ok := cooldowns.Attempt("points", "streamer", "7", test.option); !ok {
+			t.Errorf("%s: first attempt refused", name)
+			continue
+		}
+
+		remaining, ok := cooldowns.Attempt("points", test.channel, test.user, test.option)
+		if ok != test.ok {
+			t.Errorf("%s: second attempt passed as %t, expected %t", name, ok, test.ok)
+		}
+		if !ok && (remaining <= 29*time.Second || remaining > 30*time.Second) {
+			t.Errorf("%s: %s remaining, expected about 30s", name, remaining)
+		}
+	}
+}
+
+func TestRemainingStartsNothing(t *testing.T) {
+	cooldowns := NewCooldownTracker()
+	option := app.TwitchCommandCooldown{User: 30}
+
+	if remaining := cooldowns.Remaining("points", "streamer", "7"); remaining != 0 {
+		t.Errorf("%s remaining before any attempt", remaining)
+	}
+	if _, ok := cooldowns.Attempt("points", "streamer", "7", option); !ok {
+		t.Error("attempt refused after checking what's remaining")
+	}
+	if remaining := cooldowns.Remaining("points", "streamer", "7"); remaining <= 0 {
+		t.Error("nothing remaining once attempted")
+	}
+}
+
+func TestResetIncludesChildren(t *testing.T) {
+	cooldowns := NewCooldownTracker()
+	option := app.TwitchCommandCooldown{Global: 30, Channel: 30, User: 30}
+	for _, key := range []string{"points", "points give", "pointsboard", "sound"} {
+		cooldowns.Attempt(key, "streamer", "7", option)
+	}
+
+	cooldowns.Reset("points")
+
+	state := cooldowns.State()
+	for key, active := range map[string]bool{
+		"points":      false,
+		"points give": false,
+		"pointsboard": true,
+		"sound":       true,
+	} {
+		if _, ok := state[key]; ok != active {
+			t.Errorf("%q active: %t, expected %t", key, ok, active)
+		}
+	}
+}
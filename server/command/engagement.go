@@ -0,0 +1,87 @@
+package command
+
+import (
+	"context"
+	"strconv"
+
+	"github.com/imoliwer/sound-point-twitch-bot/server/engagement"
+	"github.com/imoliwer/sound-point-twitch-bot/server/util"
+)
+
+func NewWatchTimeCommand(tracker *engagement.Tracker) PrimaryCommand {
+	return PrimaryCommand{
+		Command: Command{
+			Requirements: make([]UserRequirement, 0),
+			Execute: func(ctx Context) {
+				name, userId, ok := engagement_target(&ctx)
+				if !ok {
+					return
+				}
+
+				lookup, cancel := context.WithTimeout(context.Background(), lookup_timeout)
+				defer cancel()
+
+				recorded, err := tracker.Of(lookup, strconv.FormatUint(userId, 10))
+				if err != nil {
+					util.Log("Commands", "Could not look up the watch time of '%s': %s", name, err)
+					ctx.Reply(ctx.Message("operation_failed"))
+					return
+				}
+
+				ctx.Temp["response-watchtime"] = format_duration(recorded.WatchTime)
+				ctx.ReplyExtra(ctx.Message("watchtime"), engagement_placeholders)
+			},
+		},
+		Children: map[string]Command{},
+	}
+}
+
+func NewFollowAgeCommand(tracker *engagement.Tracker) PrimaryCommand {
+	return PrimaryCommand{
+		Command: Command{
+			Requirements: make([]UserRequirement, 0),
+			Execute: func(ctx Context) {
+				name, userId, ok := engagement_target(&ctx)
+				if !ok {
+					return
+				}
+
+				lookup, cancel := context.WithTimeout(context.Background(), lookup_timeout)
+				defer cancel()
+
+				followedAt, following, err := tracker.FollowOf(lookup, strconv.FormatUint(userId, 10))
+				if err != nil {
+					util.Log("Commands", "Could not look up the follow of '%s': %s", name, err)
+					ctx.Reply(ctx.Message("operation_failed"))
+					return
+				}
+
+				if !following {
+					ctx.ReplyExtra(ctx.Message("followage_not_following"), engagement_placeholders)
+					return
+				}
+
+				ctx.Temp["response-followage"] = format_age(followedAt)
+				ctx.ReplyExtra(ctx.Message("followage"), engagement_placeholders)
+			},
+		},
+		Children: map[string]Command{},
+	}
+}
+
+//////////////////////
+// HELPER FUNCTIONS //
+//////////////////////
+
+// the user named by the first argument, if any, and the sender otherwise
+func engagement_target(ctx *Context) (string, uint64, bool) {
+	if len(ctx.Arguments) == 0 {
+		userId, err := util.Uint64(ctx.State.User.Id)
+		ctx.Temp["response-target"] = ctx.State.User.DisplayName
+		return ctx.State.User.DisplayName, userId, err == nil
+	}
+
+	ctx.Temp["response-target"] = ctx.Arguments[0]
+	username, userId, err := user_of(ctx)
+	return username, userId, err == nil
+}
@@ -0,0 +1,109 @@
+package command
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/imoliwer/sound-point-twitch-bot/server/sound"
+	"github.com/imoliwer/sound-point-twitch-bot/server/util"
+)
+
+// NewPacksCommand lists the packs of sounds a page at a time, telling which the chatter unlocked, and unlocks them for points.
+func NewPacksCommand(packs *sound.Packs) PrimaryCommand {
+	return PrimaryCommand{
+		Command: Command{
+			Requirements: make([]UserRequirement, 0),
+			Execute: func(ctx Context) {
+				page := 1
+				if len(ctx.Arguments) > 0 {
+					if number, err := strconv.Atoi(ctx.Arguments[0]); err == nil {
+						page = number
+					}
+				}
+
+				available := ctx.Client.App.Settings().Audio.Packs
+				if len(available) == 0 {
+					ctx.Reply(ctx.Message("packs_none"))
+					return
+				}
+
+				names := make([]string, 0, len(available))
+				for name := range available {
+					names = append(names, name)
+				}
+				sort.Strings(names)
+
+				entries := make([]string, len(names))
+				for index, name := range names {
+					if packs.Owns(ctx.State.User.Id, name) {
+						entries[index] = fmt.Sprintf("%s (%s)", name, ctx.Message("pack_owned"))
+						continue
+					}
+					entries[index] = fmt.Sprintf("%s (%d)", name, available[name].Price)
+				}
+
+				message := ctx.Message("packs_list")
+				pages := pages_within(ctx, message, packs_placeholders, "response-packs", entries)
+				if page < 1 || page > len(pages) {
+					ctx.ReplyExtra(ctx.PluralMessage("packs_page_not_found", uint64(len(pages))), packs_placeholders)
+					return
+				}
+
+				ctx.Temp["response-page"] = page
+				ctx.Temp["response-packs"] = strings.Join(pages[page-1], ", ")
+				ctx.ReplyExtra(message, packs_placeholders)
+			},
+		},
+		Children: map[string]Command{
+			"unlock": {
+				Requirements: make([]UserRequirement, 0),
+				Execute: func(ctx Context) {
+					packs_unlock(ctx, packs)
+				},
+			},
+		},
+	}
+}
+
+//////////////////////
+// HELPER FUNCTIONS //
+//////////////////////
+
+func packs_unlock(ctx Context, packs *sound.Packs) {
+	if len(ctx.Arguments) == 0 {
+		ctx.Reply(ctx.Message("specify_pack"))
+		return
+	}
+
+	name := strings.ToLower(ctx.Arguments[0])
+	ctx.Temp["response-pack"] = name
+
+	pack, ok := ctx.Client.App.Settings().Audio.Packs[name]
+	if !ok {
+		ctx.ReplyExtra(ctx.Message("pack_not_found"), packs_placeholders)
+		return
+	}
+
+	user := &ctx.State.User
+	if packs.Owns(user.Id, name) {
+		ctx.ReplyExtra(ctx.Message("pack_already_unlocked"), packs_placeholders)
+		return
+	}
+
+	ctx.Temp["response-points"] = pack.Price
+	unlocked, err := packs.Unlock(context.Background(), user.Id, name, pack.Price)
+	if err != nil {
+		util.Log("Commands", "Could not unlock the pack '%s' for %s: %s", name, user.Login, err)
+		ctx.Reply(ctx.Message("operation_failed"))
+		return
+	}
+
+	if !unlocked {
+		ctx.ReplyExtra(ctx.PluralMessage("pack_not_enough_points", pack.Price), packs_placeholders)
+		return
+	}
+	ctx.ReplyExtra(ctx.PluralMessage("pack_unlocked", pack.Price), packs_placeholders)
+}
@@ -1,6 +1,9 @@
 package command
 
-import "fmt"
+import (
+	"fmt"
+	"time"
+)
 
 type PlaceholderFunc func(*Context) any
 
@@ -14,6 +17,23 @@ type PlaceholderIndex struct {
 //      SHARED      //
 //////////////////////
 
+// GeneralPlaceholders are available in every message.
+var GeneralPlaceholders = map[string]PlaceholderFunc{
+	"uptime": func(ctx *Context) any {
+		status := ctx.Client.App.Stream.Status()
+		if !status.Live {
+			return ctx.Message("stream_offline")
+		}
+		return format_duration(status.Uptime())
+	},
+	"game": func(ctx *Context) any {
+		return ctx.Client.App.Stream.Status().Game
+	},
+	"title": func(ctx *Context) any {
+		return ctx.Client.App.Stream.Status().Title
+	},
+}
+
 var points_placeholders = map[string]PlaceholderFunc{
 	"target": func(ctx *Context) any {
 		return ctx.Arguments[0]
@@ -27,6 +47,110 @@ var points_placeholders = map[string]PlaceholderFunc{
 	},
 }
 
+var engagement_placeholders = map[string]PlaceholderFunc{
+	"target": func(ctx *Context) any {
+		return ctx.Temp["response-target"]
+	},
+	"watchtime": func(ctx *Context) any {
+		return ctx.Temp["response-watchtime"]
+	},
+	"followage": func(ctx *Context) any {
+		return ctx.Temp["response-followage"]
+	},
+}
+
+var sounds_placeholders = map[string]PlaceholderFunc{
+	"sounds": func(ctx *Context) any {
+		return ctx.Temp["response-sounds"]
+	},
+	"search": func(ctx *Context) any {
+		return ctx.Temp["response-search"]
+	},
+	"link": func(ctx *Context) any {
+		return ctx.Temp["response-link"]
+	},
+	"page": func(ctx *Context) any {
+		return ctx.Temp["response-page"]
+	},
+	"pages": func(ctx *Context) any {
+		return ctx.Temp["response-pages"]
+	},
+}
+
+var sound_placeholders = map[string]PlaceholderFunc{
+	"sound": func(ctx *Context) any {
+		return ctx.Temp["response-sound"]
+	},
+	"points": func(ctx *Context) any {
+		return ctx.Temp["response-points"]
+	},
+	"remaining": func(ctx *Context) any {
+		return ctx.Temp["response-remaining"]
+	},
+	"packs": func(ctx *Context) any {
+		return ctx.Temp["response-packs"]
+	},
+}
+
+var packs_placeholders = map[string]PlaceholderFunc{
+	"pack": func(ctx *Context) any {
+		return ctx.Temp["response-pack"]
+	},
+	"packs": func(ctx *Context) any {
+		return ctx.Temp["response-packs"]
+	},
+	"points": func(ctx *Context) any {
+		return ctx.Temp["response-points"]
+	},
+	"page": func(ctx *Context) any {
+		return ctx.Temp["response-page"]
+	},
+	"pages": func(ctx *Context) any {
+		return ctx.Temp["response-pages"]
+	},
+}
+
+var tts_placeholders = map[string]PlaceholderFunc{
+	"points": func(ctx *Context) any {
+		return ctx.Temp["response-points"]
+	},
+	"max": func(ctx *Context) any {
+		return ctx.Temp["response-max"]
+	},
+}
+
+var cooldown_placeholders = map[string]PlaceholderFunc{
+	"remaining": func(ctx *Context) any {
+		value, ok := ctx.Temp["response-remaining"]
+		if !ok {
+			return 0
+		}
+		return value
+	},
+}
+
+// e.g. "2h 5m", or "5m 3s" within the first hour
+func format_duration(duration time.Duration) string {
+	hours, minutes, seconds := int(duration.Hours()), int(duration.Minutes())%60, int(duration.Seconds())%60
+	if hours > 0 {
+		return fmt.Sprintf("%dh %dm", hours, minutes)
+	}
+	return fmt.Sprintf("%dm %ds", minutes, seconds)
+}
+
+// e.g. "1y 12d", or "12d" within the first year
+func format_age(since time.Time) string {
+	age := time.Since(since)
+	days := int(age.Hours() / 24)
+	switch {
+	case days == 0:
+		return format_duration(age)
+	case days < 365:
+		return fmt.Sprintf("%dd", days)
+	}
+	return fmt.Sprintf("%dy %dd", days/365, days%365)
+}
+
 //////////////////////
 //    PROCESSING    //
 //////////////////////
@@ -54,7 +178,9 @@ func (r *Context) process_placeholders(in string, placeholders map[string]Placeh
 		}
 	}
 
-	for _, placeholder := range population {
+	// replaced from the last, as replacing changes the indices of those after
+	for index := len(population) - 1; index >= 0; index-- {
+		placeholder := population[index]
 		function := placeholders[placeholder.Content]
 		if function == nil {
 			continue
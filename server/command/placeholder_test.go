@@ -0,0 +1,23 @@
+package command
+
+import "testing"
+
+func TestPlaceholdersReplaced(t *testing.T) {
+	ctx := Context{registry: &Registry{}, Temp: map[string]any{
+		"response-page":   1,
+		"response-pages":  12,
+		"response-sounds": "honk (10), boing (25)",
+		"response-link":   "http://localhost:9998/sounds",
+	}}
+
+	for message, expected := range map[string]string{
+		"Sounds ({page}/{pages}): {sounds} | {link}": "Sounds (1/12): honk (10), boing (25) | http://localhost:9998/sounds",
+		"{pages}{page}{pages}":                       "12112",
+		"{unknown} {page}":                           "{unknown} 1",
+		"no placeholders":                            "no placeholders",
+	} {
+		if rendered := ctx.render(message, sounds_placeholders); rendered != expected {
+			t.Errorf("%q rendered as %q, expected %q", message, rendered, expected)
+		}
+	}
+}
@@ -17,14 +17,13 @@ func points_no_arg(ctx Context) {
 		Where("id = ?", userId).
 		Scan(context.Background())
 
-	message := ctx.AppMessages().PointsNoArg
 	if err != nil {
-		ctx.ReplyExtra(message, points_placeholders)
+		ctx.ReplyExtra(ctx.PluralMessage("points_no_arg", 0), points_placeholders)
 		return
 	}
 
 	ctx.withResponsePoints(response.Points)
-	ctx.ReplyExtra(message, points_placeholders)
+	ctx.ReplyExtra(ctx.PluralMessage("points_no_arg", response.Points), points_placeholders)
 }
 
 func points_give(ctx Context) {
@@ -40,9 +39,11 @@ func points_give(ctx Context) {
 		},
 	)
 
-	ctx.CheckErr(err)
+	if !ctx.CheckErr(err) {
+		return
+	}
 	ctx.withResponsePoints(amount)
-	ctx.ReplyExtra(ctx.AppMessages().PointsGiveSuccess, points_placeholders)
+	ctx.ReplyExtra(ctx.PluralMessage("points_give_success", amount), points_placeholders)
 }
 
 func points_set(ctx Context) {
@@ -58,9 +59,11 @@ func points_set(ctx Context) {
 		},
 	)
 
-	ctx.CheckErr(err)
+	if !ctx.CheckErr(err) {
+		return
+	}
 	ctx.withResponsePoints(amount)
-	ctx.ReplyExtra(ctx.AppMessages().PointsSetSuccess, points_placeholders)
+	ctx.ReplyExtra(ctx.PluralMessage("points_set_success", amount), points_placeholders)
 }
 
 func NewPointsCommand() PrimaryCommand {
@@ -1,21 +1,25 @@
 package command
 
 import (
+	"context"
 	"errors"
 	"strings"
+	"time"
 
 	"github.com/imoliwer/sound-point-twitch-bot/server/request"
 	"github.com/imoliwer/sound-point-twitch-bot/server/util"
 )
 
+// how long looking up a user may take, including waiting out the rate limit
+const lookup_timeout = 10 * time.Second
+
 func check_user_and_amount(ctx *Context) bool {
-	messages := ctx.AppMessages()
 	switch len(ctx.Arguments) {
 	case 0:
-		ctx.Reply(messages.SpecifyUser)
+		ctx.Reply(ctx.Message("specify_user"))
 		return false
 	case 1:
-		ctx.Reply(messages.SpecifyAmount)
+		ctx.Reply(ctx.Message("specify_amount"))
 		return false
 	}
 	return true
@@ -25,7 +29,7 @@ func amount_of(ctx *Context, allowZero bool) (uint64, error) {
 	amount, err := util.Uint64(ctx.Arguments[1])
 
 	if err != nil || (!allowZero && amount == 0) {
-		ctx.Reply(ctx.AppMessages().MustSpecifyValidAmount)
+		ctx.Reply(ctx.Message("must_specify_valid_amount"))
 		return 0, errors.New("invalid amount")
 	}
 	return amount, nil
@@ -33,17 +37,26 @@ func amount_of(ctx *Context, allowZero bool) (uint64, error) {
 
 func user_of(ctx *Context) (string, uint64, error) {
 	var userId uint64
-	username := strings.ToLower(ctx.Arguments[0])
+	username := request.NormalizedLogin(ctx.Arguments[0])
 
-	if username == strings.ToLower(ctx.State.User.DisplayName) {
+	if username == ctx.State.User.Login || username == strings.ToLower(ctx.State.User.DisplayName) {
 		userId, _ = util.Uint64(ctx.State.User.Id)
 	} else {
-		userBy := request.TwitchUserBy(username)
+		lookup, cancel := context.WithTimeout(context.Background(), lookup_timeout)
+		defer cancel()
+
+		userBy, err := request.Users.ByLogin(lookup, username)
+		if err != nil {
+			util.Log("Commands", "Could not look up '%s': %s", username, err)
+			ctx.Reply(ctx.Message("operation_failed"))
+			return username, 0, err
+		}
+
 		if userBy == nil {
-			ctx.Reply(ctx.AppMessages().CouldNotFindUser)
+			ctx.ReplyExtra(ctx.Message("could_not_find_user"), points_placeholders)
 			return username, 0, errors.New("user not found")
 		}
-		userId, _ = util.Uint64(userBy.Id)
+		userId, _ = util.Uint64(userBy.ID)
 	}
 	return username, userId, nil
 }
@@ -0,0 +1,140 @@
+package command
+
+import (
+	"context"
+	"strings"
+
+	"github.com/imoliwer/sound-point-twitch-bot/server/model"
+	"github.com/imoliwer/sound-point-twitch-bot/server/permission"
+	"github.com/imoliwer/sound-point-twitch-bot/server/sound"
+	"github.com/imoliwer/sound-point-twitch-bot/server/util"
+)
+
+// NewSoundCommand plays a sound from chat, paid for with the points of the bot rather than channel points,
+// at the price it currently has for the chatter. Naming a group plays one of its sounds available,
+// while sounds of packs are only played for those who unlocked them.
+func NewSoundCommand(cover *sound.DeploymentCover, cooldowns *sound.Cooldowns, packs *sound.Packs) PrimaryCommand {
+	return PrimaryCommand{
+		Command: Command{
+			Requirements: make([]UserRequirement, 0),
+			Execute: func(ctx Context) {
+				if len(ctx.Arguments) == 0 {
+					ctx.Reply(ctx.Message("specify_sound"))
+					return
+				}
+
+				name := strings.ToLower(ctx.Arguments[0])
+				ctx.Temp["response-sound"] = name
+
+				user := &ctx.State.User
+				audio := ctx.Client.App.Settings().Audio
+				if group, ok := audio.Groups[name]; ok {
+					picked, ok := cover.Groups.Pick(name, group, func(member string) bool {
+						reference, ok := audio.References[member]
+						permitted, _ := packs.Permits(user.Id, member)
+						return ok && reference.IsEnabled() && permitted &&
+							permission.Permits(permission.OfState(user), reference.Permission) &&
+							cooldowns.Remaining(member, user.Id) == 0 &&
+							(reference.MaxPlaysPerStream == 0 || cover.Plays.Of(member) < reference.MaxPlaysPerStream)
+					})
+					if !ok {
+						ctx.ReplyExtra(ctx.Message("sound_group_unavailable"), sound_placeholders)
+						return
+					}
+					name = picked
+					ctx.Temp["response-sound"] = name
+				}
+
+				reference, ok := audio.References[name]
+				if !ok || !reference.IsEnabled() {
+					ctx.ReplyExtra(ctx.Message("sound_not_found"), sound_placeholders)
+					return
+				}
+
+				if permitted, unlocking := packs.Permits(user.Id, name); !permitted {
+					ctx.Temp["response-packs"] = strings.Join(unlocking, ", ")
+					ctx.ReplyExtra(ctx.Message("sound_locked"), sound_placeholders)
+					return
+				}
+
+				if !permission.Permits(permission.OfState(user), reference.Permission) {
+					ctx.ReplyExtra(ctx.Message("sound_not_permitted"), sound_placeholders)
+					return
+				}
+
+				lookup, cancel := context.WithTimeout(context.Background(), lookup_timeout)
+				defer cancel()
+
+				if tracker := ctx.registry.engagement; tracker != nil {
+					meets, err := tracker.Meets(lookup, user.Id, reference.Requirements)
+					if err != nil {
+						util.Log("Commands", "Could not check the requirements of %s: %s", user.Login, err)
+						ctx.Reply(ctx.Message("operation_failed"))
+						return
+					}
+					if !meets {
+						ctx.Reply(ctx.Message("requirements_not_met"))
+						return
+					}
+				}
+
+				userId, err := util.Uint64(user.Id)
+				if !ctx.CheckErr(err) {
+					return
+				}
+
+				// the cooldowns and the play are reserved at once, so plays at the same time can't both pass
+				reservation, remaining, ok := cooldowns.Reserve(name, user.Id)
+				if !ok {
+					seconds := util.WholeSeconds(remaining)
+					ctx.Temp["response-remaining"] = seconds
+					ctx.ReplyExtra(ctx.PluralMessage("sound_on_cooldown", seconds), sound_placeholders)
+					return
+				}
+
+				// priced as counted, as the first play of a stream may be free
+				played, ok := cover.Plays.Attempt(name, reference.MaxPlaysPerStream)
+				if !ok {
+					cooldowns.Release(reservation)
+					ctx.ReplyExtra(ctx.Message("sound_played_out"), sound_placeholders)
+					return
+				}
+				price := cover.PriceOfPlay(name, reference, user.SubscriptionTier(), played)
+
+				ctx.Temp["response-points"] = price
+				spent, err := model.SpendPoints(lookup, ctx.Client.App.Database, userId, price)
+				if err != nil || !spent {
+					cover.Plays.Undo(name)
+					cooldowns.Release(reservation)
+					if !ctx.CheckErr(err) {
+						return
+					}
+					ctx.ReplyExtra(ctx.PluralMessage("sound_not_enough_points", price), sound_placeholders)
+					return
+				}
+
+				if err := cover.Deploy(sound.NewRealDeployment(ctx.Client.App, name, reference, price, user)); err != nil {
+					cover.Plays.Undo(name)
+					cooldowns.Release(reservation)
+					if err := model.AddPoints(context.Background(), ctx.Client.App.Database, userId, price); err != nil {
+						util.Log("Commands", "Could not refund %d points to %s: %s", price, user.Login, err)
+					}
+					ctx.ReplyExtra(ctx.Message("sound_unavailable"), sound_placeholders)
+					return
+				}
+
+				cover.Played(name, reference)
+				if err := cooldowns.Keep(context.Background(), reservation); err != nil {
+					util.Log("Commands", "Could not save the cooldowns of '%s': %s", name, err)
+				}
+
+				if price == 0 {
+					ctx.ReplyExtra(ctx.Message("sound_played_free"), sound_placeholders)
+					return
+				}
+				ctx.ReplyExtra(ctx.PluralMessage("sound_played", price), sound_placeholders)
+			},
+		},
+		Children: map[string]Command{},
+	}
+}
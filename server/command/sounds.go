@@ -0,0 +1,131 @@
+package command
+
+import (
+	"fmt"
+	"net/url"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/imoliwer/sound-point-twitch-bot/server/sound"
+)
+
+// the most characters twitch allows within a single chat message
+const max_message_length = 500
+
+// the most characters of a search term echoed (and linked), leaving room for the sounds
+const max_search_length = 50
+
+// the fewest characters of entries listed per page, however much surrounds them
+const min_page_length = 100
+
+// NewSoundsCommand lists the sounds within chat, a page at a time or those matching a search term,
+// linking to the public page (under said page url) listing them all. Sounds are listed at the price they currently
+// have for the chatter, those on cooldown telling how long.
+func NewSoundsCommand(cover *sound.DeploymentCover, cooldowns *sound.Cooldowns, pageURL string) PrimaryCommand {
+	return PrimaryCommand{
+		Command: Command{
+			Requirements: make([]UserRequirement, 0),
+			Execute: func(ctx Context) {
+				page, search := 1, strings.Join(ctx.Arguments, " ")
+				if number, err := strconv.Atoi(search); err == nil {
+					page, search = number, ""
+				}
+
+				echoed := truncated(search, max_search_length)
+				link := strings.TrimSuffix(pageURL, "/") + sound.PublicPath
+				if echoed != "" {
+					link += "?q=" + url.QueryEscape(strings.TrimSuffix(echoed, "…"))
+				}
+				ctx.Temp["response-search"] = echoed
+				ctx.Temp["response-link"] = link
+
+				sounds := sound.PublicSounds(ctx.Client.App, cover, cooldowns, search, &ctx.State.User)
+				if len(sounds) == 0 {
+					if search == "" {
+						ctx.ReplyExtra(ctx.Message("sounds_none"), sounds_placeholders)
+					} else {
+						ctx.ReplyExtra(ctx.Message("sounds_not_found"), sounds_placeholders)
+					}
+					return
+				}
+
+				entries := make([]string, len(sounds))
+				for index, listed := range sounds {
+					if listed.CooldownRemaining > 0 {
+						entries[index] = fmt.Sprintf("%s (%d, %ds)", listed.Name, listed.EffectivePrice, listed.CooldownRemaining)
+						continue
+					}
+					entries[index] = fmt.Sprintf("%s (%d)", listed.Name, listed.EffectivePrice)
+				}
+
+				message := ctx.Message("sounds_list")
+				if search != "" {
+					message = ctx.Message("sounds_search")
+				}
+
+				pages := pages_within(ctx, message, sounds_placeholders, "response-sounds", entries)
+				if page < 1 || page > len(pages) {
+					ctx.ReplyExtra(ctx.PluralMessage("sounds_page_not_found", uint64(len(pages))), sounds_placeholders)
+					return
+				}
+
+				ctx.Temp["response-page"] = page
+				ctx.Temp["response-sounds"] = strings.Join(pages[page-1], ", ")
+				ctx.ReplyExtra(message, sounds_placeholders)
+			},
+		},
+		Children: map[string]Command{},
+	}
+}
+
+//////////////////////
+// HELPER FUNCTIONS //
+//////////////////////
+
+// said text cut to said length in characters, if longer
+func truncated(text string, length int) string {
+	if utf8.RuneCountInString(text) <= length {
+		return text
+	}
+	return string([]rune(text)[:length-1]) + "…"
+}
+
+// split said entries into pages fitting a reply of said message, which lists them under said key,
+// setting the number of pages for it to tell
+func pages_within(ctx Context, message string, placeholders map[string]PlaceholderFunc, key string, entries []string) [][]string {
+	// whatever surrounds the entries takes from the length allowed, the page numbers at their widest
+	ctx.Temp[key] = ""
+	ctx.Temp["response-page"], ctx.Temp["response-pages"] = len(entries), len(entries)
+
+	// though always leaving room for some, should a customised message (or the link of sounds) be lengthy
+	length := max_message_length - utf8.RuneCountInString(ctx.render(message, placeholders))
+	if length < min_page_length {
+		length = min_page_length
+	}
+
+	pages := pages_of(entries, length)
+	ctx.Temp["response-pages"] = len(pages)
+	return pages
+}
+
+// split said entries into pages, each within said length once joined (but for an entry too long on its own)
+func pages_of(entries []string, length int) [][]string {
+	pages := make([][]string, 0)
+	current, taken := make([]string, 0), 0
+
+	for _, entry := range entries {
+		size := utf8.RuneCountInString(entry)
+		if len(current) > 0 && taken+len(", ")+size > length {
+			pages = append(pages, current)
+			current, taken = make([]string, 0), 0
+		}
+
+		if len(current) > 0 {
+			taken += len(", ")
+		}
+		current = append(current, entry)
+		taken += size
+	}
+	return append(pages, current)
+}
@@ -0,0 +1,74 @@
+package command
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+	"unicode/utf8"
+)
+
+func TestPagesOf(t *testing.T) {
+	exact := []string{strings.Repeat("a", 248), strings.Repeat("b", 250)} // 500 once joined
+
+	for name, test := range map[string]struct {
+		entries []string
+		length  int
+		pages   int
+	}{
+		"nothing":                 {[]string{}, max_message_length, 1},
+		"exactly the length":      {exact, max_message_length, 1},
+		"a rune beyond":           {append(exact[:1:1], exact[1]+"c"), max_message_length, 2},
+		"multibyte runes counted": {[]string{strings.Repeat("ä", 248), strings.Repeat("ö", 250)}, max_message_length, 1},
+		"an entry too long alone": {[]string{"short", strings.Repeat("a", 600), "short"}, max_message_length, 3},
+	} {
+		pages := pages_of(test.entries, test.length)
+		if len(pages) != test.pages {
+			t.Errorf("%s: %d pages, expected %d", name, len(pages), test.pages)
+		}
+
+		var joined []string
+		for _, page := range pages {
+			joined = append(joined, page...)
+		}
+		if strings.Join(joined, ",") != strings.Join(test.entries, ",") {
+			t.Errorf("%s: entries not kept in order across pages", name)
+		}
+	}
+}
+
+func TestPagesWithinMessage(t *testing.T) {
+	entries := make([]string, 200)
+	for index := range entries {
+		entries[index] = fmt.Sprintf("sound%03d (%d)", index, index*25)
+	}
+
+	for name, test := range map[string]struct {
+		link string
+		most int // of the reply for the short link, of the entries for the lengthy one
+	}{
+		"short link":   {"http://localhost:9998/sounds", max_message_length},
+		"lengthy link": {"http://localhost:9998/sounds?q=" + strings.Repeat("x", 450), min_page_length},
+	} {
+		ctx := Context{registry: &Registry{}, Temp: map[string]any{"response-link": test.link}}
+		message := "Sounds ({page}/{pages}): {sounds} | All of them: {link}"
+		pages := pages_within(ctx, message, sounds_placeholders, "response-sounds", entries)
+
+		if ctx.Temp["response-pages"] != len(pages) {
+			t.Errorf("%s: told %v pages, expected %d", name, ctx.Temp["response-pages"], len(pages))
+		}
+
+		for index, page := range pages {
+			listed := strings.Join(page, ", ")
+			ctx.Temp["response-page"] = index + 1
+			ctx.Temp["response-sounds"] = listed
+
+			length := utf8.RuneCountInString(ctx.render(message, sounds_placeholders))
+			if test.most == min_page_length {
+				length = utf8.RuneCountInString(listed)
+			}
+			if length > test.most {
+				t.Errorf("%s: page %d is %d runes long, expected at most %d", name, index+1, length, test.most)
+			}
+		}
+	}
+}
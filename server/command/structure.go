@@ -3,9 +3,15 @@ package command
 import (
 	"context"
 	"database/sql"
+	"fmt"
+	"net/http"
 	"strings"
+	"time"
 
+	"github.com/gin-gonic/gin"
 	"github.com/imoliwer/sound-point-twitch-bot/server/app"
+	"github.com/imoliwer/sound-point-twitch-bot/server/engagement"
+	"github.com/imoliwer/sound-point-twitch-bot/server/locale"
 	"github.com/imoliwer/sound-point-twitch-bot/server/model"
 	"github.com/imoliwer/sound-point-twitch-bot/server/twitch_irc"
 	"github.com/imoliwer/sound-point-twitch-bot/server/util"
@@ -39,6 +45,8 @@ type Registry struct {
 	commands     map[string]PrimaryCommand
 	readyForNext bool
 	placeholders map[string]PlaceholderFunc
+	cooldowns    *CooldownTracker
+	engagement   *engagement.Tracker
 	Prefix       rune
 }
 
@@ -51,6 +59,7 @@ func NewRegistry(prefix rune, initialCmds map[string]PrimaryCommand, placeholder
 		commands:     make(map[string]PrimaryCommand),
 		readyForNext: true,
 		placeholders: placeholders,
+		cooldowns:    NewCooldownTracker(),
 	}
 	for key, cmd := range initialCmds {
 		registry.Include(key, cmd) // this is used only for the loggings and lowered names
@@ -84,9 +93,44 @@ func (r *Registry) IsReadyForNext() bool {
 	return r.readyForNext
 }
 
+// the prefix of the (possibly reloaded) settings takes precedence over the one given initially
+func (r *Registry) prefix_of(settings app.TwitchCommandSettings) rune {
+	if prefix := []rune(settings.Prefix); len(prefix) == 1 {
+		return prefix[0]
+	}
+	return r.Prefix
+}
+
+// UseEngagement checks the requirements of the commands against said tracker.
+func (r *Registry) UseEngagement(tracker *engagement.Tracker) {
+	r.engagement = tracker
+}
+
+func (r *Registry) Cooldowns() *CooldownTracker {
+	return r.cooldowns
+}
+
+func (r *Registry) Handler(engine *gin.Engine) {
+	engine.GET("/commands/cooldowns", func(ctx *gin.Context) {
+		ctx.JSON(http.StatusOK, r.cooldowns.State())
+	})
+
+	engine.DELETE("/commands/cooldowns", func(ctx *gin.Context) {
+		name := strings.ToLower(ctx.Query("command"))
+		if name == "" {
+			ctx.String(http.StatusBadRequest, "missing command")
+			return
+		}
+
+		r.cooldowns.Reset(name)
+		ctx.String(http.StatusOK, "cooldown has been reset")
+	})
+}
+
 func (r *Registry) DefaultHandler(client *twitch_irc.Client, state *twitch_irc.MessageState) {
+	commandSettings := client.App.Settings().TwitchBot.Command
 	raw := state.Text
-	if raw == "" || len(raw) == 1 || raw[0] != byte(r.Prefix) || !r.readyForNext { // ensure the client is ready for the next command as well
+	if raw == "" || len(raw) == 1 || raw[0] != byte(r.prefix_of(commandSettings)) || !r.readyForNext { // ensure the client is ready for the next command as well
 		return
 	}
 
@@ -95,7 +139,8 @@ func (r *Registry) DefaultHandler(client *twitch_irc.Client, state *twitch_irc.M
 	raw = strings.Join(strings.Fields(raw), " ")
 	raw = strings.TrimSuffix(raw[1:], "\r")
 	arguments := strings.Split(raw, " ")
-	name := strings.ToLower(arguments[0])
+	options := commandSettings.Options
+	name := resolve_primary(strings.ToLower(arguments[0]), r.commands, options)
 
 	command, ok := r.commands[name]
 	if !ok {
@@ -103,7 +148,7 @@ func (r *Registry) DefaultHandler(client *twitch_irc.Client, state *twitch_irc.M
 		return
 	}
 
-	primaryOption, ok := client.App.Settings.TwitchBot.Command.Options[name]
+	primaryOption, ok := options[name]
 	if !try_requirements(command.Command, client, state) || !ok || !primaryOption.Enabled {
 		r.MakeReady() // accept commands
 		return
@@ -113,7 +158,7 @@ func (r *Registry) DefaultHandler(client *twitch_irc.Client, state *twitch_irc.M
 	arguments = arguments[1:]
 
 	if len(children) > 0 && len(arguments) > 0 {
-		childName := strings.ToLower(arguments[0])
+		childName := resolve_child(strings.ToLower(arguments[0]), children, primaryOption.Arguments)
 		childCommand, ok := children[childName]
 
 		if !ok {
@@ -127,44 +172,111 @@ func (r *Registry) DefaultHandler(client *twitch_irc.Client, state *twitch_irc.M
 			return
 		}
 
-		try_exec(childCommand.Execute, Context{
+		childContext := Context{
 			Client:    client,
 			State:     state,
 			Arguments: arguments[1:],
 			registry:  r,
 			Temp:      make(map[string]any),
-		})
+		}
+
+		if r.try_gates(fmt.Sprintf("%s %s", name, childName), childOption, childContext) {
+			try_exec(childCommand.Execute, childContext)
+		}
 
 		r.MakeReady() // accept commands
 		return
 	}
 
-	try_exec(command.Execute, Context{
+	primaryContext := Context{
 		Client:    client,
 		State:     state,
 		Arguments: arguments,
 		registry:  r,
 		Temp:      make(map[string]any),
-	})
+	}
+
+	if r.try_gates(name, primaryOption.TwitchCommandOption, primaryContext) {
+		try_exec(command.Execute, primaryContext)
+	}
 
 	r.MakeReady() // accept commands
 }
 
+// the cooldown is checked before the requirements, so those not meeting them can't have them replied over and over,
+// yet only started once they're met
+func (r *Registry) try_gates(key string, option app.TwitchCommandOption, ctx Context) bool {
+	return r.try_cooldown(key, option.Cooldown, ctx, false) &&
+		r.try_engagement(option, ctx) &&
+		r.try_cooldown(key, option.Cooldown, ctx, true)
+}
+
+func (r *Registry) try_cooldown(key string, option app.TwitchCommandCooldown, ctx Context, start bool) bool {
+	if option.ModeratorBypass && ModRequirement(ctx.Client, &ctx.State.User) {
+		return true
+	}
+
+	var remaining time.Duration
+	if start {
+		remaining, _ = r.cooldowns.Attempt(key, ctx.State.ChannelName, ctx.State.User.Id, option)
+	} else {
+		remaining = r.cooldowns.Remaining(key, ctx.State.ChannelName, ctx.State.User.Id)
+	}
+	if remaining <= 0 {
+		return true
+	}
+
+	if option.Reply {
+		seconds := util.WholeSeconds(remaining)
+		ctx.Temp["response-remaining"] = seconds
+		ctx.ReplyExtra(ctx.PluralMessage("on_cooldown", seconds), cooldown_placeholders)
+	}
+	return false
+}
+
+func (r *Registry) try_engagement(option app.TwitchCommandOption, ctx Context) bool {
+	if r.engagement == nil || option.Requirements == nil {
+		return true
+	}
+
+	lookup, cancel := context.WithTimeout(context.Background(), lookup_timeout)
+	defer cancel()
+
+	meets, err := r.engagement.Meets(lookup, ctx.State.User.Id, option.Requirements)
+	if err != nil {
+		util.Log("Commands", "Could not check the requirements of %s: %s", ctx.State.User.Login, err)
+		ctx.Reply(ctx.Message("operation_failed"))
+		return false
+	}
+
+	if !meets {
+		ctx.Reply(ctx.Message("requirements_not_met"))
+	}
+	return meets
+}
+
 func (r Context) Reply(message string) {
 	r.ReplyExtra(message, nil)
 }
 
 func (r Context) ReplyExtra(message string, specificPlaceholders map[string]PlaceholderFunc) {
+	err := r.Client.ReplyTo(
+		r.State.Id,
+		r.State.ChannelName,
+		r.render(message, specificPlaceholders),
+	)
+	if err != nil {
+		util.Log("Commands", "Could not reply to %s: %s", r.State.User.Login, err)
+	}
+}
+
+// the message with its placeholders replaced, those specific taking precedence over the general ones
+func (r Context) render(message string, specificPlaceholders map[string]PlaceholderFunc) string {
 	placeholders := r.registry.placeholders
 	if specificPlaceholders != nil {
 		placeholders = util.MergeMaps(placeholders, specificPlaceholders)
 	}
-
-	r.Client.ReplyTo(
-		r.State.Id,
-		r.State.ChannelName,
-		r.process_placeholders(message, placeholders),
-	)
+	return r.process_placeholders(message, placeholders)
 }
 
 func (r Context) CheckErr(err error) bool {
@@ -172,7 +284,7 @@ func (r Context) CheckErr(err error) bool {
 		return true
 	}
 
-	r.Reply("An error occurred during the operation. Contact personel for further assistance in the matter.")
+	r.Reply(r.Message("operation_failed"))
 	return false
 }
 
@@ -188,8 +300,53 @@ func (r Context) ModifyUser(mod *model.User, setters map[string][]interface{}) (
 	return query.Exec(context.Background())
 }
 
-func (r Context) AppMessages() *app.TwitchCommandMessages {
-	return &r.Client.App.Settings.TwitchBot.Command.Messages
+func (r Context) Locale() string {
+	return r.Client.App.Settings().TwitchBot.Command.LocaleOf(r.State.ChannelName)
+}
+
+func (r Context) Message(id string) string {
+	if override, ok := r.Client.App.Settings().TwitchBot.Command.Messages[id]; ok && override.Form(locale.PluralOther) != "" {
+		return override.Form(locale.PluralOther)
+	}
+	return r.Client.App.Locales.Get(r.Locale(), id)
+}
+
+// overrides are chosen from by count too, as those of the catalog are
+func (r Context) PluralMessage(id string, count uint64) string {
+	if override, ok := r.Client.App.Settings().TwitchBot.Command.Messages[id]; ok {
+		if text := override.Form(locale.PluralOf(r.Locale(), count)); text != "" {
+			return text
+		}
+	}
+	return r.Client.App.Locales.Plural(r.Locale(), id, count)
+}
+
+// resolve said name into the name of a registered command, either directly or through its aliases
+func resolve_primary(name string, commands map[string]PrimaryCommand, options map[string]app.TwitchCommandPrimaryOption) string {
+	if _, ok := commands[name]; ok {
+		return name
+	}
+
+	for key, option := range options {
+		if option.HasAlias(name) {
+			return key
+		}
+	}
+	return name
+}
+
+// resolve said name into the name of a child command, either directly or through its aliases
+func resolve_child(name string, children map[string]Command, options map[string]app.TwitchCommandOption) string {
+	if _, ok := children[name]; ok {
+		return name
+	}
+
+	for key, option := range options {
+		if option.HasAlias(name) {
+			return key
+		}
+	}
+	return name
 }
 
 func try_requirements(cmd Command, client *twitch_irc.Client, state *twitch_irc.MessageState) bool {
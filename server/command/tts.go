@@ -0,0 +1,131 @@
+package command
+
+import (
+	"context"
+	"encoding/base64"
+	"errors"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/imoliwer/sound-point-twitch-bot/server/app"
+	"github.com/imoliwer/sound-point-twitch-bot/server/model"
+	"github.com/imoliwer/sound-point-twitch-bot/server/permission"
+	"github.com/imoliwer/sound-point-twitch-bot/server/sound"
+	"github.com/imoliwer/sound-point-twitch-bot/server/tts"
+	"github.com/imoliwer/sound-point-twitch-bot/server/twitch_irc"
+	"github.com/imoliwer/sound-point-twitch-bot/server/util"
+)
+
+// how long reading a message aloud may take
+const synthesis_timeout = 20 * time.Second
+
+// NewTTSCommand reads the message of a chatter aloud through the overlay, paid for with points by the character.
+func NewTTSCommand(cover *sound.DeploymentCover) PrimaryCommand {
+	return PrimaryCommand{
+		Command: Command{
+			Requirements: make([]UserRequirement, 0),
+			Execute: func(ctx Context) {
+				audio := ctx.Client.App.Settings().Audio
+				settings := audio.TTS
+				if !settings.Enabled {
+					ctx.Reply(ctx.Message("tts_disabled"))
+					return
+				}
+
+				user := &ctx.State.User
+				if !permission.Permits(permission.OfState(user), settings.Permission) {
+					ctx.Reply(ctx.Message("tts_not_permitted"))
+					return
+				}
+
+				text, err := tts.Filter(strings.Join(ctx.Arguments, " "), settings.AllowLinks, settings.BlockedWords)
+				switch {
+				case errors.Is(err, tts.ErrLink):
+					ctx.Reply(ctx.Message("tts_link"))
+					return
+				case errors.Is(err, tts.ErrProfanity):
+					ctx.Reply(ctx.Message("tts_profanity"))
+					return
+				case text == "":
+					ctx.Reply(ctx.Message("specify_message"))
+					return
+				}
+
+				characters := uint64(utf8.RuneCountInString(text))
+				if most := settings.MaxCharactersOf(); characters > most {
+					ctx.Temp["response-max"] = most
+					ctx.ReplyExtra(ctx.PluralMessage("tts_too_long", most), tts_placeholders)
+					return
+				}
+
+				userId, err := util.Uint64(user.Id)
+				if !ctx.CheckErr(err) {
+					return
+				}
+
+				price := settings.PriceOf(characters)
+				ctx.Temp["response-points"] = price
+
+				spent, err := model.SpendPoints(context.Background(), ctx.Client.App.Database, userId, price)
+				if !ctx.CheckErr(err) {
+					return
+				}
+				if !spent {
+					ctx.ReplyExtra(ctx.PluralMessage("tts_not_enough_points", price), tts_placeholders)
+					return
+				}
+
+				// synthesis takes a while, which mustn't hold up the commands of others
+				go func() {
+					synthesis, cancel := context.WithTimeout(context.Background(), synthesis_timeout)
+					defer cancel()
+
+					if err := tts_deploy(synthesis, cover, settings, audio.TargetLoudness, text, price, user); err != nil {
+						util.Log("Commands", "Could not read the message of %s aloud: %s", user.Login, err)
+						if err := model.AddPoints(context.Background(), ctx.Client.App.Database, userId, price); err != nil {
+							util.Log("Commands", "Could not refund %d points to %s: %s", price, user.Login, err)
+						}
+						ctx.Reply(ctx.Message("tts_unavailable"))
+						return
+					}
+					ctx.ReplyExtra(ctx.PluralMessage("tts_played", price), tts_placeholders)
+				}()
+			},
+		},
+		Children: map[string]Command{},
+	}
+}
+
+//////////////////////
+// HELPER FUNCTIONS //
+//////////////////////
+
+// read said text aloud and send it to the overlay, at the volume reaching the target loudness if any
+func tts_deploy(ctx context.Context, cover *sound.DeploymentCover, settings app.TTSSettings, target *float64, text string, price uint64, user *twitch_irc.UserState) error {
+	backend, err := tts.BackendOf(settings)
+	if err != nil {
+		return err
+	}
+
+	speech, err := backend.Synthesize(ctx, text)
+	if err != nil {
+		return err
+	}
+
+	var loudness *float64
+	if measured, err := sound.Loudness(speech); err == nil {
+		loudness = &measured
+	}
+
+	return cover.Deploy(sound.TTSDeployment{
+		GlobalDeployment: sound.GlobalDeployment{
+			Price:  price,
+			ID:     "tts",
+			Volume: app.PlaybackVolumeOf(settings.VolumePercent(), loudness, target),
+		},
+		Text:  text,
+		Audio: "data:audio/wav;base64," + base64.StdEncoding.EncodeToString(speech),
+		State: user,
+	})
+}
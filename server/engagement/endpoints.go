@@ -0,0 +1,102 @@
+package engagement
+
+import (
+	"context"
+	"net/http"
+	"strconv"
+	"sync"
+
+	"github.com/gin-gonic/gin"
+	"github.com/imoliwer/sound-point-twitch-bot/server/model"
+	"github.com/imoliwer/sound-point-twitch-bot/server/request"
+	"github.com/uptrace/bun"
+)
+
+const (
+	default_leaderboard_size = 10
+	max_leaderboard_size     = 100
+)
+
+// columns the leaderboard may be ordered by
+var leaderboard_columns = map[string]string{
+	"points":     "points",
+	"watch_time": "watch_seconds",
+	"messages":   "messages",
+}
+
+type LeaderboardEntry struct {
+	Rank         int    `json:"rank"`
+	ID           string `json:"id"`
+	Login        string `json:"login"`
+	DisplayName  string `json:"display_name"`
+	Points       uint64 `json:"points"`
+	WatchSeconds uint64 `json:"watch_seconds"`
+	Messages     uint64 `json:"messages"`
+}
+
+func (r *Tracker) Handler(engine *gin.Engine) {
+	engine.GET("/leaderboard", func(ctx *gin.Context) {
+		column, ok := leaderboard_columns[ctx.DefaultQuery("by", "points")]
+		if !ok {
+			ctx.String(http.StatusBadRequest, "must be ordered by either 'points', 'watch_time' or 'messages'")
+			return
+		}
+
+		size, err := strconv.Atoi(ctx.DefaultQuery("limit", strconv.Itoa(default_leaderboard_size)))
+		if err != nil || size < 1 || size > max_leaderboard_size {
+			ctx.String(http.StatusBadRequest, "limit must be within 1 and %d", max_leaderboard_size)
+			return
+		}
+
+		timeout, cancel := context.WithTimeout(ctx.Request.Context(), request_timeout)
+		defer cancel()
+
+		// message counts not yet flushed would otherwise be left out
+		if err := r.flush(timeout); err != nil {
+			ctx.String(http.StatusInternalServerError, "failed saving message counts")
+			return
+		}
+
+		var users []model.User
+		err = r.app.Database.
+			NewSelect().
+			Model(&users).
+			OrderExpr("? DESC", bun.Ident(column)).
+			OrderExpr("id ASC").
+			Limit(size).
+			Scan(timeout)
+
+		if err != nil {
+			ctx.String(http.StatusInternalServerError, "failed fetching leaderboard")
+			return
+		}
+		ctx.JSON(http.StatusOK, entries_of(timeout, users))
+	})
+}
+
+// the entries of said users in order, named by looking them up all at once
+func entries_of(ctx context.Context, users []model.User) []LeaderboardEntry {
+	entries := make([]LeaderboardEntry, len(users))
+
+	var group sync.WaitGroup
+	for index, user := range users {
+		entries[index] = LeaderboardEntry{
+			Rank:         index + 1,
+			ID:           strconv.FormatUint(user.ID, 10),
+			Points:       user.Points,
+			WatchSeconds: user.WatchSeconds,
+			Messages:     user.Messages,
+		}
+
+		group.Add(1)
+		go func(entry *LeaderboardEntry) {
+			defer group.Done()
+			if cached, err := request.Users.ByID(ctx, entry.ID); err == nil && cached != nil {
+				entry.Login, entry.DisplayName = cached.Login, cached.DisplayName
+			}
+		}(&entries[index])
+	}
+
+	group.Wait()
+	return entries
+}
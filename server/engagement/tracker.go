@@ -0,0 +1,289 @@
+package engagement
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/imoliwer/sound-point-twitch-bot/server/app"
+	"github.com/imoliwer/sound-point-twitch-bot/server/model"
+	"github.com/imoliwer/sound-point-twitch-bot/server/request"
+	"github.com/imoliwer/sound-point-twitch-bot/server/scheduler"
+	"github.com/imoliwer/sound-point-twitch-bot/server/util"
+)
+
+const (
+	// WatchInterval is how often the chatters are fetched, everyone present being credited with the time since.
+	WatchInterval = time.Minute
+	// the most credited at once, in case a fetch was delayed (e.g. by the rate limit)
+	max_credit      = 2 * WatchInterval
+	follow_lifetime = 10 * time.Minute
+	request_timeout = 30 * time.Second
+)
+
+// Engagement of a single user.
+type Engagement struct {
+	WatchTime  time.Duration
+	Messages   uint64
+	Following  bool
+	FollowedAt time.Time
+}
+
+type cached_follow struct {
+	following  bool
+	followedAt time.Time
+	fetchedAt  time.Time
+}
+
+// Tracker accumulates the watch time of everyone present in the chat while live, counts their messages
+// and looks up (and caches) when they followed.
+type Tracker struct {
+	app      *app.Application
+	mutex    sync.Mutex
+	messages map[uint64]uint64 // counted since the last flush
+	follows  map[string]cached_follow
+	polledAt time.Time
+	task     *scheduler.RepeatingTask
+}
+
+func NewTracker(application *app.Application) *Tracker {
+	return &Tracker{
+		app:      application,
+		messages: make(map[uint64]uint64),
+		follows:  make(map[string]cached_follow),
+	}
+}
+
+// Start credits the watch time (and flushes the message counts) every said interval until stopped.
+func (r *Tracker) Start(interval time.Duration) {
+	r.mutex.Lock()
+	r.polledAt = time.Now()
+	r.mutex.Unlock()
+
+	r.task = scheduler.Every(interval, func(_ *scheduler.RepeatingTask) {
+		r.poll()
+	})
+}
+
+// Stop stops crediting, flushing the message counts not yet flushed.
+func (r *Tracker) Stop() {
+	if r.task != nil {
+		r.task.Cancel()
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), request_timeout)
+	defer cancel()
+
+	if err := r.flush(ctx); err != nil {
+		util.Log("Engagement", "Could not save message counts: %s", err)
+	}
+}
+
+// Count counts a message sent by said user.
+func (r *Tracker) Count(userID string) {
+	id, err := util.Uint64(userID)
+	if err != nil {
+		return
+	}
+
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	r.messages[id]++
+}
+
+// Followed remembers said user to have followed at said time (e.g. as told by an event).
+func (r *Tracker) Followed(userID string, at time.Time) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	r.follows[userID] = cached_follow{following: true, followedAt: at, fetchedAt: time.Now()}
+}
+
+// FollowOf returns when said user followed the broadcaster, if following at all.
+func (r *Tracker) FollowOf(ctx context.Context, userID string) (time.Time, bool, error) {
+	r.mutex.Lock()
+	cached, ok := r.follows[userID]
+	r.mutex.Unlock()
+
+	if ok && time.Since(cached.fetchedAt) < follow_lifetime {
+		return cached.followedAt, cached.following, nil
+	}
+
+	follow, err := request.FollowOf(ctx, request.Profiles.Twitch.UserID(), userID)
+	if err != nil {
+		return time.Time{}, false, err
+	}
+
+	cached = cached_follow{following: follow != nil, fetchedAt: time.Now()}
+	if follow != nil {
+		cached.followedAt = follow.FollowedAt
+	}
+
+	r.mutex.Lock()
+	r.follows[userID] = cached
+	r.mutex.Unlock()
+	return cached.followedAt, cached.following, nil
+}
+
+// Of returns the engagement of said user, zero if never seen.
+func (r *Tracker) Of(ctx context.Context, userID string) (Engagement, error) {
+	engagement, err := r.recorded(ctx, userID)
+	if err != nil {
+		return Engagement{}, err
+	}
+
+	engagement.FollowedAt, engagement.Following, err = r.FollowOf(ctx, userID)
+	return engagement, err
+}
+
+// Meets returns whether said user meets said requirements, the broadcaster meeting any.
+func (r *Tracker) Meets(ctx context.Context, userID string, requirements *app.Requirements) (bool, error) {
+	if requirements == nil || *requirements == (app.Requirements{}) || userID == request.Profiles.Twitch.UserID() {
+		return true, nil
+	}
+
+	engagement, err := r.recorded(ctx, userID)
+	if err != nil {
+		return false, err
+	}
+
+	if engagement.WatchTime < time.Duration(requirements.WatchMinutes)*time.Minute || engagement.Messages < requirements.Messages {
+		return false, nil
+	}
+
+	if requirements.FollowDays == 0 {
+		return true, nil
+	}
+
+	followedAt, following, err := r.FollowOf(ctx, userID)
+	if err != nil {
+		return false, err
+	}
+	return following && time.Since(followedAt) >= time.Duration(requirements.FollowDays)*24*time.Hour, nil
+}
+
+//////////////////////
+// HELPER FUNCTIONS //
+//////////////////////
+
+// the watch time and messages of said user, including those not yet flushed
+func (r *Tracker) recorded(ctx context.Context, userID string) (Engagement, error) {
+	id, err := util.Uint64(userID)
+	if err != nil {
+		return Engagement{}, err
+	}
+
+	var user model.User
+	err = r.app.Database.NewSelect().Model(&user).Where("id = ?", id).Scan(ctx)
+	if err != nil && !errors.Is(err, sql.ErrNoRows) {
+		return Engagement{}, err
+	}
+
+	r.mutex.Lock()
+	pending := r.messages[id]
+	r.mutex.Unlock()
+
+	return Engagement{
+		WatchTime: time.Duration(user.WatchSeconds) * time.Second,
+		Messages:  user.Messages + pending,
+	}, nil
+}
+
+func (r *Tracker) poll() {
+	ctx, cancel := context.WithTimeout(context.Background(), request_timeout)
+	defer cancel()
+
+	r.mutex.Lock()
+	now := time.Now()
+
+	// credited in whole seconds, the remainder carrying over onto the next poll
+	elapsed := now.Sub(r.polledAt).Truncate(time.Second)
+	r.polledAt = r.polledAt.Add(elapsed)
+	if elapsed > max_credit {
+		elapsed, r.polledAt = max_credit, now
+	}
+
+	for id, cached := range r.follows {
+		if now.Sub(cached.fetchedAt) > follow_lifetime {
+			delete(r.follows, id)
+		}
+	}
+	r.mutex.Unlock()
+
+	if err := r.flush(ctx); err != nil {
+		util.Log("Engagement", "Could not save message counts: %s", err)
+	}
+
+	// only time spent watching the stream is credited
+	if elapsed == 0 || !r.app.Stream.Live() {
+		return
+	}
+
+	if err := r.credit(ctx, elapsed); err != nil {
+		util.Log("Engagement", "Could not credit watch time: %s", err)
+	}
+}
+
+func (r *Tracker) credit(ctx context.Context, elapsed time.Duration) error {
+	chatters, err := request.ChattersOf(ctx, request.Profiles.Twitch.UserID())
+	if err != nil {
+		return err
+	}
+
+	users := make([]model.User, 0, len(chatters))
+	for _, chatter := range chatters {
+		id, err := util.Uint64(chatter.UserID)
+		if err != nil {
+			continue
+		}
+
+		request.Users.Remember(request.CachedUser{ID: chatter.UserID, Login: chatter.UserLogin, DisplayName: chatter.UserName})
+		users = append(users, model.User{ID: id, WatchSeconds: uint64(elapsed / time.Second)})
+	}
+
+	if len(users) == 0 {
+		return nil
+	}
+
+	_, err = r.app.Database.
+		NewInsert().
+		Model(&users).
+		On("CONFLICT (id) DO UPDATE").
+		Set("watch_seconds = watch_seconds + EXCLUDED.watch_seconds").
+		Exec(ctx)
+	return err
+}
+
+func (r *Tracker) flush(ctx context.Context) error {
+	r.mutex.Lock()
+	counted := r.messages
+	r.messages = make(map[uint64]uint64)
+	r.mutex.Unlock()
+
+	if len(counted) == 0 {
+		return nil
+	}
+
+	users := make([]model.User, 0, len(counted))
+	for id, messages := range counted {
+		users = append(users, model.User{ID: id, Messages: messages})
+	}
+
+	_, err := r.app.Database.
+		NewInsert().
+		Model(&users).
+		On("CONFLICT (id) DO UPDATE").
+		Set("messages = messages + EXCLUDED.messages").
+		Exec(ctx)
+
+	// counted anew if not saved, so none are lost
+	if err != nil {
+		r.mutex.Lock()
+		for id, messages := range counted {
+			r.messages[id] += messages
+		}
+		r.mutex.Unlock()
+	}
+	return err
+}
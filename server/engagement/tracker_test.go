@@ -0,0 +1,166 @@
+package engagement
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/imoliwer/sound-point-twitch-bot/server/app"
+	"github.com/imoliwer/sound-point-twitch-bot/server/mock"
+	"github.com/imoliwer/sound-point-twitch-bot/server/model"
+	"github.com/imoliwer/sound-point-twitch-bot/server/request"
+	"github.com/uptrace/bun"
+	"github.com/uptrace/bun/dialect/sqlitedialect"
+	"github.com/uptrace/bun/driver/sqliteshim"
+)
+
+func TestPollCreditsWholeSeconds(t *testing.T) {
+	helix := mock.NewHelix(mock.NewTwitchID("client", "secret"))
+	mock.UseHelix(t, helix, "streamer", "42", "moderator:read:chatters")
+	helix.Chat("42", request.TwitchUser{Id: "7", Login: "viewer"})
+
+	for name, test := range map[string]struct {
+		elapsed  time.Duration
+		credited uint64
+		carried  time.Duration
+	}{
+		"partial second carried over": {61500 * time.Millisecond, 61, 500 * time.Millisecond},
+		"less than a second":          {400 * time.Millisecond, 0, 400 * time.Millisecond},
+		"delayed beyond the most":     {10 * time.Minute, uint64(max_credit / time.Second), 0},
+	} {
+		tracker := tracker_of(t)
+		tracker.app.Stream.Online(time.Now())
+		tracker.polledAt = time.Now().Add(-test.elapsed)
+		tracker.poll()
+
+		if credited := user_of(t, tracker, 7).WatchSeconds; credited != test.credited {
+			t.Errorf("%s: credited %ds, expected %ds", name, credited, test.credited)
+		}
+		if carried := time.Since(tracker.polledAt); carried < test.carried || carried > test.carried+time.Second/10 {
+			t.Errorf("%s: carried %s over, expected %s", name, carried, test.carried)
+		}
+	}
+}
+
+func TestPollCreditsNothingWhileOffline(t *testing.T) {
+	helix := mock.NewHelix(mock.NewTwitchID("client", "secret"))
+	mock.UseHelix(t, helix, "streamer", "42", "moderator:read:chatters")
+	helix.Chat("42", request.TwitchUser{Id: "7", Login: "viewer"})
+
+	tracker := tracker_of(t)
+	tracker.polledAt = time.Now().Add(-time.Minute)
+	tracker.poll()
+
+	if credited := user_of(t, tracker, 7).WatchSeconds; credited != 0 {
+		t.Errorf("credited %ds while offline", credited)
+	}
+}
+
+func TestMessagesRequeuedUnlessSaved(t *testing.T) {
+	tracker := tracker_of(t)
+	for index := 0; index < 3; index++ {
+		tracker.Count("7")
+	}
+
+	ctx := context.Background()
+	if _, err := tracker.app.Database.NewDropTable().Model((*model.User)(nil)).Exec(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if err := tracker.flush(ctx); err == nil {
+		t.Fatal("flushed without a table to save into")
+	}
+	if pending := tracker.messages[7]; pending != 3 {
+		t.Fatalf("%d messages pending after failing to save, expected 3", pending)
+	}
+
+	if err := model.Migrate(ctx, tracker.app.Database); err != nil {
+		t.Fatal(err)
+	}
+	tracker.Count("7")
+	if err := tracker.flush(ctx); err != nil {
+		t.Fatal(err)
+	}
+
+	if messages := user_of(t, tracker, 7).Messages; messages != 4 {
+		t.Errorf("%d messages saved, expected 4", messages)
+	}
+	if len(tracker.messages) != 0 {
+		t.Errorf("%d users still pending once saved", len(tracker.messages))
+	}
+}
+
+func TestMeets(t *testing.T) {
+	helix := mock.NewHelix(mock.NewTwitchID("client", "secret"))
+	mock.UseHelix(t, helix, "streamer", "42", "moderator:read:followers")
+
+	tracker := tracker_of(t)
+	_, err := tracker.app.Database.NewInsert().Model(&[]model.User{
+		{ID: 7, WatchSeconds: 3600, Messages: 4},
+		{ID: 8, WatchSeconds: 3599, Messages: 5},
+	}).Exec(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tracker.Count("7") // pending, yet counted
+	tracker.Followed("7", time.Now().Add(-8*24*time.Hour))
+	helix.Follow("42", request.TwitchUser{Id: "8", Login: "lurker"}, time.Now().Add(-6*24*time.Hour))
+
+	for name, test := range map[string]struct {
+		userID       string
+		requirements *app.Requirements
+		meets        bool
+	}{
+		"nothing required":           {"9", nil, true},
+		"zero required":              {"9", &app.Requirements{}, true},
+		"broadcaster":                {"42", &app.Requirements{WatchMinutes: 60, FollowDays: 7}, true},
+		"watched exactly enough":     {"7", &app.Requirements{WatchMinutes: 60}, true},
+		"watched a second too short": {"8", &app.Requirements{WatchMinutes: 60}, false},
+		"messages including pending": {"7", &app.Requirements{Messages: 5}, true},
+		"too few messages":           {"7", &app.Requirements{Messages: 6}, false},
+		"followed long enough":       {"7", &app.Requirements{FollowDays: 7}, true},
+		"followed too recently":      {"8", &app.Requirements{FollowDays: 7}, false},
+		"not following":              {"9", &app.Requirements{FollowDays: 1}, false},
+	} {
+		meets, err := tracker.Meets(context.Background(), test.userID, test.requirements)
+		if err != nil {
+			t.Errorf("%s: %s", name, err)
+		} else if meets != test.meets {
+			t.Errorf("%s: meets is %t, expected %t", name, meets, test.meets)
+		}
+	}
+}
+
+//////////////////////
+// HELPER FUNCTIONS //
+//////////////////////
+
+func tracker_of(t *testing.T) *Tracker {
+	sqlDb, err := sql.Open(sqliteshim.ShimName, filepath.Join(t.TempDir(), "bot.db"))
+	if err != nil {
+		t.Fatalf("opening database: %s", err)
+	}
+
+	db := bun.NewDB(sqlDb, sqlitedialect.New())
+	t.Cleanup(func() { db.Close() })
+
+	if err := model.Migrate(context.Background(), db); err != nil {
+		t.Fatalf("migrating database: %s", err)
+	}
+
+	application := app.NewApplication(&app.Settings{}, nil, nil)
+	application.Database = db
+	return NewTracker(application)
+}
+
+func user_of(t *testing.T, tracker *Tracker, id uint64) model.User {
+	var user model.User
+	err := tracker.app.Database.NewSelect().Model(&user).Where("id = ?", id).Scan(context.Background())
+	if err != nil && !errors.Is(err, sql.ErrNoRows) {
+		t.Fatalf("selecting user %d: %s", id, err)
+	}
+	return user
+}
@@ -0,0 +1,162 @@
+package main
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/imoliwer/sound-point-twitch-bot/server/app"
+	"github.com/imoliwer/sound-point-twitch-bot/server/engagement"
+	"github.com/imoliwer/sound-point-twitch-bot/server/eventsub"
+	"github.com/imoliwer/sound-point-twitch-bot/server/model"
+	"github.com/imoliwer/sound-point-twitch-bot/server/request"
+	"github.com/imoliwer/sound-point-twitch-bot/server/reward"
+	"github.com/imoliwer/sound-point-twitch-bot/server/util"
+)
+
+const (
+	event_timeout = 30 * time.Second
+	// how long a follower isn't awarded again, as unfollowing and following again would award anew
+	follow_memory = 24 * time.Hour
+)
+
+// subscribe to the events of the broadcaster's channel, tracking the stream, awarding points for follows,
+// subscriptions and cheers and passing the redemptions on to the rewards manager
+func listen_for_events(application *app.Application, rewards *reward.Manager, tracker *engagement.Tracker) *eventsub.Client {
+	client := eventsub.NewClient()
+
+	// resolved whenever subscribing, as the id is only known once the token has been validated
+	broadcaster := func() map[string]string {
+		return map[string]string{"broadcaster_user_id": request.Profiles.Twitch.UserID()}
+	}
+	for _, kind := range []string{
+		eventsub.TypeSubscribe,
+		eventsub.TypeCheer,
+		eventsub.TypeRedemption,
+		eventsub.TypeStreamOnline,
+		eventsub.TypeStreamOffline,
+		eventsub.TypeChannelUpdate,
+		eventsub.TypeHypeTrainBegin,
+		eventsub.TypeHypeTrainProgress,
+		eventsub.TypeHypeTrainEnd,
+	} {
+		client.Subscribe(kind, broadcaster)
+	}
+
+	// following requires a moderator of the channel, the broadcaster being one of their own
+	client.Subscribe(eventsub.TypeFollow, func() map[string]string {
+		broadcasterID := request.Profiles.Twitch.UserID()
+		return map[string]string{"broadcaster_user_id": broadcasterID, "moderator_user_id": broadcasterID}
+	})
+
+	// followers are awarded once within the memory, those older being forgotten to not grow without bound
+	var followersMutex sync.Mutex
+	followers := make(map[string]time.Time)
+
+	eventsub.On(client, eventsub.TypeFollow, func(event eventsub.FollowEvent) {
+		tracker.Followed(event.UserID, event.FollowedAt)
+
+		followersMutex.Lock()
+		now := time.Now()
+		for userID, awardedAt := range followers {
+			if now.Sub(awardedAt) >= follow_memory {
+				delete(followers, userID)
+			}
+		}
+		_, awarded := followers[event.UserID]
+		if !awarded {
+			followers[event.UserID] = now
+		}
+		followersMutex.Unlock()
+
+		if !awarded {
+			award_points(application, event.User, application.Settings().EventPoints.Follow)
+		}
+	})
+
+	eventsub.On(client, eventsub.TypeSubscribe, func(event eventsub.SubscribeEvent) {
+		award_points(application, event.User, application.Settings().EventPoints.Subscribe)
+	})
+
+	eventsub.On(client, eventsub.TypeCheer, func(event eventsub.CheerEvent) {
+		if event.IsAnonymous {
+			return
+		}
+		award_points(application, event.User, event.Bits/100*application.Settings().EventPoints.CheerPer100Bits)
+	})
+
+	eventsub.On(client, eventsub.TypeRedemption, func(event eventsub.RedemptionEvent) {
+		ctx, cancel := context.WithTimeout(context.Background(), event_timeout)
+		defer cancel()
+
+		if err := rewards.Handle(ctx, event.Redemption()); err != nil {
+			util.Log("Rewards", "Could not handle redemption of '%s': %s", event.Reward.Title, err)
+		}
+	})
+
+	eventsub.On(client, eventsub.TypeStreamOnline, func(event eventsub.StreamOnlineEvent) {
+		application.Stream.Online(event.StartedAt)
+	})
+
+	eventsub.On(client, eventsub.TypeStreamOffline, func(_ eventsub.StreamOfflineEvent) {
+		application.Stream.Offline()
+	})
+
+	eventsub.On(client, eventsub.TypeChannelUpdate, func(event eventsub.ChannelUpdateEvent) {
+		application.Stream.Channel(event.Title, event.CategoryName)
+	})
+
+	// progress is pushed on every contribution, only the levels reached are told
+	var hypeMutex sync.Mutex
+	var hypeLevel uint64
+
+	eventsub.On(client, eventsub.TypeHypeTrainBegin, func(event eventsub.HypeTrainEvent) {
+		hypeMutex.Lock()
+		hypeLevel = event.Level
+		hypeMutex.Unlock()
+		util.Log("EventSub", "A hype train has begun at level %d.", event.Level)
+	})
+
+	eventsub.On(client, eventsub.TypeHypeTrainProgress, func(event eventsub.HypeTrainEvent) {
+		hypeMutex.Lock()
+		reached := event.Level > hypeLevel
+		if reached {
+			hypeLevel = event.Level
+		}
+		hypeMutex.Unlock()
+
+		if reached {
+			util.Log("EventSub", "The hype train has reached level %d.", event.Level)
+		}
+	})
+
+	eventsub.On(client, eventsub.TypeHypeTrainEnd, func(event eventsub.HypeTrainEvent) {
+		hypeMutex.Lock()
+		hypeLevel = 0
+		hypeMutex.Unlock()
+		util.Log("EventSub", "The hype train has ended at level %d.", event.Level)
+	})
+
+	// redemptions are only polled whilst not pushed to us
+	rewards.UsePush(client.Connected)
+	return client
+}
+
+// points are only awarded whilst live
+func award_points(application *app.Application, user eventsub.User, amount uint64) {
+	if amount == 0 || !application.Stream.Live() {
+		return
+	}
+
+	userID, err := util.Uint64(user.UserID)
+	if err != nil {
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), event_timeout)
+	defer cancel()
+
+	if err := model.AddPoints(ctx, application.Database, userID, amount); err != nil {
+		util.Log("EventSub", "Could not award %d points to %s: %s", amount, user.UserLogin, err)
+	}
+}
@@ -0,0 +1,353 @@
+package eventsub
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/gorilla/websocket"
+	"github.com/imoliwer/sound-point-twitch-bot/server/request"
+	"github.com/imoliwer/sound-point-twitch-bot/server/util"
+)
+
+const (
+	// DefaultURL is where twitch serves EventSub over WebSocket.
+	DefaultURL = "wss://eventsub.wss.twitch.tv/ws"
+	// how long the welcome message may take, twitch also requires subscribing within this time
+	welcome_timeout = 10 * time.Second
+	// leeway granted on top of the keepalive timeout announced by twitch
+	keepalive_margin    = 5 * time.Second
+	first_connect_delay = time.Second
+	max_connect_delay   = time.Minute
+	// how long a message id is remembered, as twitch may deliver a message more than once
+	seen_lifetime = 10 * time.Minute
+)
+
+type Metadata struct {
+	MessageID           string    `json:"message_id"`
+	MessageType         string    `json:"message_type"`
+	MessageTimestamp    time.Time `json:"message_timestamp"`
+	SubscriptionType    string    `json:"subscription_type,omitempty"`
+	SubscriptionVersion string    `json:"subscription_version,omitempty"`
+}
+
+type Session struct {
+	ID                      string    `json:"id"`
+	Status                  string    `json:"status"`
+	KeepaliveTimeoutSeconds uint64    `json:"keepalive_timeout_seconds"`
+	ReconnectURL            string    `json:"reconnect_url"`
+	ConnectedAt             time.Time `json:"connected_at"`
+}
+
+type Payload struct {
+	Session      *Session                      `json:"session,omitempty"`
+	Subscription *request.EventSubSubscription `json:"subscription,omitempty"`
+	Event        json.RawMessage               `json:"event,omitempty"`
+}
+
+// Message is what twitch sends over the websocket, its type told by the metadata.
+type Message struct {
+	Metadata Metadata `json:"metadata"`
+	Payload  Payload  `json:"payload"`
+}
+
+// Notification is an event of a type subscribed to.
+type Notification struct {
+	Subscription request.EventSubSubscription
+	Event        json.RawMessage
+	SentAt       time.Time
+}
+
+type wanted_subscription struct {
+	kind      string
+	condition func() map[string]string
+}
+
+// Client keeps a websocket session with EventSub, subscribing to every type of event wanted once welcomed
+// and dispatching their notifications to the handlers of said type.
+type Client struct {
+	URL        string
+	mutex      sync.Mutex
+	wanted     []wanted_subscription
+	handlers   map[string][]func(Notification)
+	revocation []func(request.EventSubSubscription)
+	session    Session
+	connected  bool
+	seen       map[string]time.Time
+}
+
+func NewClient() *Client {
+	return &Client{
+		URL:      DefaultURL,
+		handlers: make(map[string][]func(Notification)),
+		seen:     make(map[string]time.Time),
+	}
+}
+
+// Subscribe adds said type of event to those subscribed to every new session, its condition resolved anew
+// whenever subscribing (as e.g. the id of the broadcaster may not be known yet at first).
+func (r *Client) Subscribe(kind string, condition func() map[string]string) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	r.wanted = append(r.wanted, wanted_subscription{kind: kind, condition: condition})
+}
+
+// Handle registers said handler, called with every notification of said type of event.
+func (r *Client) Handle(kind string, handler func(Notification)) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	r.handlers[kind] = append(r.handlers[kind], handler)
+}
+
+// OnRevocation registers said handler, called whenever twitch revokes a subscription (e.g. as the
+// authorization has been revoked). The subscription is attempted anew regardless.
+func (r *Client) OnRevocation(handler func(request.EventSubSubscription)) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	r.revocation = append(r.revocation, handler)
+}
+
+// On registers said handler, called with the event of every notification of said type decoded into T.
+func On[T any](client *Client, kind string, handler func(T)) {
+	client.Handle(kind, func(notification Notification) {
+		var event T
+		if err := json.Unmarshal(notification.Event, &event); err != nil {
+			util.Log("EventSub", "Could not decode '%s' event: %s", kind, err)
+			return
+		}
+		handler(event)
+	})
+}
+
+// Connected returns whether a session is currently established.
+func (r *Client) Connected() bool {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	return r.connected
+}
+
+// Run keeps a session established until said context is done, connecting anew with backoff whenever lost.
+func (r *Client) Run(ctx context.Context) {
+	delay := first_connect_delay
+	for ctx.Err() == nil {
+		welcomed, err := r.run_session(ctx)
+		if ctx.Err() != nil {
+			return
+		}
+
+		if welcomed {
+			delay = first_connect_delay
+		}
+
+		util.Log("EventSub", "Session lost, connecting again in %s: %s", delay, err)
+		select {
+		case <-time.After(delay):
+		case <-ctx.Done():
+			return
+		}
+
+		if delay *= 2; delay > max_connect_delay {
+			delay = max_connect_delay
+		}
+	}
+}
+
+//////////////////////
+// HELPER FUNCTIONS //
+//////////////////////
+
+// establish a session and read from it until lost, returning whether it was welcomed at all
+func (r *Client) run_session(ctx context.Context) (bool, error) {
+	conn, session, err := dial(ctx, r.URL)
+	if err != nil {
+		return false, err
+	}
+
+	var current sync.Mutex // guards conn, which is replaced when twitch asks to reconnect
+	sessionCtx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	go func() {
+		<-sessionCtx.Done()
+		current.Lock()
+		conn.Close()
+		current.Unlock()
+	}()
+
+	r.set_session(session, true)
+	defer r.set_session(Session{}, false)
+
+	util.Log("EventSub", "Session %s established.", session.ID)
+	r.mutex.Lock()
+	wanted := append([]wanted_subscription{}, r.wanted...)
+	r.mutex.Unlock()
+	go r.subscribe(sessionCtx, wanted)
+
+	for {
+		conn.SetReadDeadline(time.Now().Add(time.Duration(session.KeepaliveTimeoutSeconds)*time.Second + keepalive_margin))
+
+		var message Message
+		if err := conn.ReadJSON(&message); err != nil {
+			return true, err
+		}
+
+		switch message.Metadata.MessageType {
+		case "session_keepalive":
+		case "notification":
+			r.dispatch(message)
+		case "revocation":
+			r.revoke(sessionCtx, message)
+		case "session_reconnect":
+			if message.Payload.Session == nil {
+				continue
+			}
+
+			// the subscriptions carry over onto the new connection, which is welcomed before the old is closed
+			replacement, welcomed, err := dial(ctx, message.Payload.Session.ReconnectURL)
+			if err != nil {
+				return true, fmt.Errorf("reconnecting: %w", err)
+			}
+
+			current.Lock()
+			conn.Close()
+			conn, session = replacement, welcomed
+			current.Unlock()
+
+			r.set_session(session, true)
+			util.Log("EventSub", "Reconnected session %s.", session.ID)
+		}
+	}
+}
+
+func (r *Client) set_session(session Session, connected bool) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	r.session, r.connected = session, connected
+}
+
+// subscribe the current session to said events, retrying those failing with backoff until said context is done
+func (r *Client) subscribe(ctx context.Context, wanted []wanted_subscription) {
+	delay := first_connect_delay
+	for {
+		failed := make([]wanted_subscription, 0)
+		for _, subscription := range wanted {
+			r.mutex.Lock()
+			sessionID := r.session.ID
+			r.mutex.Unlock()
+
+			timeout, cancel := context.WithTimeout(ctx, welcome_timeout)
+			_, err := request.CreateEventSubSubscription(timeout, sessionID, subscription.kind, Versions[subscription.kind], subscription.condition())
+			cancel()
+
+			if ctx.Err() != nil {
+				return
+			}
+			if err != nil {
+				util.Log("EventSub", "Could not subscribe to '%s', retrying in %s: %s", subscription.kind, delay, err)
+				failed = append(failed, subscription)
+			}
+		}
+
+		if wanted = failed; len(wanted) == 0 {
+			return
+		}
+
+		select {
+		case <-time.After(delay):
+		case <-ctx.Done():
+			return
+		}
+
+		if delay *= 2; delay > max_connect_delay {
+			delay = max_connect_delay
+		}
+	}
+}
+
+func (r *Client) dispatch(message Message) {
+	if message.Payload.Subscription == nil {
+		return
+	}
+
+	r.mutex.Lock()
+	now := time.Now()
+	for id, seenAt := range r.seen {
+		if now.Sub(seenAt) > seen_lifetime {
+			delete(r.seen, id)
+		}
+	}
+
+	if _, duplicate := r.seen[message.Metadata.MessageID]; duplicate {
+		r.mutex.Unlock()
+		return
+	}
+	r.seen[message.Metadata.MessageID] = now
+
+	handlers := append([]func(Notification){}, r.handlers[message.Payload.Subscription.Type]...)
+	r.mutex.Unlock()
+
+	notification := Notification{
+		Subscription: *message.Payload.Subscription,
+		Event:        message.Payload.Event,
+		SentAt:       message.Metadata.MessageTimestamp,
+	}
+
+	// handled apart from the reading, which has to keep up with the keepalive
+	for _, handler := range handlers {
+		go handler(notification)
+	}
+}
+
+// tell the handlers of said revocation and subscribe to its type of event anew, within said session
+func (r *Client) revoke(ctx context.Context, message Message) {
+	subscription := message.Payload.Subscription
+	if subscription == nil {
+		return
+	}
+
+	util.Log("EventSub", "Subscription to '%s' was revoked, subscribing anew: %s", subscription.Type, subscription.Status)
+
+	r.mutex.Lock()
+	handlers := append([]func(request.EventSubSubscription){}, r.revocation...)
+	wanted := make([]wanted_subscription, 0, 1)
+	for _, candidate := range r.wanted {
+		if candidate.kind == subscription.Type {
+			wanted = append(wanted, candidate)
+		}
+	}
+	r.mutex.Unlock()
+
+	go r.subscribe(ctx, wanted)
+
+	for _, handler := range handlers {
+		handler(*subscription)
+	}
+}
+
+// connect to said url and await the welcome message
+func dial(ctx context.Context, url string) (*websocket.Conn, Session, error) {
+	timeout, cancel := context.WithTimeout(ctx, welcome_timeout)
+	defer cancel()
+
+	conn, _, err := websocket.DefaultDialer.DialContext(timeout, url, nil)
+	if err != nil {
+		return nil, Session{}, err
+	}
+
+	conn.SetReadDeadline(time.Now().Add(welcome_timeout))
+
+	var welcome Message
+	if err := conn.ReadJSON(&welcome); err != nil {
+		conn.Close()
+		return nil, Session{}, err
+	}
+
+	if welcome.Metadata.MessageType != "session_welcome" || welcome.Payload.Session == nil {
+		conn.Close()
+		return nil, Session{}, errors.New("expected a welcome message")
+	}
+	return conn, *welcome.Payload.Session, nil
+}
@@ -0,0 +1,135 @@
+package eventsub_test
+
+import (
+	"context"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/imoliwer/sound-point-twitch-bot/server/eventsub"
+	"github.com/imoliwer/sound-point-twitch-bot/server/mock"
+	"github.com/imoliwer/sound-point-twitch-bot/server/request"
+)
+
+func TestSubscribedOnceWelcomed(t *testing.T) {
+	standIn, client, follows := client_of(t, func() map[string]string {
+		return map[string]string{"broadcaster_user_id": request.Profiles.Twitch.UserID()}
+	})
+
+	eventually(t, "subscribed", func() bool { return len(standIn.Subscriptions()) == 1 })
+	if !client.Connected() {
+		t.Error("client tells it isn't connected")
+	}
+
+	follow(t, standIn)
+	eventually(t, "follow handled", func() bool { return atomic.LoadInt32(follows) == 1 })
+}
+
+func TestNotifiedAfterReconnecting(t *testing.T) {
+	standIn, _, follows := client_of(t, func() map[string]string {
+		return map[string]string{"broadcaster_user_id": request.Profiles.Twitch.UserID()}
+	})
+	eventually(t, "subscribed", func() bool { return len(standIn.Subscriptions()) == 1 })
+
+	if err := standIn.Reconnect(); err != nil {
+		t.Fatalf("asking to reconnect: %s", err)
+	}
+	time.Sleep(100 * time.Millisecond) // the old connection is closed once the new one is welcomed
+
+	follow(t, standIn)
+	eventually(t, "follow handled", func() bool { return atomic.LoadInt32(follows) == 1 })
+	if count := len(standIn.Subscriptions()); count != 1 {
+		t.Errorf("%d subscriptions after reconnecting, expected them carried over", count)
+	}
+}
+
+func TestSubscribedAnewOnceRevoked(t *testing.T) {
+	standIn, _, _ := client_of(t, func() map[string]string {
+		return map[string]string{"broadcaster_user_id": request.Profiles.Twitch.UserID()}
+	})
+	eventually(t, "subscribed", func() bool { return len(standIn.Subscriptions()) == 1 })
+
+	if err := standIn.Revoke(eventsub.TypeFollow, "authorization_revoked"); err != nil {
+		t.Fatalf("revoking: %s", err)
+	}
+	eventually(t, "subscribed anew", func() bool { return len(standIn.Subscriptions()) == 1 })
+}
+
+func TestConditionResolvedWhenSubscribing(t *testing.T) {
+	var known int32
+	standIn, _, _ := client_of(t, func() map[string]string {
+		if atomic.LoadInt32(&known) == 0 {
+			return map[string]string{"broadcaster_user_id": ""} // as if the token wasn't validated yet
+		}
+		return map[string]string{"broadcaster_user_id": request.Profiles.Twitch.UserID()}
+	})
+
+	time.Sleep(200 * time.Millisecond)
+	if count := len(standIn.Subscriptions()); count != 0 {
+		t.Fatalf("subscribed %d times without knowing the broadcaster", count)
+	}
+
+	atomic.StoreInt32(&known, 1)
+	eventually(t, "subscribed once known", func() bool { return len(standIn.Subscriptions()) == 1 })
+	if id := standIn.Subscriptions()[0].Condition["broadcaster_user_id"]; id != "42" {
+		t.Errorf("subscribed for broadcaster %q", id)
+	}
+}
+
+//////////////////////
+// HELPER FUNCTIONS //
+//////////////////////
+
+// a client subscribing to follows with said condition, connected to a stand-in until the test is done
+func client_of(t *testing.T, condition func() map[string]string) (*mock.EventSub, *eventsub.Client, *int32) {
+	helix := mock.NewHelix(mock.NewTwitchID("client", "secret"))
+	mock.UseHelix(t, helix, "streamer", "42", "moderator:read:followers")
+
+	standIn := mock.NewEventSub(10 * time.Second)
+	helix.UseEventSub(standIn)
+
+	client := eventsub.NewClient()
+	client.URL = standIn.URL()
+	client.Subscribe(eventsub.TypeFollow, condition)
+
+	follows := new(int32)
+	eventsub.On(client, eventsub.TypeFollow, func(event eventsub.FollowEvent) {
+		atomic.AddInt32(follows, 1)
+	})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		client.Run(ctx)
+		close(done)
+	}()
+
+	t.Cleanup(func() {
+		cancel()
+		<-done
+		standIn.Close()
+	})
+	return standIn, client, follows
+}
+
+func follow(t *testing.T, standIn *mock.EventSub) {
+	notified, err := standIn.Notify(eventsub.TypeFollow, eventsub.FollowEvent{
+		Broadcaster: eventsub.Broadcaster{BroadcasterUserID: "42"},
+		User:        eventsub.User{UserID: "7", UserLogin: "viewer"},
+		FollowedAt:  time.Now(),
+	})
+	if err != nil || notified != 1 {
+		t.Fatalf("notified %d sessions: %v", notified, err)
+	}
+}
+
+func eventually(t *testing.T, what string, condition func() bool) {
+	t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for !condition() {
+		if time.Now().After(deadline) {
+			t.Fatalf("timed out waiting until %s", what)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
@@ -0,0 +1,132 @@
+package eventsub
+
+import (
+	"time"
+
+	"github.com/imoliwer/sound-point-twitch-bot/server/request"
+)
+
+// subscription types in use
+const (
+	TypeFollow            = "channel.follow"
+	TypeSubscribe         = "channel.subscribe"
+	TypeCheer             = "channel.cheer"
+	TypeRedemption        = "channel.channel_points_custom_reward_redemption.add"
+	TypeStreamOnline      = "stream.online"
+	TypeStreamOffline     = "stream.offline"
+	TypeChannelUpdate     = "channel.update"
+	TypeHypeTrainBegin    = "channel.hype_train.begin"
+	TypeHypeTrainProgress = "channel.hype_train.progress"
+	TypeHypeTrainEnd      = "channel.hype_train.end"
+)
+
+// Versions of every subscription type in use.
+var Versions = map[string]string{
+	TypeFollow:            "2",
+	TypeSubscribe:         "1",
+	TypeCheer:             "1",
+	TypeRedemption:        "1",
+	TypeStreamOnline:      "1",
+	TypeStreamOffline:     "1",
+	TypeChannelUpdate:     "2",
+	TypeHypeTrainBegin:    "1",
+	TypeHypeTrainProgress: "1",
+	TypeHypeTrainEnd:      "1",
+}
+
+type Broadcaster struct {
+	BroadcasterUserID    string `json:"broadcaster_user_id"`
+	BroadcasterUserLogin string `json:"broadcaster_user_login"`
+	BroadcasterUserName  string `json:"broadcaster_user_name"`
+}
+
+type User struct {
+	UserID    string `json:"user_id"`
+	UserLogin string `json:"user_login"`
+	UserName  string `json:"user_name"`
+}
+
+type FollowEvent struct {
+	Broadcaster
+	User
+	FollowedAt time.Time `json:"followed_at"`
+}
+
+type SubscribeEvent struct {
+	Broadcaster
+	User
+	Tier   string `json:"tier"`
+	IsGift bool   `json:"is_gift"`
+}
+
+type CheerEvent struct {
+	Broadcaster
+	User               // empty when anonymous
+	IsAnonymous bool   `json:"is_anonymous"`
+	Message     string `json:"message"`
+	Bits        uint64 `json:"bits"`
+}
+
+type RedemptionEvent struct {
+	Broadcaster
+	User
+	ID         string                 `json:"id"`
+	UserInput  string                 `json:"user_input"`
+	Status     string                 `json:"status"`
+	Reward     request.RedeemedReward `json:"reward"`
+	RedeemedAt time.Time              `json:"redeemed_at"`
+}
+
+// Redemption converts the event into the redemption Helix would respond with.
+func (r RedemptionEvent) Redemption() request.Redemption {
+	return request.Redemption{
+		ID:            r.ID,
+		BroadcasterID: r.BroadcasterUserID,
+		UserID:        r.UserID,
+		UserLogin:     r.UserLogin,
+		UserName:      r.UserName,
+		UserInput:     r.UserInput,
+		Status:        r.Status,
+		Reward:        r.Reward,
+		RedeemedAt:    r.RedeemedAt,
+	}
+}
+
+type StreamOnlineEvent struct {
+	Broadcaster
+	ID        string    `json:"id"`
+	Type      string    `json:"type"`
+	StartedAt time.Time `json:"started_at"`
+}
+
+type StreamOfflineEvent struct {
+	Broadcaster
+}
+
+type ChannelUpdateEvent struct {
+	Broadcaster
+	Title        string `json:"title"`
+	Language     string `json:"language"`
+	CategoryID   string `json:"category_id"`
+	CategoryName string `json:"category_name"`
+}
+
+type HypeTrainContribution struct {
+	User
+	Type  string `json:"type"`
+	Total uint64 `json:"total"`
+}
+
+// HypeTrainEvent covers the begin, progress and end of a hype train, leaving out what doesn't apply.
+type HypeTrainEvent struct {
+	Broadcaster
+	ID               string                  `json:"id"`
+	Level            uint64                  `json:"level"`
+	Total            uint64                  `json:"total"`
+	Progress         uint64                  `json:"progress"`
+	Goal             uint64                  `json:"goal"`
+	TopContributions []HypeTrainContribution `json:"top_contributions"`
+	StartedAt        time.Time               `json:"started_at"`
+	ExpiresAt        time.Time               `json:"expires_at"`
+	EndedAt          time.Time               `json:"ended_at"`
+}
@@ -0,0 +1,233 @@
+package locale
+
+import (
+	"embed"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// Fallback is the locale used whenever a message is absent in the requested one.
+const Fallback = "en"
+
+//go:embed *.json
+var builtin embed.FS
+
+type Message struct {
+	Forms map[string]string
+}
+
+type Catalog struct {
+	locales map[string]map[string]Message
+}
+
+// NewMessage creates a message of said text, whatever the count.
+func NewMessage(text string) Message {
+	return Message{Forms: map[string]string{PluralOther: text}}
+}
+
+func (r *Message) UnmarshalJSON(data []byte) error {
+	var single string
+	if err := json.Unmarshal(data, &single); err == nil {
+		*r = NewMessage(single)
+		return nil
+	}
+
+	var forms map[string]string
+	if err := json.Unmarshal(data, &forms); err != nil {
+		return errors.New("message must be either a string or an object of plural forms")
+	}
+
+	if _, ok := forms[PluralOther]; !ok {
+		return fmt.Errorf("message is missing the '%s' plural form", PluralOther)
+	}
+	r.Forms = forms
+	return nil
+}
+
+// MarshalJSON writes a message without plural forms as a plain string, as it was read.
+func (r Message) MarshalJSON() ([]byte, error) {
+	if text, ok := r.Forms[PluralOther]; ok && len(r.Forms) == 1 {
+		return json.Marshal(text)
+	}
+	return json.Marshal(r.Forms)
+}
+
+// Form returns the text of said plural category, or the "other" form when absent.
+func (r Message) Form(category string) string {
+	if text, ok := r.Forms[category]; ok {
+		return text
+	}
+	return r.Forms[PluralOther]
+}
+
+// NewCatalog creates a catalog consisting of the built-in locales only.
+func NewCatalog() *Catalog {
+	catalog := &Catalog{locales: make(map[string]map[string]Message)}
+	entries, _ := fs.ReadDir(builtin, ".")
+
+	for _, entry := range entries {
+		content, _ := builtin.ReadFile(entry.Name())
+		if err := catalog.Include(strings.TrimSuffix(entry.Name(), ".json"), content); err != nil {
+			panic(err) // built-in locales are expected to be valid
+		}
+	}
+	return catalog
+}
+
+// Load creates a catalog of the built-in locales, extended by every "<locale>.json" file within said directory.
+// An absent directory is not treated as an error.
+func Load(directory string) (*Catalog, error) {
+	catalog := NewCatalog()
+	entries, err := os.ReadDir(directory)
+
+	if errors.Is(err, os.ErrNotExist) {
+		return catalog, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	for _, entry := range entries {
+		name := entry.Name()
+		if entry.IsDir() || filepath.Ext(name) != ".json" {
+			continue
+		}
+
+		content, err := os.ReadFile(filepath.Join(directory, name))
+		if err != nil {
+			return nil, err
+		}
+
+		if err := catalog.Include(strings.TrimSuffix(name, ".json"), content); err != nil {
+			return nil, fmt.Errorf("%s: %w", name, err)
+		}
+	}
+	return catalog, nil
+}
+
+// Include merges the messages of said json content into the given locale, replacing existing ones.
+func (r *Catalog) Include(locale string, content []byte) error {
+	var messages map[string]Message
+	if err := json.Unmarshal(content, &messages); err != nil {
+		return err
+	}
+
+	locale = normalize(locale)
+	existing, ok := r.locales[locale]
+	if !ok {
+		existing = make(map[string]Message)
+		r.locales[locale] = existing
+	}
+
+	for id, message := range messages {
+		existing[id] = message
+	}
+	return nil
+}
+
+// Has returns whether said locale is known to the catalog.
+func (r *Catalog) Has(locale string) bool {
+	_, ok := r.locales[normalize(locale)]
+	return ok
+}
+
+// Locales returns every known locale, sorted.
+func (r *Catalog) Locales() []string {
+	population := make([]string, 0, len(r.locales))
+	for locale := range r.locales {
+		population = append(population, locale)
+	}
+	sort.Strings(population)
+	return population
+}
+
+// IDs returns every message id of the fallback locale, sorted.
+func (r *Catalog) IDs() []string {
+	population := make([]string, 0)
+	for id := range r.locales[Fallback] {
+		population = append(population, id)
+	}
+	sort.Strings(population)
+	return population
+}
+
+// Get returns the message of said id within said locale, in its "other" form.
+func (r *Catalog) Get(locale string, id string) string {
+	for _, candidate := range candidates_of(locale) {
+		if message, ok := r.locales[candidate][id]; ok {
+			return message.Form(PluralOther)
+		}
+	}
+	return id
+}
+
+// Plural returns the message of said id within said locale, in the plural form matching said count.
+// Lookup happens through the exact locale, its base language and lastly the fallback locale.
+// The id itself is returned if none of them contain it.
+func (r *Catalog) Plural(locale string, id string, count uint64) string {
+	for _, candidate := range candidates_of(locale) {
+		if message, ok := r.locales[candidate][id]; ok {
+			return message.Form(PluralOf(candidate, count))
+		}
+	}
+	return id
+}
+
+func candidates_of(locale string) []string {
+	locale = normalize(locale)
+	population := make([]string, 0, 3)
+
+	if locale != "" {
+		population = append(population, locale)
+	}
+	if language := language_of(locale); language != locale && language != "" {
+		population = append(population, language)
+	}
+	return append(population, Fallback)
+}
+
+// Placeholders returns the placeholders (without braces) used by any form of said message within the fallback locale.
+func (r *Catalog) Placeholders(id string) []string {
+	message, ok := r.locales[Fallback][id]
+	if !ok {
+		return nil
+	}
+
+	population := make([]string, 0)
+	seen := make(map[string]bool)
+	for _, text := range message.Forms {
+		for _, placeholder := range PlaceholdersOf(text) {
+			if !seen[placeholder] {
+				seen[placeholder] = true
+				population = append(population, placeholder)
+			}
+		}
+	}
+	sort.Strings(population)
+	return population
+}
+
+// PlaceholdersOf returns every "{placeholder}" (without braces) within said text, in order of appearance.
+func PlaceholdersOf(text string) []string {
+	population := make([]string, 0)
+	start := -1
+
+	for index, char := range text {
+		switch char {
+		case '{':
+			start = index
+		case '}':
+			if start != -1 {
+				population = append(population, text[start+1:index])
+				start = -1
+			}
+		}
+	}
+	return population
+}
@@ -0,0 +1,103 @@
+package locale
+
+import "strings"
+
+// plural categories as defined by the unicode CLDR
+const (
+	PluralZero  = "zero"
+	PluralOne   = "one"
+	PluralTwo   = "two"
+	PluralFew   = "few"
+	PluralMany  = "many"
+	PluralOther = "other"
+)
+
+// PluralCategories are every plural category, in the order of the CLDR.
+var PluralCategories = []string{PluralZero, PluralOne, PluralTwo, PluralFew, PluralMany, PluralOther}
+
+type PluralRule = func(count uint64) string
+
+var plural_one_other PluralRule = func(count uint64) string {
+	if count == 1 {
+		return PluralOne
+	}
+	return PluralOther
+}
+
+var plural_one_includes_zero PluralRule = func(count uint64) string {
+	if count == 0 || count == 1 {
+		return PluralOne
+	}
+	return PluralOther
+}
+
+var plural_slavic PluralRule = func(count uint64) string {
+	mod10, mod100 := count%10, count%100
+	switch {
+	case mod10 == 1 && mod100 != 11:
+		return PluralOne
+	case mod10 >= 2 && mod10 <= 4 && (mod100 < 12 || mod100 > 14):
+		return PluralFew
+	default:
+		return PluralMany
+	}
+}
+
+var plural_polish PluralRule = func(count uint64) string {
+	mod10, mod100 := count%10, count%100
+	switch {
+	case count == 1:
+		return PluralOne
+	case mod10 >= 2 && mod10 <= 4 && (mod100 < 12 || mod100 > 14):
+		return PluralFew
+	default:
+		return PluralMany
+	}
+}
+
+var plural_other PluralRule = func(count uint64) string {
+	return PluralOther
+}
+
+var plural_rules = map[string]PluralRule{
+	"en": plural_one_other,
+	"de": plural_one_other,
+	"nl": plural_one_other,
+	"sv": plural_one_other,
+	"da": plural_one_other,
+	"no": plural_one_other,
+	"nb": plural_one_other,
+	"fi": plural_one_other,
+	"es": plural_one_other,
+	"it": plural_one_other,
+	"pt": plural_one_other,
+	"fr": plural_one_includes_zero,
+	"ru": plural_slavic,
+	"uk": plural_slavic,
+	"pl": plural_polish,
+	"ja": plural_other,
+	"ko": plural_other,
+	"zh": plural_other,
+	"tr": plural_other,
+}
+
+// PluralOf returns the plural category of said count within said locale, defaulting to the english rule.
+func PluralOf(locale string, count uint64) string {
+	if rule, ok := plural_rules[language_of(locale)]; ok {
+		return rule(count)
+	}
+	return plural_one_other(count)
+}
+
+// fetch the base language of a locale (e.g. "pt-BR" -> "pt")
+func language_of(locale string) string {
+	locale = normalize(locale)
+	if index := strings.IndexByte(locale, '-'); index != -1 {
+		return locale[:index]
+	}
+	return locale
+}
+
+func normalize(locale string) string {
+	return strings.ReplaceAll(strings.ToLower(strings.TrimSpace(locale)), "_", "-")
+}
@@ -0,0 +1,46 @@
+package locale
+
+import (
+	"encoding/json"
+	"testing"
+)
+
+func TestPluralOf(t *testing.T) {
+	for _, test := range []struct {
+		locale   string
+		count    uint64
+		category string
+	}{
+		{"en", 0, PluralOther},
+		{"en", 1, PluralOne},
+		{"fr", 0, PluralOne},
+		{"fr-CA", 1, PluralOne},
+		{"fr", 2, PluralOther},
+		{"ru", 21, PluralOne},
+		{"ru", 12, PluralMany},
+		{"pl", 22, PluralFew},
+		{"ja", 1, PluralOther},
+		{"unknown", 1, PluralOne},
+	} {
+		if category := PluralOf(test.locale, test.count); category != test.category {
+			t.Errorf("%d in %s is %s, expected %s", test.count, test.locale, category, test.category)
+		}
+	}
+}
+
+func TestMessageWrittenAsRead(t *testing.T) {
+	for _, raw := range []string{
+		`"plain"`,
+		`{"one":"a point","other":"{points} points"}`,
+	} {
+		var message Message
+		if err := json.Unmarshal([]byte(raw), &message); err != nil {
+			t.Fatalf("%s: %s", raw, err)
+		}
+
+		written, err := json.Marshal(message)
+		if err != nil || string(written) != raw {
+			t.Errorf("%s written as %s (%v)", raw, written, err)
+		}
+	}
+}
@@ -4,19 +4,23 @@ import (
 	"context"
 	"database/sql"
 	"errors"
+	"flag"
 	"log"
 	"net/http"
 	"os"
 	"os/signal"
+	"strings"
 	"syscall"
-	"time"
 
 	"github.com/gin-gonic/gin"
 	"github.com/imoliwer/sound-point-twitch-bot/server/app"
+	"github.com/imoliwer/sound-point-twitch-bot/server/auth"
 	"github.com/imoliwer/sound-point-twitch-bot/server/command"
+	"github.com/imoliwer/sound-point-twitch-bot/server/engagement"
+	"github.com/imoliwer/sound-point-twitch-bot/server/locale"
 	"github.com/imoliwer/sound-point-twitch-bot/server/model"
 	"github.com/imoliwer/sound-point-twitch-bot/server/request"
-	"github.com/imoliwer/sound-point-twitch-bot/server/scheduler"
+	"github.com/imoliwer/sound-point-twitch-bot/server/reward"
 	"github.com/imoliwer/sound-point-twitch-bot/server/sound"
 	"github.com/imoliwer/sound-point-twitch-bot/server/twitch_irc"
 	"github.com/imoliwer/sound-point-twitch-bot/server/util"
@@ -26,45 +30,84 @@ import (
 )
 
 func main() {
+	// resolve the config from flags, environment variables and defaults
+	config, arguments, configErr := app.LoadConfig(os.Args[1:], os.Stderr)
+	if configErr != nil {
+		if errors.Is(configErr, flag.ErrHelp) {
+			return
+		}
+		os.Exit(2)
+	}
+
+	app.SettingsFile = config.SettingsFile
+	app.SecretsFile = config.SecretsFile
+
+	// handle subcommands (e.g. validate-config) in place of running the bot
+	if len(arguments) > 0 {
+		run_subcommand(config, arguments[0], arguments[1:])
+		return
+	}
+
+	// load the locale catalog used for chat responses
+	catalog, catalogErr := locale.Load(config.LocalesDirectory)
+	if catalogErr != nil {
+		panic(catalogErr)
+	}
+
 	// read the settings and handle its presence accordingly
 	log.Println("Fetching settings...")
 
-	settings := app.ReadSettings()
+	settings := app.ReadSettings(catalog)
 	if settings == nil {
 		return
 	}
 
+	// the settings may configure what neither flags nor environment variables did
+	config.ApplySettings(settings)
+	sound.Directory = config.SoundsDirectory
+
+	// read the secrets kept apart from the settings
+	secrets := app.ReadSecrets(settings)
+	if secrets == nil {
+		return
+	}
+
 	// configure our application holders
-	application := app.Application{
-		Settings: settings,
-	}
-
-	// handle the assignment of request profiles
-	twitchAccessory := settings.TwitchAccessory
-	request.Profiles = &request.RequestProfiles{
-		Twitch: request.TwitchRequestProfile{
-			ClientID:     twitchAccessory.ClientID,
-			ClientSecret: twitchAccessory.ClientSecret,
-			OAuthToken:   twitchAccessory.AuthToken,
-			RefreshToken: twitchAccessory.RefreshToken,
-		},
+	application := app.NewApplication(settings, catalog, secrets)
+
+	// handle the assignment of request profiles, each managing the token of its account
+	request.Profiles = auth.NewProfiles(application)
+	request.Helix = request.NewHelixClient(request.Profiles.Twitch)
+
+	// signal for shutdown
+	shutdown := make(chan os.Signal, 1)
+
+	// ensure an awaited channel
+	signal.Notify(shutdown, syscall.SIGINT, syscall.SIGKILL, syscall.SIGTERM, os.Interrupt)
+
+	// obtain the tokens absent, if any, through the authorization-code flow
+	authorizer := auth.NewAuthorizer(application, config.PublicURL)
+	if !await_authorization(application, authorizer, config.Address, shutdown) {
+		return
 	}
-	settings.TwitchAccessory = nil // after request assigning
 
-	// handle the validation of the user's Twitch oauth token
-	refreshTask := &scheduler.LaterTask{}
-	checkToken(true, false, nil, refreshTask)
+	// handle the validation of the broadcaster's and the bot's Twitch oauth token, each on its own schedule
+	request.Profiles.Twitch.Start()
+	defer request.Profiles.Twitch.Stop()
 
-	validationTask := scheduler.Every(time.Hour, func(_ *scheduler.RepeatingTask) {
-		checkToken(false, false, refreshTask, refreshTask)
-	})
+	request.Profiles.Bot.Start()
+	defer request.Profiles.Bot.Stop()
 
-	defer refreshTask.Cancel()
-	defer validationTask.Cancel()
+	// tokens authorized before scopes were added lack them, until authorized again
+	for _, manager := range []*request.TokenManager{request.Profiles.Twitch, request.Profiles.Bot} {
+		if missing := auth.MissingScopes(manager.Account(), manager.Health()); len(missing) > 0 {
+			util.Log("Auth", "The %s token lacks the scopes %s, authorize again: %s", manager.Account(), strings.Join(missing, ", "), authorizer.LoginURL(manager.Account()))
+		}
+	}
 
 	// attempt to create the SQLite database in case it's absent
-	if _, err := os.Stat("data.db"); errors.Is(err, os.ErrNotExist) {
-		dataFile, dataFileErr := os.Create("data.db")
+	if _, err := os.Stat(config.DatabaseFile); errors.Is(err, os.ErrNotExist) {
+		dataFile, dataFileErr := os.Create(config.DatabaseFile)
 		if dataFileErr != nil {
 			panic("Failed to create database.")
 		}
@@ -75,7 +118,7 @@ func main() {
 	log.Println("Attempting to connect to database...")
 
 	// connect to the database and handle errors accordingly
-	sqlDb, sqlDbErr := sql.Open(sqliteshim.ShimName, "./data.db")
+	sqlDb, sqlDbErr := sql.Open(sqliteshim.ShimName, config.DatabaseFile)
 	if sqlDbErr != nil {
 		panic("Could not open connection to SQLite database.")
 	}
@@ -90,28 +133,97 @@ func main() {
 	application.Database = db
 	defer db.Close() // ensure the client is closed on shutdown
 
-	// prepare table(s)
-	modelArray := []interface{}{
-		(*model.User)(nil),
+	// prepare table(s), adding the columns of later versions to those existing
+	if err := model.Migrate(context.Background(), db); err != nil {
+		panic(err)
 	}
 
-	for _, model := range modelArray {
-		_, err := db.NewCreateTable().Model(model).IfNotExists().Exec(context.Background())
-		if err != nil {
-			panic(err)
-		}
+	// handle commands
+	twitchCmdPrefix := []rune(settings.TwitchBot.Command.Prefix)
+	if len(twitchCmdPrefix) != 1 {
+		panic("Command prefix must consist of ONE character.")
 	}
 
+	// track the watch time and messages of the chatters, as well as when they followed
+	engagementTracker := engagement.NewTracker(application)
+
 	deploymentCover := sound.NewCover(0, 2048)
+
+	// plays are limited per stream, counted across restarts in the middle of one
+	if err := deploymentCover.Plays.Load(context.Background(), db); err != nil {
+		util.Log("Sounds", "Could not load the plays: %s", err)
+	}
+
+	// the cooldowns of sounds outlive restarts, those expired meanwhile being dropped
+	soundCooldowns := sound.NewCooldowns(application)
+	if err := soundCooldowns.Load(context.Background()); err != nil {
+		util.Log("Sounds", "Could not load the cooldowns: %s", err)
+	}
+
+	// packs unlocked are kept for good, gating the sounds within them
+	soundPacks := sound.NewPacks(application)
+	if err := soundPacks.Load(context.Background()); err != nil {
+		util.Log("Sounds", "Could not load the packs unlocked: %s", err)
+	}
+	deploymentCover.UsePacks(soundPacks)
+
+	twitchCmdRegistry := command.NewRegistry(
+		twitchCmdPrefix[0],
+		map[string]command.PrimaryCommand{
+			"points":    command.NewPointsCommand(),
+			"watchtime": command.NewWatchTimeCommand(engagementTracker),
+			"followage": command.NewFollowAgeCommand(engagementTracker),
+			"sounds":    command.NewSoundsCommand(deploymentCover, soundCooldowns, config.PageURL),
+			"sound":     command.NewSoundCommand(deploymentCover, soundCooldowns, soundPacks),
+			"packs":     command.NewPacksCommand(soundPacks),
+			"tts":       command.NewTTSCommand(deploymentCover),
+		},
+		command.GeneralPlaceholders,
+	)
+	twitchCmdRegistry.UseEngagement(engagementTracker)
+
+	// convert uploaded sounds into a single format of a consistent loudness, if enabled
+	soundProcessor := sound.NewProcessor(application)
+	soundProcessor.Start()
+	defer soundProcessor.Stop()
+
+	// mirror sounds as channel points rewards and handle their redemptions
+	rewards := reward.NewManager(application, deploymentCover)
+	rewards.UseEngagement(engagementTracker)
+	rewards.UseCooldowns(soundCooldowns)
+	rewards.Start(reward.PollInterval)
+	defer rewards.Stop()
+
+	// track whether the stream is live, polling in case an event is missed
+	application.Stream.OnOnline(func(status app.StreamStatus) {
+		deploymentCover.Plays.Begin(status.StartedAt) // plays are limited per stream
+	})
+	application.Stream.Start(app.StreamPollInterval)
+	defer application.Stream.Stop()
+
+	engagementTracker.Start(engagement.WatchInterval)
+	defer engagementTracker.Stop()
+
+	// react to what happens on the channel as twitch pushes it through EventSub
+	events := listen_for_events(application, rewards, engagementTracker)
+	eventsContext, stopEvents := context.WithCancel(context.Background())
+	go events.Run(eventsContext)
+	defer stopEvents()
+
 	{ // set up the main server
 		gin.SetMode(gin.ReleaseMode)
 		engine := sound.WithCORSAndRecovery(gin.New())
 
 		deploymentCover.Handler(engine)
-		sound.RegisterAll(engine, &application, deploymentCover)
+		twitchCmdRegistry.Handler(engine)
+		application.Handler(engine)
+		authorizer.Handler(engine)
+		rewards.Handler(engine)
+		engagementTracker.Handler(engine)
+		sound.RegisterAll(engine, application, deploymentCover, soundProcessor, soundCooldowns, soundPacks)
 
 		server := &http.Server{
-			Addr:    ":9999",
+			Addr:    config.Address,
 			Handler: engine,
 		}
 
@@ -128,84 +240,90 @@ func main() {
 		defer server.Close()
 	}
 
+	{ // set up the public page of sounds, apart from the dashboard as the api is unauthenticated
+		engine := gin.New()
+		engine.Use(gin.Recovery())
+		sound.PublicHandler(engine, application, deploymentCover, soundCooldowns)
+
+		server := &http.Server{
+			Addr:    config.PublicAddress,
+			Handler: engine,
+		}
+
+		go func() {
+			if err := server.ListenAndServe(); err != nil {
+				if errors.Is(err, http.ErrServerClosed) {
+					return
+				}
+				panic(err)
+			}
+		}()
+
+		util.Log("Public Page", "Server started.")
+		defer server.Close()
+	}
+
 	{ // set up the twitch irc
 		twitchChannelToJoin := settings.TwitchBot.Channel
 		if twitchChannelToJoin == "" {
 			panic("Invalid channel name in settings.")
 		}
 
-		twitchIRC := twitch_irc.NewClient(&application)
+		twitchIRC := twitch_irc.NewClient(application)
 		twitchIRC.Listen()
 		defer twitchIRC.Stop()
 
-		// handle commands
-		twitchCmdPrefix := []rune(settings.TwitchBot.Command.Prefix)
-		if len(twitchCmdPrefix) != 1 {
-			panic("Command prefix must consist of ONE character.")
-		}
-
-		twitchCmdRegistry := command.NewRegistry(
-			twitchCmdPrefix[0],
-			map[string]command.PrimaryCommand{
-				"points": command.NewPointsCommand(),
-			},
-			map[string]command.PlaceholderFunc{}, // TODO: register a bunch of general placeholders
-		)
-
-		twitchIRC.WithHandler("message", twitchCmdRegistry.DefaultHandler)
+		twitchIRC.WithHandler("message", func(client *twitch_irc.Client, state *twitch_irc.MessageState) {
+			engagementTracker.Count(state.User.Id)
+			twitchCmdRegistry.DefaultHandler(client, state)
+		})
 		twitchIRC.Join(twitchChannelToJoin) // join after command handle
-	}
 
-	// signal for shutdown
-	shutdown := make(chan os.Signal)
+		// re-authenticate whenever the bot token is refreshed or authorized anew
+		request.Profiles.Bot.OnRefresh(func(_ request.TwitchRequestProfile) {
+			if err := twitchIRC.Reconnect(); err != nil {
+				util.Log("Twitch IRC", "Could not reconnect: %s", err)
+			}
+		})
 
-	// ensure an awaited channel
-	signal.Notify(shutdown, syscall.SIGINT, syscall.SIGKILL, syscall.SIGTERM, os.Interrupt)
-	<-shutdown
+		// a rejected token is refreshed once, and the connection re-established with the new one
+		twitchIRC.OnAuthenticationFailed(func(_ *twitch_irc.Client) {
+			request.Profiles.Bot.RefreshAfterRejection()
+		})
 
-	// termination message
-	application.Settings.Save()
-	log.Println("Cleaning up and shutting down...")
-}
+		// apply what can be applied at runtime whenever the settings are reloaded
+		application.OnSettingsSwap(func(previous *app.Settings, current *app.Settings) {
+			previousBot, currentBot := previous.TwitchBot, current.TwitchBot
 
-func checkToken(first bool, ignoreValidation bool, old *scheduler.LaterTask, ptr *scheduler.LaterTask) {
-	profile := request.Profiles.Twitch
+			if !strings.EqualFold(previousBot.Channel, currentBot.Channel) {
+				twitchIRC.Part(previousBot.Channel)
+				twitchIRC.Join(currentBot.Channel)
+			}
 
-	if !ignoreValidation {
-		if validation := request.ValidateTwitchToken(profile.OAuthToken); validation != nil {
-			if first {
-				tokenTimer(validation.ExpiresIn, ptr)
+			if previousBot.Name != currentBot.Name {
+				util.Log("Settings", "Changes to the bot's name require a restart.")
 			}
-			return
-		}
-	}
 
-	response := request.RefreshCurrentTwitchToken()
-	if response == nil {
-		panic("Twitch 'Refresh Token' is invalid. Please generate a new one and replace the one in \"settings.json.\"")
+			if previous.Server != current.Server {
+				util.Log("Settings", "Changes to the server section require a restart.")
+			}
+		})
 	}
 
-	// attempt to revoke current just to avoid multiple (twitch holds up to 50 access tokens per refresh token)
-	request.RevokeTwitchToken(request.Profiles.Twitch.OAuthToken)
-
-	request.Profiles.Twitch = request.TwitchRequestProfile{
-		ClientID:     profile.ClientID,
-		ClientSecret: profile.ClientSecret,
-		OAuthToken:   response.AccessToken,
-		RefreshToken: response.RefreshToken,
+	// reload the settings whenever modified on disk
+	settingsWatcher, settingsWatcherErr := application.WatchSettings()
+	if settingsWatcherErr != nil {
+		util.Log("Settings", "Could not watch for changes: %s", settingsWatcherErr)
+	} else {
+		defer settingsWatcher.Close()
 	}
 
-	if old != nil {
-		old.Cancel()
-	}
-	tokenTimer(response.ExpiresIn, ptr)
-}
+	// await the signal for shutdown
+	<-shutdown
 
-func tokenTimer(expiresIn uint64, ptr *scheduler.LaterTask) {
-	*ptr = *scheduler.After(
-		time.Duration(expiresIn)*time.Second,
-		func(this *scheduler.LaterTask) {
-			checkToken(false, true, this, ptr)
-		},
-	)
+	// termination message
+	if err := application.Flush(); err != nil {
+		log.Printf("Could not save settings: %s", err)
+	}
+	log.Println("Cleaning up and shutting down...")
 }
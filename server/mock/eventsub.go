@@ -0,0 +1,274 @@
+package mock
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gorilla/websocket"
+	"github.com/imoliwer/sound-point-twitch-bot/server/eventsub"
+	"github.com/imoliwer/sound-point-twitch-bot/server/request"
+)
+
+var ErrNoSession = errors.New("no session connected")
+
+type eventsub_connection struct {
+	socket *websocket.Conn
+	mutex  sync.Mutex // serializes the writing
+	closed chan struct{}
+}
+
+// EventSub is a local stand-in for the EventSub WebSocket transport, for use within tests. Subscriptions
+// are created through the Helix stand-in it is attached to.
+type EventSub struct {
+	server        *httptest.Server
+	upgrader      websocket.Upgrader
+	keepalive     time.Duration
+	mutex         sync.Mutex
+	sessions      map[string]*eventsub_connection
+	current       string // id of the session last welcomed
+	subscriptions map[string]request.EventSubSubscription
+}
+
+// NewEventSub creates the stand-in, sending keepalive messages every said interval when otherwise idle.
+func NewEventSub(keepalive time.Duration) *EventSub {
+	standIn := &EventSub{
+		keepalive:     keepalive,
+		sessions:      make(map[string]*eventsub_connection),
+		subscriptions: make(map[string]request.EventSubSubscription),
+	}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/ws", standIn.connect)
+
+	standIn.server = httptest.NewServer(mux)
+	return standIn
+}
+
+// URL returns the url to use in place of "wss://eventsub.wss.twitch.tv/ws".
+func (r *EventSub) URL() string {
+	return "ws" + strings.TrimPrefix(r.server.URL, "http") + "/ws"
+}
+
+func (r *EventSub) Close() {
+	r.mutex.Lock()
+	for _, connection := range r.sessions {
+		connection.socket.Close()
+	}
+	r.mutex.Unlock()
+	r.server.Close()
+}
+
+// Session returns the id of the session last welcomed.
+func (r *EventSub) Session() string {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	return r.current
+}
+
+// Subscriptions returns every subscription that is enabled.
+func (r *EventSub) Subscriptions() []request.EventSubSubscription {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	subscriptions := make([]request.EventSubSubscription, 0, len(r.subscriptions))
+	for _, subscription := range r.subscriptions {
+		subscriptions = append(subscriptions, subscription)
+	}
+	return subscriptions
+}
+
+// Notify sends said event to every session subscribed to said type of event, returning how many were notified.
+func (r *EventSub) Notify(kind string, event any) (int, error) {
+	raw, err := json.Marshal(event)
+	if err != nil {
+		return 0, err
+	}
+
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	notified := 0
+	for _, subscription := range r.subscriptions {
+		connection, ok := r.sessions[subscription.Transport.SessionID]
+		if subscription.Type != kind || !ok {
+			continue
+		}
+
+		subscription := subscription
+		message := message_of("notification", eventsub.Payload{Subscription: &subscription, Event: raw})
+		message.Metadata.SubscriptionType = subscription.Type
+		message.Metadata.SubscriptionVersion = subscription.Version
+
+		if err := connection.send(message); err != nil {
+			return notified, err
+		}
+		notified++
+	}
+	return notified, nil
+}
+
+// Reconnect asks the current session to reconnect, carrying its subscriptions over to the new connection.
+func (r *EventSub) Reconnect() error {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	connection, ok := r.sessions[r.current]
+	if !ok {
+		return ErrNoSession
+	}
+
+	return connection.send(message_of("session_reconnect", eventsub.Payload{Session: &eventsub.Session{
+		ID:           r.current,
+		Status:       "reconnecting",
+		ReconnectURL: r.URL() + "?reconnect=" + r.current,
+		ConnectedAt:  time.Now(),
+	}}))
+}
+
+// Revoke revokes every subscription to said type of event for said reason (e.g. "authorization_revoked").
+func (r *EventSub) Revoke(kind string, status string) error {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	for id, subscription := range r.subscriptions {
+		if subscription.Type != kind {
+			continue
+		}
+		delete(r.subscriptions, id)
+
+		connection, ok := r.sessions[subscription.Transport.SessionID]
+		if !ok {
+			continue
+		}
+
+		subscription.Status = status
+		if err := connection.send(message_of("revocation", eventsub.Payload{Subscription: &subscription})); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Drop closes the connection of the current session without notice, as if lost.
+func (r *EventSub) Drop() {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	if connection, ok := r.sessions[r.current]; ok {
+		connection.socket.Close()
+	}
+}
+
+// subscribe said session, as done by the Helix stand-in
+func (r *EventSub) subscribe(subscription request.EventSubSubscription) (request.EventSubSubscription, bool) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	if _, ok := r.sessions[subscription.Transport.SessionID]; !ok || subscription.Transport.Method != "websocket" {
+		return subscription, false
+	}
+
+	subscription.ID = random_token()
+	subscription.Status = "enabled"
+	subscription.CreatedAt = time.Now()
+	r.subscriptions[subscription.ID] = subscription
+	return subscription, true
+}
+
+// unsubscribe said subscription, as done by the Helix stand-in
+func (r *EventSub) unsubscribe(id string) bool {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	_, ok := r.subscriptions[id]
+	delete(r.subscriptions, id)
+	return ok
+}
+
+func (r *EventSub) connect(writer http.ResponseWriter, req *http.Request) {
+	socket, err := r.upgrader.Upgrade(writer, req, nil)
+	if err != nil {
+		return
+	}
+
+	connection := &eventsub_connection{socket: socket, closed: make(chan struct{})}
+
+	r.mutex.Lock()
+	sessionID := req.URL.Query().Get("reconnect")
+	if _, ok := r.sessions[sessionID]; !ok {
+		sessionID = random_token()
+	}
+	r.sessions[sessionID] = connection
+	r.current = sessionID
+
+	connection.send(message_of("session_welcome", eventsub.Payload{Session: &eventsub.Session{
+		ID:                      sessionID,
+		Status:                  "connected",
+		KeepaliveTimeoutSeconds: uint64(r.keepalive / time.Second),
+		ConnectedAt:             time.Now(),
+	}}))
+	r.mutex.Unlock()
+
+	go r.keep_alive(connection)
+
+	// nothing is expected from the client, the reading only tells when the connection is gone
+	for {
+		if _, _, err := socket.ReadMessage(); err != nil {
+			break
+		}
+	}
+	close(connection.closed)
+
+	// the subscriptions of a session end with it, unless it has been carried over to another connection
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	if r.sessions[sessionID] != connection {
+		return
+	}
+	delete(r.sessions, sessionID)
+
+	for id, subscription := range r.subscriptions {
+		if subscription.Transport.SessionID == sessionID {
+			delete(r.subscriptions, id)
+		}
+	}
+}
+
+func (r *EventSub) keep_alive(connection *eventsub_connection) {
+	ticker := time.NewTicker(r.keepalive)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-connection.closed:
+			return
+		case <-ticker.C:
+			if connection.send(message_of("session_keepalive", eventsub.Payload{})) != nil {
+				return
+			}
+		}
+	}
+}
+
+func (r *eventsub_connection) send(message eventsub.Message) error {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	return r.socket.WriteJSON(message)
+}
+
+func message_of(kind string, payload eventsub.Payload) eventsub.Message {
+	return eventsub.Message{
+		Metadata: eventsub.Metadata{
+			MessageID:        random_token(),
+			MessageType:      kind,
+			MessageTimestamp: time.Now(),
+		},
+		Payload: payload,
+	}
+}
@@ -0,0 +1,463 @@
+package mock
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/imoliwer/sound-point-twitch-bot/server/request"
+)
+
+var valid_login = regexp.MustCompile("^[a-zA-Z0-9_]{1,25}$")
+
+type helix_reward struct {
+	request.CustomReward
+	createdAt time.Time
+}
+
+// Helix is a local stand-in for the parts of the Twitch Helix API in use, for use within tests.
+// Tokens are checked against said Twitch ID stand-in.
+type Helix struct {
+	id          *TwitchID
+	server      *httptest.Server
+	mutex       sync.Mutex
+	users       []request.TwitchUser
+	rewards     map[string]*helix_reward
+	redemptions map[string]*request.Redemption
+	eventSub    *EventSub
+	streams     map[string]request.TwitchStream
+	channels    map[string]request.ChannelInformation
+	chatters    map[string][]request.Chatter
+	followers   map[string][]request.ChannelFollower
+	requests    map[string]int
+}
+
+func NewHelix(id *TwitchID) *Helix {
+	helix := &Helix{
+		id:          id,
+		rewards:     make(map[string]*helix_reward),
+		redemptions: make(map[string]*request.Redemption),
+		streams:     make(map[string]request.TwitchStream),
+		channels:    make(map[string]request.ChannelInformation),
+		chatters:    make(map[string][]request.Chatter),
+		followers:   make(map[string][]request.ChannelFollower),
+		requests:    make(map[string]int),
+	}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/helix/users", helix.authorized("", helix.users_endpoint))
+	mux.HandleFunc("/helix/channel_points/custom_rewards", helix.authorized("channel:manage:redemptions", helix.rewards_endpoint))
+	mux.HandleFunc("/helix/channel_points/custom_rewards/redemptions", helix.authorized("channel:manage:redemptions", helix.redemptions_endpoint))
+	mux.HandleFunc("/helix/streams", helix.authorized("", helix.streams_endpoint))
+	mux.HandleFunc("/helix/channels", helix.authorized("", helix.channels_endpoint))
+	mux.HandleFunc("/helix/chat/chatters", helix.authorized("moderator:read:chatters", helix.chatters_endpoint))
+	mux.HandleFunc("/helix/channels/followers", helix.authorized("moderator:read:followers", helix.followers_endpoint))
+	mux.HandleFunc("/helix/eventsub/subscriptions", helix.authorized("", helix.subscriptions_endpoint))
+
+	helix.server = httptest.NewServer(mux)
+	return helix
+}
+
+// URL returns the base url to use in place of "https://api.twitch.tv/helix".
+func (r *Helix) URL() string {
+	return r.server.URL + "/helix"
+}
+
+func (r *Helix) Close() {
+	r.server.Close()
+}
+
+// ID returns the Twitch ID stand-in tokens are checked against.
+func (r *Helix) ID() *TwitchID {
+	return r.id
+}
+
+// Requests returns how many requests were made to said endpoint, e.g. "/users".
+func (r *Helix) Requests(endpoint string) int {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	return r.requests[endpoint]
+}
+
+// AddUser makes said user known to the user endpoint.
+func (r *Helix) AddUser(user request.TwitchUser) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	r.users = append(r.users, user)
+}
+
+// UseEventSub creates the subscriptions (and deletes them) through said EventSub stand-in.
+func (r *Helix) UseEventSub(eventSub *EventSub) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	r.eventSub = eventSub
+}
+
+// GoLive makes said stream live, its title and game also becoming those of the channel.
+func (r *Helix) GoLive(stream request.TwitchStream) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	r.streams[stream.UserID] = stream
+	r.channels[stream.UserID] = request.ChannelInformation{
+		BroadcasterID:    stream.UserID,
+		BroadcasterLogin: stream.UserLogin,
+		GameID:           stream.GameID,
+		GameName:         stream.GameName,
+		Title:            stream.Title,
+	}
+}
+
+// GoOffline ends the stream of said broadcaster.
+func (r *Helix) GoOffline(broadcasterID string) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	delete(r.streams, broadcasterID)
+}
+
+// Chat replaces those present in the chat of said broadcaster.
+func (r *Helix) Chat(broadcasterID string, users ...request.TwitchUser) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	chatters := make([]request.Chatter, 0, len(users))
+	for _, user := range users {
+		chatters = append(chatters, request.Chatter{UserID: user.Id, UserLogin: user.Login, UserName: user.DisplayName})
+	}
+	r.chatters[broadcasterID] = chatters
+}
+
+// Follow makes said user follow said broadcaster since said time.
+func (r *Helix) Follow(broadcasterID string, user request.TwitchUser, at time.Time) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	r.followers[broadcasterID] = append(r.followers[broadcasterID], request.ChannelFollower{
+		UserID:     user.Id,
+		UserLogin:  user.Login,
+		UserName:   user.DisplayName,
+		FollowedAt: at,
+	})
+}
+
+// Rewards returns every custom reward of said broadcaster, oldest first.
+func (r *Helix) Rewards(broadcasterID string) []request.CustomReward {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	return r.rewards_of(broadcasterID)
+}
+
+// Redeem redeems said reward as said user, as if done through the chat. The redemption is returned.
+func (r *Helix) Redeem(rewardID string, user request.TwitchUser, input string) (request.Redemption, bool) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	reward, ok := r.rewards[rewardID]
+	if !ok {
+		return request.Redemption{}, false
+	}
+
+	status := request.RedemptionUnfulfilled
+	if reward.ShouldRedemptionsSkipRequestQueue {
+		status = request.RedemptionFulfilled
+	}
+
+	redemption := &request.Redemption{
+		ID:            random_token(),
+		BroadcasterID: reward.BroadcasterID,
+		UserID:        user.Id,
+		UserLogin:     user.Login,
+		UserName:      user.DisplayName,
+		UserInput:     input,
+		Status:        status,
+		Reward: request.RedeemedReward{
+			ID:     reward.ID,
+			Title:  reward.Title,
+			Prompt: reward.Prompt,
+			Cost:   reward.Cost,
+		},
+		RedeemedAt: time.Now(),
+	}
+	r.redemptions[redemption.ID] = redemption
+	return *redemption, true
+}
+
+// Redemption returns the current state of said redemption.
+func (r *Helix) Redemption(id string) (request.Redemption, bool) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	redemption, ok := r.redemptions[id]
+	if !ok {
+		return request.Redemption{}, false
+	}
+	return *redemption, true
+}
+
+// the handler of said endpoint, requiring a valid token granted said scope (unless empty) as helix does
+func (r *Helix) authorized(scope string, handle func(http.ResponseWriter, *http.Request)) http.HandlerFunc {
+	return func(writer http.ResponseWriter, req *http.Request) {
+		token := strings.TrimPrefix(req.Header.Get("Authorization"), "Bearer ")
+		_, scopes, ok := r.id.Grant(token)
+		if !ok || req.Header.Get("Client-Id") != r.id.ClientID {
+			helix_error(writer, http.StatusUnauthorized, "Invalid OAuth token")
+			return
+		}
+		if scope != "" && !contains_fold(scopes, scope) {
+			helix_error(writer, http.StatusUnauthorized, "Missing scope: "+scope)
+			return
+		}
+
+		r.mutex.Lock()
+		defer r.mutex.Unlock()
+		r.requests[strings.TrimPrefix(req.URL.Path, "/helix")]++
+		handle(writer, req)
+	}
+}
+
+func (r *Helix) users_endpoint(writer http.ResponseWriter, req *http.Request) {
+	query := req.URL.Query()
+	logins, ids := query["login"], query["id"]
+	for _, login := range logins {
+		if !valid_login.MatchString(login) {
+			helix_error(writer, http.StatusBadRequest, "Invalid login names, emails or IDs in request")
+			return
+		}
+	}
+
+	population := make([]request.TwitchUser, 0)
+	for _, user := range r.users {
+		if contains_fold(logins, user.Login) || contains_fold(ids, user.Id) {
+			population = append(population, user)
+		}
+	}
+	respond(writer, http.StatusOK, request.Page[request.TwitchUser]{Data: population})
+}
+
+func (r *Helix) streams_endpoint(writer http.ResponseWriter, req *http.Request) {
+	population := make([]request.TwitchStream, 0)
+	for _, id := range req.URL.Query()["user_id"] {
+		if stream, ok := r.streams[id]; ok {
+			population = append(population, stream)
+		}
+	}
+	respond(writer, http.StatusOK, request.Page[request.TwitchStream]{Data: population})
+}
+
+func (r *Helix) channels_endpoint(writer http.ResponseWriter, req *http.Request) {
+	population := make([]request.ChannelInformation, 0)
+	for _, id := range req.URL.Query()["broadcaster_id"] {
+		if channel, ok := r.channels[id]; ok {
+			population = append(population, channel)
+		}
+	}
+	respond(writer, http.StatusOK, request.Page[request.ChannelInformation]{Data: population})
+}
+
+func (r *Helix) chatters_endpoint(writer http.ResponseWriter, req *http.Request) {
+	query := req.URL.Query()
+	if query.Get("moderator_id") == "" {
+		helix_error(writer, http.StatusBadRequest, "missing moderator_id")
+		return
+	}
+
+	population := append([]request.Chatter{}, r.chatters[query.Get("broadcaster_id")]...)
+	respond(writer, http.StatusOK, request.Page[request.Chatter]{Data: population, Total: len(population)})
+}
+
+func (r *Helix) followers_endpoint(writer http.ResponseWriter, req *http.Request) {
+	query := req.URL.Query()
+	userID := query.Get("user_id")
+
+	population := make([]request.ChannelFollower, 0)
+	for _, follower := range r.followers[query.Get("broadcaster_id")] {
+		if userID == "" || follower.UserID == userID {
+			population = append(population, follower)
+		}
+	}
+	respond(writer, http.StatusOK, request.Page[request.ChannelFollower]{Data: population, Total: len(population)})
+}
+
+func (r *Helix) rewards_endpoint(writer http.ResponseWriter, req *http.Request) {
+	query := req.URL.Query()
+	broadcasterID := query.Get("broadcaster_id")
+
+	switch req.Method {
+	case http.MethodGet:
+		respond(writer, http.StatusOK, request.Page[request.CustomReward]{Data: r.rewards_of(broadcasterID)})
+	case http.MethodPost:
+		var changes request.CustomRewardChanges
+		if json.NewDecoder(req.Body).Decode(&changes) != nil || changes.Title == "" || changes.Cost == 0 {
+			helix_error(writer, http.StatusBadRequest, "invalid reward")
+			return
+		}
+
+		if r.has_title(broadcasterID, changes.Title, "") {
+			helix_error(writer, http.StatusBadRequest, "CREATE_CUSTOM_REWARD_DUPLICATE_REWARD")
+			return
+		}
+
+		reward := &helix_reward{createdAt: time.Now()}
+		reward.ID = random_token()
+		reward.BroadcasterID = broadcasterID
+		apply_changes(&reward.CustomReward, changes)
+		r.rewards[reward.ID] = reward
+		respond(writer, http.StatusOK, request.Page[request.CustomReward]{Data: []request.CustomReward{reward.CustomReward}})
+	case http.MethodPatch:
+		reward, ok := r.rewards[query.Get("id")]
+		if !ok || reward.BroadcasterID != broadcasterID {
+			helix_error(writer, http.StatusNotFound, "reward not found")
+			return
+		}
+
+		var changes request.CustomRewardChanges
+		if json.NewDecoder(req.Body).Decode(&changes) != nil {
+			helix_error(writer, http.StatusBadRequest, "invalid reward")
+			return
+		}
+
+		if r.has_title(broadcasterID, changes.Title, reward.ID) {
+			helix_error(writer, http.StatusBadRequest, "UPDATE_CUSTOM_REWARD_DUPLICATE_REWARD")
+			return
+		}
+
+		apply_changes(&reward.CustomReward, changes)
+		respond(writer, http.StatusOK, request.Page[request.CustomReward]{Data: []request.CustomReward{reward.CustomReward}})
+	case http.MethodDelete:
+		reward, ok := r.rewards[query.Get("id")]
+		if !ok || reward.BroadcasterID != broadcasterID {
+			helix_error(writer, http.StatusNotFound, "reward not found")
+			return
+		}
+
+		delete(r.rewards, reward.ID)
+		writer.WriteHeader(http.StatusNoContent)
+	default:
+		helix_error(writer, http.StatusMethodNotAllowed, "method not allowed")
+	}
+}
+
+func (r *Helix) redemptions_endpoint(writer http.ResponseWriter, req *http.Request) {
+	query := req.URL.Query()
+	broadcasterID, rewardID := query.Get("broadcaster_id"), query.Get("reward_id")
+
+	switch req.Method {
+	case http.MethodGet:
+		population := make([]request.Redemption, 0)
+		for _, redemption := range r.redemptions {
+			if redemption.BroadcasterID == broadcasterID && redemption.Reward.ID == rewardID && redemption.Status == query.Get("status") {
+				population = append(population, *redemption)
+			}
+		}
+
+		sort.Slice(population, func(i, j int) bool { return population[i].RedeemedAt.Before(population[j].RedeemedAt) })
+		respond(writer, http.StatusOK, request.Page[request.Redemption]{Data: population})
+	case http.MethodPatch:
+		var body struct {
+			Status string `json:"status"`
+		}
+		if json.NewDecoder(req.Body).Decode(&body) != nil || (body.Status != request.RedemptionFulfilled && body.Status != request.RedemptionCanceled) {
+			helix_error(writer, http.StatusBadRequest, "invalid status")
+			return
+		}
+
+		redemption, ok := r.redemptions[query.Get("id")]
+		if !ok || redemption.BroadcasterID != broadcasterID || redemption.Reward.ID != rewardID || redemption.Status != request.RedemptionUnfulfilled {
+			helix_error(writer, http.StatusNotFound, "redemption not found or not unfulfilled")
+			return
+		}
+
+		redemption.Status = body.Status
+		respond(writer, http.StatusOK, request.Page[request.Redemption]{Data: []request.Redemption{*redemption}})
+	default:
+		helix_error(writer, http.StatusMethodNotAllowed, "method not allowed")
+	}
+}
+
+func (r *Helix) subscriptions_endpoint(writer http.ResponseWriter, req *http.Request) {
+	if r.eventSub == nil {
+		helix_error(writer, http.StatusNotFound, "no eventsub in use")
+		return
+	}
+
+	switch req.Method {
+	case http.MethodPost:
+		var subscription request.EventSubSubscription
+		if json.NewDecoder(req.Body).Decode(&subscription) != nil || subscription.Type == "" || subscription.Version == "" {
+			helix_error(writer, http.StatusBadRequest, "invalid subscription")
+			return
+		}
+		for key, value := range subscription.Condition {
+			if value == "" {
+				helix_error(writer, http.StatusBadRequest, "invalid condition "+key)
+				return
+			}
+		}
+
+		created, ok := r.eventSub.subscribe(subscription)
+		if !ok {
+			helix_error(writer, http.StatusBadRequest, "session not found")
+			return
+		}
+		respond(writer, http.StatusAccepted, request.Page[request.EventSubSubscription]{Data: []request.EventSubSubscription{created}, Total: 1})
+	case http.MethodDelete:
+		if !r.eventSub.unsubscribe(req.URL.Query().Get("id")) {
+			helix_error(writer, http.StatusNotFound, "subscription not found")
+			return
+		}
+		writer.WriteHeader(http.StatusNoContent)
+	default:
+		helix_error(writer, http.StatusMethodNotAllowed, "method not allowed")
+	}
+}
+
+// must be called while holding the mutex
+func (r *Helix) rewards_of(broadcasterID string) []request.CustomReward {
+	population := make([]*helix_reward, 0)
+	for _, reward := range r.rewards {
+		if reward.BroadcasterID == broadcasterID {
+			population = append(population, reward)
+		}
+	}
+	sort.Slice(population, func(i, j int) bool { return population[i].createdAt.Before(population[j].createdAt) })
+
+	rewards := make([]request.CustomReward, 0, len(population))
+	for _, reward := range population {
+		rewards = append(rewards, reward.CustomReward)
+	}
+	return rewards
+}
+
+// must be called while holding the mutex
+func (r *Helix) has_title(broadcasterID string, title string, except string) bool {
+	for _, reward := range r.rewards {
+		if reward.BroadcasterID == broadcasterID && reward.ID != except && strings.EqualFold(reward.Title, title) {
+			return true
+		}
+	}
+	return false
+}
+
+func apply_changes(reward *request.CustomReward, changes request.CustomRewardChanges) {
+	reward.Title = changes.Title
+	reward.Prompt = changes.Prompt
+	reward.Cost = changes.Cost
+	reward.IsEnabled = changes.IsEnabled
+	reward.ShouldRedemptionsSkipRequestQueue = changes.ShouldRedemptionsSkipRequestQueue
+}
+
+func helix_error(writer http.ResponseWriter, status int, message string) {
+	respond(writer, status, map[string]any{"error": http.StatusText(status), "status": status, "message": message})
+}
+
+func contains_fold(values []string, value string) bool {
+	for _, candidate := range values {
+		if strings.EqualFold(candidate, value) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,55 @@
+package mock
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/imoliwer/sound-point-twitch-bot/server/request"
+)
+
+// UseTwitchID points the request package at said stand-in for the duration of said test, with profiles
+// of both accounts holding its client yet no tokens. Whatever was in use is restored afterwards.
+func UseTwitchID(t testing.TB, id *TwitchID) {
+	baseOAuthURL, profiles := request.BaseOAuthURL, request.Profiles
+	request.BaseOAuthURL = id.URL()
+
+	profile := request.TwitchRequestProfile{ClientID: id.ClientID, ClientSecret: id.ClientSecret}
+	request.Profiles = &request.RequestProfiles{
+		Twitch: request.NewTokenManager("broadcaster", profile, ignore_tokens),
+		Bot:    request.NewTokenManager("bot", profile, ignore_tokens),
+	}
+
+	t.Cleanup(func() {
+		request.Profiles.Twitch.Stop()
+		request.Profiles.Bot.Stop()
+		request.BaseOAuthURL, request.Profiles = baseOAuthURL, profiles
+		id.Close()
+	})
+}
+
+// UseHelix points the request package at said stand-in (and the Twitch ID stand-in it checks tokens against)
+// for the duration of said test, the broadcaster being authorized as said user with said scopes.
+func UseHelix(t testing.TB, helix *Helix, login string, userID string, scopes ...string) {
+	UseTwitchID(t, helix.id)
+	baseAPIURL, client := request.BaseAPIURL, request.Helix
+	request.BaseAPIURL = strings.TrimSuffix(helix.URL(), "helix")
+
+	accessToken, refreshToken := helix.id.Issue(login, userID, scopes...)
+	request.Profiles.Twitch = request.NewTokenManager("broadcaster", request.TwitchRequestProfile{
+		ClientID:     helix.id.ClientID,
+		ClientSecret: helix.id.ClientSecret,
+		OAuthToken:   accessToken,
+		RefreshToken: refreshToken,
+	}, ignore_tokens)
+	request.Profiles.Twitch.Check() // tells the id of the broadcaster
+	request.Helix = request.NewHelixClient(request.Profiles.Twitch)
+
+	t.Cleanup(func() {
+		request.BaseAPIURL, request.Helix = baseAPIURL, client
+		helix.Close()
+	})
+}
+
+func ignore_tokens(*request.TwitchOAuthRefresh) error {
+	return nil
+}
@@ -0,0 +1,224 @@
+package mock
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+)
+
+// TokenLifetime is how long tokens issued by the stand-in remain valid.
+const TokenLifetime = 4 * time.Hour
+
+type grant struct {
+	Login     string
+	UserID    string
+	Scopes    []string
+	ExpiresAt time.Time
+}
+
+// TwitchID is a local stand-in for the Twitch ID (OAuth) server, for use within tests.
+// Authorization is granted instantly, as whichever login is set through AuthorizeAs.
+type TwitchID struct {
+	ClientID     string
+	ClientSecret string
+	server       *httptest.Server
+	mutex        sync.Mutex
+	login        string
+	userID       string
+	codes        map[string]grant
+	access       map[string]grant
+	refresh      map[string]grant
+}
+
+func NewTwitchID(clientID string, clientSecret string) *TwitchID {
+	id := &TwitchID{
+		ClientID:     clientID,
+		ClientSecret: clientSecret,
+		login:        "broadcaster",
+		userID:       "1",
+		codes:        make(map[string]grant),
+		access:       make(map[string]grant),
+		refresh:      make(map[string]grant),
+	}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/oauth2/authorize", id.authorize)
+	mux.HandleFunc("/oauth2/token", id.token)
+	mux.HandleFunc("/oauth2/validate", id.validate)
+	mux.HandleFunc("/oauth2/revoke", id.revoke)
+
+	id.server = httptest.NewServer(mux)
+	return id
+}
+
+// URL returns the base url to use in place of "https://id.twitch.tv/oauth2/".
+func (r *TwitchID) URL() string {
+	return r.server.URL + "/oauth2/"
+}
+
+func (r *TwitchID) Close() {
+	r.server.Close()
+}
+
+// AuthorizeAs sets the account every following authorization is granted as.
+func (r *TwitchID) AuthorizeAs(login string, userID string) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	r.login, r.userID = login, userID
+}
+
+// Issue creates an access and refresh token for said login directly, skipping the authorization.
+func (r *TwitchID) Issue(login string, userID string, scopes ...string) (string, string) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	return r.issue(grant{Login: login, UserID: userID, Scopes: scopes})
+}
+
+// Expire invalidates said access token, as if its lifetime had passed.
+func (r *TwitchID) Expire(accessToken string) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	delete(r.access, accessToken)
+}
+
+// RevokeRefresh invalidates said refresh token, as if the user had disconnected the application.
+func (r *TwitchID) RevokeRefresh(refreshToken string) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	delete(r.refresh, refreshToken)
+}
+
+// Grant returns the login and scopes said access token was issued for.
+func (r *TwitchID) Grant(accessToken string) (string, []string, bool) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	granted, ok := r.access[accessToken]
+	return granted.Login, granted.Scopes, ok
+}
+
+func (r *TwitchID) authorize(writer http.ResponseWriter, req *http.Request) {
+	query := req.URL.Query()
+	if query.Get("client_id") != r.ClientID || query.Get("response_type") != "code" {
+		http.Error(writer, "invalid client or response type", http.StatusBadRequest)
+		return
+	}
+
+	redirect, err := url.Parse(query.Get("redirect_uri"))
+	if err != nil || redirect.Scheme == "" {
+		http.Error(writer, "invalid redirect_uri", http.StatusBadRequest)
+		return
+	}
+
+	r.mutex.Lock()
+	code := random_token()
+	r.codes[code] = grant{Login: r.login, UserID: r.userID, Scopes: strings.Fields(query.Get("scope"))}
+	r.mutex.Unlock()
+
+	values := redirect.Query()
+	values.Set("code", code)
+	values.Set("state", query.Get("state"))
+	values.Set("scope", query.Get("scope"))
+	redirect.RawQuery = values.Encode()
+	http.Redirect(writer, req, redirect.String(), http.StatusFound)
+}
+
+func (r *TwitchID) token(writer http.ResponseWriter, req *http.Request) {
+	if req.Method != http.MethodPost {
+		http.Error(writer, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	if req.FormValue("client_id") != r.ClientID || req.FormValue("client_secret") != r.ClientSecret {
+		respond(writer, http.StatusForbidden, map[string]any{"status": 403, "message": "invalid client secret"})
+		return
+	}
+
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	var source map[string]grant
+	var key string
+	switch req.FormValue("grant_type") {
+	case "authorization_code":
+		source, key = r.codes, req.FormValue("code")
+	case "refresh_token":
+		source, key = r.refresh, req.FormValue("refresh_token")
+	default:
+		respond(writer, http.StatusBadRequest, map[string]any{"status": 400, "message": "unsupported grant type"})
+		return
+	}
+
+	granted, ok := source[key]
+	if !ok {
+		respond(writer, http.StatusBadRequest, map[string]any{"status": 400, "message": "Invalid refresh token"})
+		return
+	}
+	delete(source, key)
+
+	accessToken, refreshToken := r.issue(granted)
+	respond(writer, http.StatusOK, map[string]any{
+		"access_token":  accessToken,
+		"refresh_token": refreshToken,
+		"expires_in":    uint64(TokenLifetime.Seconds()),
+		"scope":         granted.Scopes,
+		"token_type":    "bearer",
+	})
+}
+
+func (r *TwitchID) validate(writer http.ResponseWriter, req *http.Request) {
+	token := strings.TrimPrefix(req.Header.Get("Authorization"), "OAuth ")
+
+	r.mutex.Lock()
+	granted, ok := r.access[token]
+	r.mutex.Unlock()
+
+	if !ok || time.Now().After(granted.ExpiresAt) {
+		respond(writer, http.StatusUnauthorized, map[string]any{"status": 401, "message": "invalid access token"})
+		return
+	}
+
+	respond(writer, http.StatusOK, map[string]any{
+		"client_id":  r.ClientID,
+		"login":      granted.Login,
+		"scopes":     granted.Scopes,
+		"user_id":    granted.UserID,
+		"expires_in": uint64(time.Until(granted.ExpiresAt).Seconds()),
+	})
+}
+
+func (r *TwitchID) revoke(writer http.ResponseWriter, req *http.Request) {
+	r.mutex.Lock()
+	delete(r.access, req.FormValue("token"))
+	r.mutex.Unlock()
+	writer.WriteHeader(http.StatusOK)
+}
+
+// must be called while holding the mutex
+func (r *TwitchID) issue(granted grant) (string, string) {
+	granted.ExpiresAt = time.Now().Add(TokenLifetime)
+	accessToken, refreshToken := random_token(), random_token()
+	r.access[accessToken] = granted
+	r.refresh[refreshToken] = granted
+	return accessToken, refreshToken
+}
+
+func respond(writer http.ResponseWriter, status int, body any) {
+	writer.Header().Set("Content-Type", "application/json")
+	writer.WriteHeader(status)
+	json.NewEncoder(writer).Encode(body)
+}
+
+func random_token() string {
+	raw := make([]byte, 15)
+	if _, err := rand.Read(raw); err != nil {
+		panic(fmt.Sprintf("could not generate token: %s", err))
+	}
+	return hex.EncodeToString(raw)
+}
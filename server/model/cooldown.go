@@ -0,0 +1,12 @@
+package model
+
+import "time"
+
+// SoundCooldown runs until said time, for a single user unless the user is empty,
+// and for every sound if the sound is empty.
+type SoundCooldown struct {
+	tableName struct{}  `bun:"sound_cooldowns" json:"-"`
+	Sound     string    `bun:"sound,pk" json:"sound"`
+	UserID    string    `bun:"user_id,pk" json:"user_id"`
+	Until     time.Time `bun:"until,notnull" json:"until"`
+}
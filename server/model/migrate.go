@@ -0,0 +1,76 @@
+package model
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/uptrace/bun"
+)
+
+// columns added to a table after its creation, as these aren't added by creating it if absent
+var added_columns = map[string][]string{
+	"users": {
+		"watch_seconds INTEGER NOT NULL DEFAULT 0",
+		"messages INTEGER NOT NULL DEFAULT 0",
+	},
+}
+
+// Migrate creates every table absent, and adds every column absent from those created by an earlier version.
+func Migrate(ctx context.Context, db *bun.DB) error {
+	models := []interface{}{
+		(*User)(nil),
+		(*SoundCooldown)(nil),
+		(*PackOwnership)(nil),
+		(*SoundPlays)(nil),
+		(*SoundUsage)(nil),
+	}
+
+	for _, model := range models {
+		if _, err := db.NewCreateTable().Model(model).IfNotExists().Exec(ctx); err != nil {
+			return err
+		}
+	}
+
+	for table, columns := range added_columns {
+		existing, err := columns_of(ctx, db, table)
+		if err != nil {
+			return err
+		}
+
+		for _, column := range columns {
+			var name string
+			fmt.Sscan(column, &name)
+
+			if existing[name] {
+				continue
+			}
+			if _, err := db.ExecContext(ctx, fmt.Sprintf("ALTER TABLE %s ADD COLUMN %s", table, column)); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+func columns_of(ctx context.Context, db *bun.DB, table string) (map[string]bool, error) {
+	rows, err := db.QueryContext(ctx, fmt.Sprintf("PRAGMA table_info(%s)", table))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	columns := make(map[string]bool)
+	for rows.Next() {
+		var (
+			index        int
+			name, kind   string
+			notNull, key int
+			fallback     any
+		)
+		if err := rows.Scan(&index, &name, &kind, &notNull, &fallback, &key); err != nil {
+			return nil, err
+		}
+		columns[name] = true
+	}
+	return columns, rows.Err()
+}
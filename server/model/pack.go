@@ -0,0 +1,11 @@
+package model
+
+import "time"
+
+// PackOwnership is a pack of sounds unlocked by a user, for good.
+type PackOwnership struct {
+	tableName  struct{}  `bun:"pack_ownerships" json:"-"`
+	Pack       string    `bun:"pack,pk" json:"pack"`
+	UserID     string    `bun:"user_id,pk" json:"user_id"`
+	UnlockedAt time.Time `bun:"unlocked_at,notnull" json:"unlocked_at"`
+}
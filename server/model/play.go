@@ -0,0 +1,12 @@
+package model
+
+import "time"
+
+// SoundPlays counts how often a sound has been played during the stream started at said time,
+// zero if played while offline.
+type SoundPlays struct {
+	tableName struct{}  `bun:"sound_plays" json:"-"`
+	Sound     string    `bun:"sound,pk" json:"sound"`
+	Stream    time.Time `bun:"stream,notnull" json:"stream"`
+	Plays     uint64    `bun:"plays,notnull" json:"plays"`
+}
@@ -0,0 +1,10 @@
+package model
+
+import "time"
+
+// SoundUsage tells when a sound was last played, kept apart from the settings as it changes with every play.
+type SoundUsage struct {
+	tableName struct{}  `bun:"sound_usages" json:"-"`
+	Sound     string    `bun:"sound,pk" json:"sound"`
+	LastUsed  time.Time `bun:"last_used,notnull" json:"last_used"`
+}
@@ -1,7 +1,46 @@
 package model
 
+import (
+	"context"
+
+	"github.com/uptrace/bun"
+)
+
 type User struct {
-	tableName struct{} `bun:"users" json:"-"`
-	ID        uint64   `bun:"id,pk,notnull,unique" json:"id"`
-	Points    uint64   `bun:"points,notnull,default:0" json:"points"`
+	tableName    struct{} `bun:"users" json:"-"`
+	ID           uint64   `bun:"id,pk,notnull,unique" json:"id"`
+	Points       uint64   `bun:"points,notnull,default:0" json:"points"`
+	WatchSeconds uint64   `bun:"watch_seconds,notnull,default:0" json:"watch_seconds"`
+	Messages     uint64   `bun:"messages,notnull,default:0" json:"messages"`
+}
+
+// AddPoints adds said amount of points to said user, creating the user if absent.
+func AddPoints(ctx context.Context, db bun.IDB, id uint64, amount uint64) error {
+	_, err := db.
+		NewInsert().
+		Model(&User{ID: id, Points: amount}).
+		On("CONFLICT (id) DO UPDATE").
+		Set("points = points + ?", amount).
+		Exec(ctx)
+	return err
+}
+
+// SpendPoints takes said amount of points from said user, unless the user has fewer, returning whether it did.
+func SpendPoints(ctx context.Context, db bun.IDB, id uint64, amount uint64) (bool, error) {
+	if amount == 0 {
+		return true, nil
+	}
+
+	result, err := db.
+		NewUpdate().
+		Model((*User)(nil)).
+		Set("points = points - ?", amount).
+		Where("id = ? AND points >= ?", id, amount).
+		Exec(ctx)
+	if err != nil {
+		return false, err
+	}
+
+	affected, err := result.RowsAffected()
+	return affected > 0, err
 }
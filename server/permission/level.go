@@ -0,0 +1,105 @@
+package permission
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/imoliwer/sound-point-twitch-bot/server/app"
+	"github.com/imoliwer/sound-point-twitch-bot/server/request"
+	"github.com/imoliwer/sound-point-twitch-bot/server/twitch_irc"
+)
+
+// how long the level of a user looked up is remembered
+const level_lifetime = 5 * time.Minute
+
+type cached_level struct {
+	level     string
+	fetchedAt time.Time
+}
+
+var (
+	mutex  sync.Mutex
+	levels = make(map[string]cached_level)
+)
+
+// Permits returns whether said level permits what requires said level.
+func Permits(level string, required string) bool {
+	return app.PermissionRank(level) >= app.PermissionRank(required)
+}
+
+// OfState returns the level of the user as told by the tags of a chat message.
+func OfState(state *twitch_irc.UserState) string {
+	switch {
+	case state.Badges.Is(twitch_irc.BadgeBroadcaster):
+		return app.PermissionBroadcaster
+	case state.IsModerator || state.Badges.Is(twitch_irc.BadgeModerator):
+		return app.PermissionModerator
+	case state.Badges.Is(twitch_irc.BadgeVIP):
+		return app.PermissionVIP
+	case state.IsSubscriber:
+		return app.PermissionSubscriber
+	}
+	return app.PermissionEveryone
+}
+
+// Of returns the level of said user on the broadcaster's channel, looked up through Helix when the tags of a
+// chat message aren't at hand (e.g. for redemptions). Nothing is looked up when said required level is everyone's.
+func Of(ctx context.Context, userID string, required string) (string, error) {
+	broadcasterID := request.Profiles.Twitch.UserID()
+	if userID == broadcasterID {
+		return app.PermissionBroadcaster, nil
+	}
+
+	if app.PermissionRank(required) <= 0 {
+		return app.PermissionEveryone, nil
+	}
+
+	mutex.Lock()
+	cached, ok := levels[userID]
+	mutex.Unlock()
+
+	if ok && time.Since(cached.fetchedAt) < level_lifetime {
+		return cached.level, nil
+	}
+
+	level, err := look_up(ctx, broadcasterID, userID)
+	if err != nil {
+		return app.PermissionEveryone, err
+	}
+
+	mutex.Lock()
+	defer mutex.Unlock()
+
+	now := time.Now()
+	for id, cached := range levels {
+		if now.Sub(cached.fetchedAt) > level_lifetime {
+			delete(levels, id)
+		}
+	}
+	levels[userID] = cached_level{level: level, fetchedAt: now}
+	return level, nil
+}
+
+// from the highest level down, as the first one held is the level of the user
+func look_up(ctx context.Context, broadcasterID string, userID string) (string, error) {
+	checks := []struct {
+		level string
+		check func(context.Context, string, string) (bool, error)
+	}{
+		{app.PermissionModerator, request.IsModeratorOf},
+		{app.PermissionVIP, request.IsVIPOf},
+		{app.PermissionSubscriber, request.IsSubscriberOf},
+	}
+
+	for _, candidate := range checks {
+		held, err := candidate.check(ctx, broadcasterID, userID)
+		if err != nil {
+			return app.PermissionEveryone, err
+		}
+		if held {
+			return candidate.level, nil
+		}
+	}
+	return app.PermissionEveryone, nil
+}
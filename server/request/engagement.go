@@ -0,0 +1,44 @@
+package request
+
+import (
+	"context"
+	"net/url"
+	"time"
+)
+
+// the most chatters a single page may hold
+const chatters_page_size = "1000"
+
+type Chatter struct {
+	UserID    string `json:"user_id"`
+	UserLogin string `json:"user_login"`
+	UserName  string `json:"user_name"`
+}
+
+type ChannelFollower struct {
+	UserID     string    `json:"user_id"`
+	UserLogin  string    `json:"user_login"`
+	UserName   string    `json:"user_name"`
+	FollowedAt time.Time `json:"followed_at"`
+}
+
+// ChattersOf returns everyone present in the chat of said broadcaster, as seen by the broadcaster.
+func ChattersOf(ctx context.Context, broadcasterID string) ([]Chatter, error) {
+	return All[Chatter](ctx, Helix, "/chat/chatters", url.Values{
+		"broadcaster_id": {broadcasterID},
+		"moderator_id":   {broadcasterID},
+		"first":          {chatters_page_size},
+	}, 0)
+}
+
+// FollowOf returns the follow of said user on said broadcaster's channel, nil when not following.
+func FollowOf(ctx context.Context, broadcasterID string, userID string) (*ChannelFollower, error) {
+	page, err := Get[ChannelFollower](ctx, Helix, "/channels/followers", url.Values{
+		"broadcaster_id": {broadcasterID},
+		"user_id":        {userID},
+	})
+	if err != nil || len(page.Data) == 0 {
+		return nil, err
+	}
+	return &page.Data[0], nil
+}
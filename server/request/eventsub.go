@@ -0,0 +1,45 @@
+package request
+
+import (
+	"context"
+	"net/http"
+	"net/url"
+	"time"
+)
+
+type EventSubTransport struct {
+	Method    string `json:"method"`
+	SessionID string `json:"session_id,omitempty"`
+}
+
+type EventSubSubscription struct {
+	ID        string            `json:"id,omitempty"`
+	Status    string            `json:"status,omitempty"`
+	Type      string            `json:"type"`
+	Version   string            `json:"version"`
+	Condition map[string]string `json:"condition"`
+	Transport EventSubTransport `json:"transport"`
+	CreatedAt time.Time         `json:"created_at,omitempty"`
+}
+
+// CreateEventSubSubscription subscribes said websocket session to said type of event.
+func CreateEventSubSubscription(ctx context.Context, sessionID string, subscriptionType string, version string, condition map[string]string) (EventSubSubscription, error) {
+	page, err := Call[Page[EventSubSubscription]](ctx, Helix, http.MethodPost, "/eventsub/subscriptions", nil, EventSubSubscription{
+		Type:      subscriptionType,
+		Version:   version,
+		Condition: condition,
+		Transport: EventSubTransport{Method: "websocket", SessionID: sessionID},
+	})
+
+	if err != nil {
+		return EventSubSubscription{}, err
+	}
+	if len(page.Data) == 0 {
+		return EventSubSubscription{}, &APIError{Status: http.StatusNotFound, Err: "Not Found", Message: "no subscription in response"}
+	}
+	return page.Data[0], nil
+}
+
+func DeleteEventSubSubscription(ctx context.Context, id string) error {
+	return Helix.Do(ctx, http.MethodDelete, "/eventsub/subscriptions", url.Values{"id": {id}}, nil, nil)
+}
@@ -0,0 +1,282 @@
+package request
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	// PageSize is the amount of entries requested per page when paginating.
+	PageSize = 100
+	// rate limited requests are retried this many times before giving up
+	rate_limit_retries = 3
+)
+
+// APIError is returned whenever Helix responds with anything but a success.
+type APIError struct {
+	Status  int    `json:"status"`
+	Err     string `json:"error"`
+	Message string `json:"message"`
+}
+
+func (r *APIError) Error() string {
+	if r.Message == "" {
+		return fmt.Sprintf("helix: %d %s", r.Status, r.Err)
+	}
+	return fmt.Sprintf("helix: %d %s: %s", r.Status, r.Err, r.Message)
+}
+
+// IsMissingScope returns whether said error tells the token lacks a scope required, which helix responds
+// with as unauthorized too, though refreshing the token is of no help.
+func IsMissingScope(err error) bool {
+	var apiErr *APIError
+	return errors.As(err, &apiErr) && apiErr.Status == http.StatusUnauthorized && strings.HasPrefix(strings.ToLower(apiErr.Message), "missing scope")
+}
+
+// IsStatus returns whether said error is an APIError of said status.
+func IsStatus(err error, status int) bool {
+	var apiErr *APIError
+	return errors.As(err, &apiErr) && apiErr.Status == status
+}
+
+type Pagination struct {
+	Cursor string `json:"cursor"`
+}
+
+// Page is the envelope most Helix endpoints respond with.
+type Page[T any] struct {
+	Data       []T        `json:"data"`
+	Pagination Pagination `json:"pagination"`
+	Total      int        `json:"total"`
+}
+
+// HelixClient performs requests against the Twitch Helix API on behalf of the account behind its tokens,
+// refreshing them once whenever rejected as invalid and waiting out the rate limit whenever exhausted.
+type HelixClient struct {
+	BaseURL   string
+	HTTP      *http.Client
+	Tokens    *TokenManager
+	mutex     sync.Mutex
+	remaining int
+	reset     time.Time
+}
+
+// Helix is the client of the broadcaster, assigned on startup.
+var Helix *HelixClient
+
+func NewHelixClient(tokens *TokenManager) *HelixClient {
+	return &HelixClient{
+		BaseURL:   helix(""),
+		HTTP:      client,
+		Tokens:    tokens,
+		remaining: -1,
+	}
+}
+
+// Call performs said request and decodes the response into T.
+// A nil body is sent as no body at all, anything else is encoded as json.
+func Call[T any](ctx context.Context, client *HelixClient, method string, path string, query url.Values, body any) (T, error) {
+	var data T
+	err := client.Do(ctx, method, path, query, body, &data)
+	return data, err
+}
+
+// Get fetches a single page of said path.
+func Get[T any](ctx context.Context, client *HelixClient, path string, query url.Values) (Page[T], error) {
+	return Call[Page[T]](ctx, client, http.MethodGet, path, query, nil)
+}
+
+// All fetches every page of said path, following the cursor until exhausted or said limit is reached.
+// A limit of zero fetches every entry.
+func All[T any](ctx context.Context, client *HelixClient, path string, query url.Values, limit int) ([]T, error) {
+	query = clone_values(query)
+	if query.Get("first") == "" {
+		query.Set("first", strconv.Itoa(PageSize))
+	}
+
+	population := make([]T, 0)
+	for {
+		page, err := Get[T](ctx, client, path, query)
+		if err != nil {
+			return population, err
+		}
+
+		population = append(population, page.Data...)
+		if limit > 0 && len(population) >= limit {
+			return population[:limit], nil
+		}
+
+		if page.Pagination.Cursor == "" || len(page.Data) == 0 {
+			return population, nil
+		}
+		query.Set("after", page.Pagination.Cursor)
+	}
+}
+
+// Do performs said request, decoding the response into out (unless nil).
+func (r *HelixClient) Do(ctx context.Context, method string, path string, query url.Values, body any, out any) error {
+	var payload []byte
+	if body != nil {
+		encoded, err := json.Marshal(body)
+		if err != nil {
+			return err
+		}
+		payload = encoded
+	}
+
+	refreshed := false
+	for attempt := 0; ; attempt++ {
+		if err := r.await_rate_limit(ctx); err != nil {
+			return err
+		}
+
+		token := r.Tokens.Profile().OAuthToken
+		err := r.perform(ctx, method, path, query, payload, out)
+
+		switch {
+		case IsMissingScope(err):
+			return err
+		case IsStatus(err, http.StatusUnauthorized) && !refreshed:
+			// the token may have expired in the meantime, validate it (unless refreshed concurrently), which refreshes
+			// it only if invalid, and retry once
+			refreshed = true
+			if r.Tokens.Profile().OAuthToken == token {
+				r.Tokens.Check()
+			}
+			if r.Tokens.Profile().OAuthToken == token {
+				return err
+			}
+		case IsStatus(err, http.StatusTooManyRequests) && attempt < rate_limit_retries:
+			continue // the rate limit has been recorded and is awaited next attempt
+		default:
+			return err
+		}
+	}
+}
+
+// RateLimit returns the requests remaining and when they're reset, as last reported by Helix.
+// The remaining requests are negative until anything has been reported.
+func (r *HelixClient) RateLimit() (int, time.Time) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	return r.remaining, r.reset
+}
+
+//////////////////////
+// HELPER FUNCTIONS //
+//////////////////////
+
+func (r *HelixClient) perform(ctx context.Context, method string, path string, query url.Values, payload []byte, out any) error {
+	target := strings.TrimSuffix(r.BaseURL, "/") + path
+	if len(query) > 0 {
+		target += "?" + query.Encode()
+	}
+
+	var reader io.Reader
+	if payload != nil {
+		reader = bytes.NewReader(payload)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, method, target, reader)
+	if err != nil {
+		return err
+	}
+
+	profile := r.Tokens.Profile()
+	req.Header.Set("Authorization", fmt.Sprintf("Bearer %s", profile.OAuthToken))
+	req.Header.Set("Client-Id", profile.ClientID)
+	if payload != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+
+	res, err := r.HTTP.Do(req)
+	if err != nil {
+		return err
+	}
+	defer res.Body.Close()
+
+	r.record_rate_limit(res.Header)
+	if res.StatusCode == http.StatusTooManyRequests {
+		r.exhaust_rate_limit()
+	}
+
+	if res.StatusCode < 200 || res.StatusCode > 299 {
+		apiErr := &APIError{Status: res.StatusCode, Err: http.StatusText(res.StatusCode)}
+		json.NewDecoder(io.LimitReader(res.Body, 1<<16)).Decode(apiErr)
+		apiErr.Status = res.StatusCode // never trust the body over the actual status
+		return apiErr
+	}
+
+	if out == nil || res.StatusCode == http.StatusNoContent {
+		io.Copy(io.Discard, res.Body)
+		return nil
+	}
+	return json.NewDecoder(res.Body).Decode(out)
+}
+
+func (r *HelixClient) record_rate_limit(header http.Header) {
+	remaining, remainingErr := strconv.Atoi(header.Get("Ratelimit-Remaining"))
+	reset, resetErr := strconv.ParseInt(header.Get("Ratelimit-Reset"), 10, 64)
+	if remainingErr != nil || resetErr != nil {
+		return
+	}
+
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	r.remaining = remaining
+	r.reset = time.Unix(reset, 0)
+}
+
+// mark the rate limit as exhausted, awaiting at least a second if Helix didn't report when it's reset
+func (r *HelixClient) exhaust_rate_limit() {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	r.remaining = 0
+	if !r.reset.After(time.Now()) {
+		r.reset = time.Now().Add(time.Second)
+	}
+}
+
+// wait until the rate limit has been reset, if exhausted
+func (r *HelixClient) await_rate_limit(ctx context.Context) error {
+	r.mutex.Lock()
+	exhausted := r.remaining == 0
+	wait := time.Until(r.reset)
+	r.mutex.Unlock()
+
+	if !exhausted || wait <= 0 {
+		return nil
+	}
+
+	timer := time.NewTimer(wait)
+	defer timer.Stop()
+
+	select {
+	case <-timer.C:
+		r.mutex.Lock()
+		r.remaining = -1 // unknown until reported again
+		r.mutex.Unlock()
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+func clone_values(values url.Values) url.Values {
+	population := make(url.Values, len(values))
+	for key, value := range values {
+		population[key] = append([]string{}, value...)
+	}
+	return population
+}
@@ -0,0 +1,68 @@
+package request_test
+
+import (
+	"context"
+	"net/http"
+	"testing"
+
+	"github.com/imoliwer/sound-point-twitch-bot/server/mock"
+	"github.com/imoliwer/sound-point-twitch-bot/server/request"
+)
+
+func TestRefreshOnceRejected(t *testing.T) {
+	id := mock.NewTwitchID("client", "secret")
+	helix := mock.NewHelix(id)
+	mock.UseHelix(t, helix, "streamer", "42")
+	helix.AddUser(request.TwitchUser{Id: "7", Login: "viewer"})
+
+	expired := request.Profiles.Twitch.Profile().OAuthToken
+	id.Expire(expired)
+
+	user, err := request.TwitchUserBy(context.Background(), "viewer")
+	if err != nil {
+		t.Fatalf("request failed despite refreshing: %s", err)
+	}
+	if user == nil || user.Id != "7" {
+		t.Errorf("got %+v, expected the user of id 7", user)
+	}
+
+	refreshed := request.Profiles.Twitch.Profile().OAuthToken
+	if refreshed == expired {
+		t.Error("token was not refreshed")
+	}
+	if _, _, ok := id.Grant(refreshed); !ok {
+		t.Error("refreshed token is not valid")
+	}
+}
+
+func TestRejectedDespiteRefreshing(t *testing.T) {
+	id := mock.NewTwitchID("client", "secret")
+	helix := mock.NewHelix(id)
+	mock.UseHelix(t, helix, "streamer", "42")
+
+	profile := request.Profiles.Twitch.Profile()
+	id.Expire(profile.OAuthToken)
+	id.RevokeRefresh(profile.RefreshToken)
+
+	_, err := request.TwitchUserBy(context.Background(), "viewer")
+	if !request.IsStatus(err, http.StatusUnauthorized) {
+		t.Fatalf("expected a 401, got %v", err)
+	}
+	if health := request.Profiles.Twitch.Health(); health.Status != request.TokenInvalid {
+		t.Errorf("token is %s, expected it invalid", health.Status)
+	}
+}
+
+func TestMissingScopeKeepsToken(t *testing.T) {
+	helix := mock.NewHelix(mock.NewTwitchID("client", "secret"))
+	mock.UseHelix(t, helix, "streamer", "42") // lacking the scope of managing rewards
+
+	token := request.Profiles.Twitch.Profile().OAuthToken
+	_, err := request.ManageableRewardsOf(context.Background(), "42")
+	if !request.IsMissingScope(err) {
+		t.Fatalf("expected a missing scope, got %v", err)
+	}
+	if refreshed := request.Profiles.Twitch.Profile().OAuthToken; refreshed != token {
+		t.Error("token was refreshed though only lacking a scope")
+	}
+}
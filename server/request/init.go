@@ -14,7 +14,8 @@ type TwitchRequestProfile struct {
 }
 
 type RequestProfiles struct {
-	Twitch TwitchRequestProfile
+	Twitch *TokenManager
+	Bot    *TokenManager // the chat account, whose token is kept without the "oauth:" prefix
 }
 
 type Request struct {
@@ -0,0 +1,115 @@
+package request
+
+import (
+	"context"
+	"net/http"
+	"net/url"
+	"time"
+)
+
+// statuses of a redemption
+const (
+	RedemptionUnfulfilled = "UNFULFILLED"
+	RedemptionFulfilled   = "FULFILLED"
+	RedemptionCanceled    = "CANCELED"
+)
+
+type CustomReward struct {
+	ID                                string `json:"id"`
+	BroadcasterID                     string `json:"broadcaster_id"`
+	Title                             string `json:"title"`
+	Prompt                            string `json:"prompt"`
+	Cost                              uint64 `json:"cost"`
+	IsEnabled                         bool   `json:"is_enabled"`
+	IsUserInputRequired               bool   `json:"is_user_input_required"`
+	ShouldRedemptionsSkipRequestQueue bool   `json:"should_redemptions_skip_request_queue"`
+}
+
+// CustomRewardChanges are the fields of a custom reward to create or update with.
+type CustomRewardChanges struct {
+	Title                             string `json:"title"`
+	Prompt                            string `json:"prompt"`
+	Cost                              uint64 `json:"cost"`
+	IsEnabled                         bool   `json:"is_enabled"`
+	ShouldRedemptionsSkipRequestQueue bool   `json:"should_redemptions_skip_request_queue"`
+}
+
+type RedeemedReward struct {
+	ID     string `json:"id"`
+	Title  string `json:"title"`
+	Prompt string `json:"prompt"`
+	Cost   uint64 `json:"cost"`
+}
+
+type Redemption struct {
+	ID            string         `json:"id"`
+	BroadcasterID string         `json:"broadcaster_id"`
+	UserID        string         `json:"user_id"`
+	UserLogin     string         `json:"user_login"`
+	UserName      string         `json:"user_name"`
+	UserInput     string         `json:"user_input"`
+	Status        string         `json:"status"`
+	Reward        RedeemedReward `json:"reward"`
+	RedeemedAt    time.Time      `json:"redeemed_at"`
+}
+
+// ManageableRewardsOf fetches the custom rewards of said broadcaster that were created by our client,
+// which are the only ones we're allowed to modify.
+func ManageableRewardsOf(ctx context.Context, broadcasterID string) ([]CustomReward, error) {
+	page, err := Get[CustomReward](ctx, Helix, "/channel_points/custom_rewards", url.Values{
+		"broadcaster_id":          {broadcasterID},
+		"only_manageable_rewards": {"true"},
+	})
+	return page.Data, err
+}
+
+func CreateCustomReward(ctx context.Context, broadcasterID string, changes CustomRewardChanges) (CustomReward, error) {
+	return first_reward(Call[Page[CustomReward]](ctx, Helix, http.MethodPost, "/channel_points/custom_rewards", url.Values{
+		"broadcaster_id": {broadcasterID},
+	}, changes))
+}
+
+func UpdateCustomReward(ctx context.Context, broadcasterID string, id string, changes CustomRewardChanges) (CustomReward, error) {
+	return first_reward(Call[Page[CustomReward]](ctx, Helix, http.MethodPatch, "/channel_points/custom_rewards", url.Values{
+		"broadcaster_id": {broadcasterID},
+		"id":             {id},
+	}, changes))
+}
+
+func DeleteCustomReward(ctx context.Context, broadcasterID string, id string) error {
+	return Helix.Do(ctx, http.MethodDelete, "/channel_points/custom_rewards", url.Values{
+		"broadcaster_id": {broadcasterID},
+		"id":             {id},
+	}, nil, nil)
+}
+
+// UnfulfilledRedemptionsOf fetches every redemption of said reward still awaiting being fulfilled or canceled,
+// oldest first.
+func UnfulfilledRedemptionsOf(ctx context.Context, broadcasterID string, rewardID string) ([]Redemption, error) {
+	return All[Redemption](ctx, Helix, "/channel_points/custom_rewards/redemptions", url.Values{
+		"broadcaster_id": {broadcasterID},
+		"reward_id":      {rewardID},
+		"status":         {RedemptionUnfulfilled},
+		"sort":           {"OLDEST"},
+	}, 0)
+}
+
+// UpdateRedemptionStatus fulfils or cancels (refunding the points of) said redemption.
+func UpdateRedemptionStatus(ctx context.Context, broadcasterID string, rewardID string, id string, status string) error {
+	return Helix.Do(ctx, http.MethodPatch, "/channel_points/custom_rewards/redemptions", url.Values{
+		"broadcaster_id": {broadcasterID},
+		"reward_id":      {rewardID},
+		"id":             {id},
+	}, map[string]string{"status": status}, nil)
+}
+
+func first_reward(page Page[CustomReward], err error) (CustomReward, error) {
+	if err != nil {
+		return CustomReward{}, err
+	}
+
+	if len(page.Data) == 0 {
+		return CustomReward{}, &APIError{Status: http.StatusNotFound, Err: "Not Found", Message: "no reward in response"}
+	}
+	return page.Data[0], nil
+}
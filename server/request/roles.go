@@ -0,0 +1,39 @@
+package request
+
+import (
+	"context"
+	"net/url"
+)
+
+type ChannelMember struct {
+	UserID    string `json:"user_id"`
+	UserLogin string `json:"user_login"`
+	UserName  string `json:"user_name"`
+}
+
+type ChannelSubscription struct {
+	ChannelMember
+	Tier   string `json:"tier"`
+	IsGift bool   `json:"is_gift"`
+}
+
+// IsModeratorOf returns whether said user moderates the channel of said broadcaster.
+func IsModeratorOf(ctx context.Context, broadcasterID string, userID string) (bool, error) {
+	return is_member(ctx, "/moderation/moderators", broadcasterID, userID)
+}
+
+// IsVIPOf returns whether said user is a VIP of the channel of said broadcaster.
+func IsVIPOf(ctx context.Context, broadcasterID string, userID string) (bool, error) {
+	return is_member(ctx, "/channels/vips", broadcasterID, userID)
+}
+
+// IsSubscriberOf returns whether said user is subscribed to said broadcaster.
+func IsSubscriberOf(ctx context.Context, broadcasterID string, userID string) (bool, error) {
+	page, err := Get[ChannelSubscription](ctx, Helix, "/subscriptions", url.Values{"broadcaster_id": {broadcasterID}, "user_id": {userID}})
+	return len(page.Data) > 0, err
+}
+
+func is_member(ctx context.Context, path string, broadcasterID string, userID string) (bool, error) {
+	page, err := Get[ChannelMember](ctx, Helix, path, url.Values{"broadcaster_id": {broadcasterID}, "user_id": {userID}})
+	return len(page.Data) > 0, err
+}
@@ -0,0 +1,45 @@
+package request
+
+import (
+	"context"
+	"net/url"
+	"time"
+)
+
+type TwitchStream struct {
+	ID          string    `json:"id"`
+	UserID      string    `json:"user_id"`
+	UserLogin   string    `json:"user_login"`
+	GameID      string    `json:"game_id"`
+	GameName    string    `json:"game_name"`
+	Type        string    `json:"type"`
+	Title       string    `json:"title"`
+	ViewerCount uint64    `json:"viewer_count"`
+	StartedAt   time.Time `json:"started_at"`
+}
+
+type ChannelInformation struct {
+	BroadcasterID    string `json:"broadcaster_id"`
+	BroadcasterLogin string `json:"broadcaster_login"`
+	GameID           string `json:"game_id"`
+	GameName         string `json:"game_name"`
+	Title            string `json:"title"`
+}
+
+// StreamOf returns the live stream of said broadcaster, nil when offline.
+func StreamOf(ctx context.Context, broadcasterID string) (*TwitchStream, error) {
+	page, err := Get[TwitchStream](ctx, Helix, "/streams", url.Values{"user_id": {broadcasterID}, "type": {"live"}})
+	if err != nil || len(page.Data) == 0 {
+		return nil, err
+	}
+	return &page.Data[0], nil
+}
+
+// ChannelInformationOf returns the title and game of said broadcaster's channel, live or not.
+func ChannelInformationOf(ctx context.Context, broadcasterID string) (*ChannelInformation, error) {
+	page, err := Get[ChannelInformation](ctx, Helix, "/channels", url.Values{"broadcaster_id": {broadcasterID}})
+	if err != nil || len(page.Data) == 0 {
+		return nil, err
+	}
+	return &page.Data[0], nil
+}
@@ -28,7 +28,3 @@ type TwitchUser struct {
 	ViewCount       uint64 `json:"view_count"`
 	CreatedAt       string `json:"created_at"`
 }
-
-type TwitchUserList struct {
-	Users []TwitchUser `json:"data"`
-}
@@ -0,0 +1,325 @@
+package request
+
+import (
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/imoliwer/sound-point-twitch-bot/server/scheduler"
+	"github.com/imoliwer/sound-point-twitch-bot/server/util"
+)
+
+const (
+	// RefreshAhead is how long before expiring a token is refreshed.
+	RefreshAhead = 10 * time.Minute
+	// ValidationInterval is how often a token is validated (as required by Twitch).
+	ValidationInterval = time.Hour
+	// a rejected token is refreshed at most once within this duration, as refreshing evidently doesn't help
+	rejection_window  = time.Minute
+	first_retry_delay = 5 * time.Second
+	max_retry_delay   = 5 * time.Minute
+)
+
+const (
+	TokenUnknown  = "unknown"
+	TokenValid    = "valid"
+	TokenRetrying = "retrying"
+	TokenInvalid  = "invalid"
+)
+
+type TokenHealth struct {
+	Account     string    `json:"account"`
+	Login       string    `json:"login,omitempty"`
+	UserID      string    `json:"user_id,omitempty"`
+	Scopes      []string  `json:"scopes,omitempty"` // granted, as of the last validation or refresh
+	Status      string    `json:"status"`
+	ExpiresAt   time.Time `json:"expires_at"`
+	LastRefresh time.Time `json:"last_refresh"`
+	LastError   string    `json:"last_error,omitempty"`
+	Attempts    int       `json:"attempts"`
+}
+
+func (r TokenHealth) Healthy() bool {
+	return r.Status == TokenValid
+}
+
+// TokenManager keeps the token of one account valid: validating it hourly, refreshing it ahead of its
+// expiry and retrying with backoff whenever Twitch can't be reached. Every access is safe for concurrent use.
+type TokenManager struct {
+	account   string
+	persist   func(tokens *TwitchOAuthRefresh) error
+	operation sync.Mutex // serializes validating and refreshing
+	mutex     sync.RWMutex
+	profile   TwitchRequestProfile
+	health    TokenHealth
+	listeners []func(TwitchRequestProfile)
+	refresh   *scheduler.LaterTask
+	validate  *scheduler.RepeatingTask
+	rejected  time.Time
+	stopped   bool
+}
+
+// NewTokenManager creates the manager of said account's profile, calling persist with every pair of
+// tokens obtained (either by refreshing or authorizing) before they're put to use.
+func NewTokenManager(account string, profile TwitchRequestProfile, persist func(tokens *TwitchOAuthRefresh) error) *TokenManager {
+	return &TokenManager{
+		account: account,
+		persist: persist,
+		profile: profile,
+		health:  TokenHealth{Account: account, Status: TokenUnknown},
+	}
+}
+
+func (r *TokenManager) Account() string {
+	return r.account
+}
+
+// Profile returns a copy of the current profile.
+func (r *TokenManager) Profile() TwitchRequestProfile {
+	r.mutex.RLock()
+	defer r.mutex.RUnlock()
+	return r.profile
+}
+
+// UserID returns the id of the user the token belongs to, as of the last validation.
+func (r *TokenManager) UserID() string {
+	r.mutex.RLock()
+	defer r.mutex.RUnlock()
+	return r.health.UserID
+}
+
+func (r *TokenManager) Health() TokenHealth {
+	r.mutex.RLock()
+	defer r.mutex.RUnlock()
+	return r.health
+}
+
+// OnRefresh registers said listener, called with the new profile whenever the tokens change.
+func (r *TokenManager) OnRefresh(listener func(TwitchRequestProfile)) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	r.listeners = append(r.listeners, listener)
+}
+
+// Start validates the token, scheduling its refresh, and keeps validating it hourly until stopped.
+func (r *TokenManager) Start() {
+	r.Check()
+
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	r.validate = scheduler.Every(ValidationInterval, func(_ *scheduler.RepeatingTask) {
+		r.Check()
+	})
+}
+
+func (r *TokenManager) Stop() {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	r.stopped = true
+	if r.refresh != nil {
+		r.refresh.Cancel()
+	}
+	if r.validate != nil {
+		r.validate.Cancel()
+	}
+}
+
+// Check validates the token, refreshing it if rejected.
+func (r *TokenManager) Check() {
+	r.operation.Lock()
+	defer r.operation.Unlock()
+
+	validation, err := ValidateTwitchToken(r.Profile().OAuthToken)
+	switch {
+	case err == nil:
+		r.mutex.Lock()
+		r.health.Status = TokenValid
+		r.health.Login = validation.Login
+		r.health.UserID = validation.UserID
+		r.health.Scopes = validation.Scopes
+		r.health.LastError = ""
+		r.health.Attempts = 0
+		r.health.ExpiresAt = expires_at(validation.ExpiresIn)
+		r.schedule(refresh_delay(validation.ExpiresIn), r.Refresh)
+		r.mutex.Unlock()
+	case errors.Is(err, ErrTokenRejected):
+		r.perform_refresh()
+	default:
+		r.retry(err, r.Check)
+	}
+}
+
+// Refresh exchanges the refresh token for a new pair of tokens.
+func (r *TokenManager) Refresh() {
+	r.operation.Lock()
+	defer r.operation.Unlock()
+	r.perform_refresh()
+}
+
+// RefreshAfterRejection refreshes the token after it was rejected by a service (e.g. the irc server),
+// unless it was rejected just as recently beforehand.
+func (r *TokenManager) RefreshAfterRejection() {
+	r.operation.Lock()
+	defer r.operation.Unlock()
+
+	r.mutex.Lock()
+	recently := time.Since(r.rejected) < rejection_window
+	r.rejected = time.Now()
+	if recently {
+		r.invalidate(errors.New("rejected again right after refreshing"))
+	}
+	r.mutex.Unlock()
+
+	if !recently {
+		r.perform_refresh()
+	}
+}
+
+// Authorize puts the tokens obtained by authorizing to use, persisting them beforehand.
+func (r *TokenManager) Authorize(tokens *TwitchOAuthRefresh) error {
+	r.operation.Lock()
+	defer r.operation.Unlock()
+	return r.apply(tokens)
+}
+
+//////////////////////
+// HELPER FUNCTIONS //
+//////////////////////
+
+// must be called while holding the operation mutex
+func (r *TokenManager) perform_refresh() {
+	profile := r.Profile()
+	if profile.RefreshToken == "" {
+		r.mutex.Lock()
+		r.invalidate(errors.New("the token is invalid and lacks a refresh token"))
+		r.mutex.Unlock()
+		return
+	}
+
+	tokens, err := RefreshTwitchToken(profile)
+	if errors.Is(err, ErrTokenRejected) {
+		r.mutex.Lock()
+		r.invalidate(err)
+		r.mutex.Unlock()
+		return
+	}
+
+	if err != nil {
+		r.retry(err, r.Refresh) // refreshed once reachable, so the listeners learn of it
+		return
+	}
+
+	// attempt to revoke current just to avoid multiple (twitch holds up to 50 access tokens per refresh token)
+	RevokeTwitchToken(profile.OAuthToken)
+
+	if err := r.apply(tokens); err != nil {
+		util.Log("Auth", "Could not save the refreshed %s tokens: %s", r.account, err)
+	}
+	util.Log("Auth", "Refreshed the %s token.", r.account)
+}
+
+// must be called while holding the operation mutex
+func (r *TokenManager) apply(tokens *TwitchOAuthRefresh) error {
+	persistErr := r.persist(tokens)
+
+	r.mutex.Lock()
+	r.profile.OAuthToken = tokens.AccessToken
+	r.profile.RefreshToken = tokens.RefreshToken
+	scopes := tokens.Scopes
+	if scopes == nil {
+		scopes = r.health.Scopes
+	}
+	r.health = TokenHealth{
+		Account:     r.account,
+		Login:       r.health.Login,
+		UserID:      r.health.UserID,
+		Scopes:      scopes,
+		Status:      TokenValid,
+		ExpiresAt:   expires_at(tokens.ExpiresIn),
+		LastRefresh: time.Now(),
+	}
+	r.schedule(refresh_delay(tokens.ExpiresIn), r.Refresh)
+
+	profile := r.profile
+	listeners := append([]func(TwitchRequestProfile){}, r.listeners...)
+	r.mutex.Unlock()
+
+	for _, listener := range listeners {
+		listener(profile)
+	}
+	return persistErr
+}
+
+// retry said action after a delay backing off with every attempt
+// must be called while holding the operation mutex
+func (r *TokenManager) retry(err error, action func()) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	r.health.Status = TokenRetrying
+	r.health.LastError = err.Error()
+	r.health.Attempts++
+
+	delay := retry_delay(r.health.Attempts)
+	util.Log("Auth", "Could not reach Twitch for the %s token, retrying in %s: %s", r.account, delay, err)
+	r.schedule(delay, action)
+}
+
+// must be called while holding the mutex
+func (r *TokenManager) invalidate(err error) {
+	r.health.Status = TokenInvalid
+	r.health.LastError = err.Error()
+	util.Log("Auth", "The %s token is invalid (%s), it has to be authorized again.", r.account, err)
+
+	if r.refresh != nil {
+		r.refresh.Cancel()
+	}
+}
+
+// must be called while holding the mutex, a zero delay leaves the token to the hourly validation
+func (r *TokenManager) schedule(delay time.Duration, action func()) {
+	if r.refresh != nil {
+		r.refresh.Cancel()
+	}
+
+	if r.stopped || delay <= 0 {
+		return
+	}
+
+	r.refresh = scheduler.After(delay, func(_ *scheduler.LaterTask) {
+		action()
+	})
+}
+
+// tokens which don't expire have an expiry of zero
+func refresh_delay(expiresIn uint64) time.Duration {
+	if expiresIn == 0 {
+		return 0
+	}
+
+	lifetime := time.Duration(expiresIn) * time.Second
+	if lifetime <= RefreshAhead {
+		return time.Second
+	}
+	return lifetime - RefreshAhead
+}
+
+func expires_at(expiresIn uint64) time.Time {
+	if expiresIn == 0 {
+		return time.Time{}
+	}
+	return time.Now().Add(time.Duration(expiresIn) * time.Second)
+}
+
+func retry_delay(attempts int) time.Duration {
+	delay := first_retry_delay
+	for attempt := 1; attempt < attempts && delay < max_retry_delay; attempt++ {
+		delay *= 2
+	}
+
+	if delay > max_retry_delay {
+		return max_retry_delay
+	}
+	return delay
+}
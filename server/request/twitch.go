@@ -1,22 +1,30 @@
 package request
 
 import (
+	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"io"
 	"net/url"
+	"strings"
 )
 
-const (
-	base_api_url   string = "https://api.twitch.tv/"
-	base_oauth_url string = "https://id.twitch.tv/oauth2/"
+// base urls of the Twitch API, replaceable to point at a local stand-in (see the mock package)
+var (
+	BaseAPIURL   = "https://api.twitch.tv/"
+	BaseOAuthURL = "https://id.twitch.tv/oauth2/"
 )
 
+// ErrTokenRejected is returned when Twitch rejects a token as invalid, as opposed to not being reachable.
+var ErrTokenRejected = errors.New("token rejected by twitch")
+
 //////////////////////
 //  OAUTH RELATION  //
 //////////////////////
 
-func ValidateTwitchToken(authToken string) *TwitchOAuthValidation {
-	return perform[TwitchOAuthValidation](true, Request{
+func ValidateTwitchToken(authToken string) (*TwitchOAuthValidation, error) {
+	return exchange[TwitchOAuthValidation](Request{
 		Method: "GET",
 		URL:    oauth2("validate"),
 		Headers: map[string]string{
@@ -25,16 +33,16 @@ func ValidateTwitchToken(authToken string) *TwitchOAuthValidation {
 	})
 }
 
-func RefreshCurrentTwitchToken() *TwitchOAuthRefresh {
-	twitchProfile := Profiles.Twitch
-	return perform[TwitchOAuthRefresh](true, Request{
+// RefreshTwitchToken exchanges the refresh token of said profile for a new pair of tokens.
+func RefreshTwitchToken(twitchProfile TwitchRequestProfile) (*TwitchOAuthRefresh, error) {
+	return exchange[TwitchOAuthRefresh](Request{
 		Method: "POST",
 		URL:    oauth2("token"),
 		Query: map[string]string{
 			"client_id":     twitchProfile.ClientID,
 			"client_secret": twitchProfile.ClientSecret,
 			"grant_type":    "refresh_token",
-			"refresh_token": url.QueryEscape(twitchProfile.RefreshToken),
+			"refresh_token": twitchProfile.RefreshToken,
 		},
 		Headers: map[string]string{
 			"Content-Type": "application/x-www-form-urlencoded",
@@ -42,13 +50,28 @@ func RefreshCurrentTwitchToken() *TwitchOAuthRefresh {
 	})
 }
 
-func RevokeTwitchToken(token string) {
-	perform[any](false, Request{
+func TwitchAuthorizeURL(redirectURI string, state string, scopes []string) string {
+	query := url.Values{}
+	query.Set("response_type", "code")
+	query.Set("client_id", Profiles.Twitch.Profile().ClientID)
+	query.Set("redirect_uri", redirectURI)
+	query.Set("scope", strings.Join(scopes, " "))
+	query.Set("state", state)
+	query.Set("force_verify", "true") // allows picking another account than the one logged in
+	return fmt.Sprintf("%s?%s", oauth2("authorize"), query.Encode())
+}
+
+func ExchangeTwitchCode(code string, redirectURI string) (*TwitchOAuthRefresh, error) {
+	twitchProfile := Profiles.Twitch.Profile()
+	return exchange[TwitchOAuthRefresh](Request{
 		Method: "POST",
-		URL:    oauth2("revoke"),
+		URL:    oauth2("token"),
 		Query: map[string]string{
-			"client_id": Profiles.Twitch.ClientID,
-			"token":     url.QueryEscape(token),
+			"client_id":     twitchProfile.ClientID,
+			"client_secret": twitchProfile.ClientSecret,
+			"code":          code,
+			"grant_type":    "authorization_code",
+			"redirect_uri":  redirectURI,
 		},
 		Headers: map[string]string{
 			"Content-Type": "application/x-www-form-urlencoded",
@@ -56,58 +79,70 @@ func RevokeTwitchToken(token string) {
 	})
 }
 
-//////////////////////
-//  USER RELATION   //
-//////////////////////
-
-func TwitchUsersBy(usernames string) *TwitchUserList {
-	requestProfile := Profiles.Twitch
-	return perform[TwitchUserList](true, Request{
-		Method: "GET",
-		URL:    helix("/users"),
+func RevokeTwitchToken(token string) error {
+	_, err := exchange[struct{}](Request{
+		Method: "POST",
+		URL:    oauth2("revoke"),
 		Query: map[string]string{
-			"login": usernames,
+			"client_id": Profiles.Twitch.Profile().ClientID,
+			"token":     token,
 		},
 		Headers: map[string]string{
-			"Authorization": fmt.Sprintf("Bearer %s", requestProfile.OAuthToken),
-			"Client-ID":     requestProfile.ClientID,
+			"Content-Type": "application/x-www-form-urlencoded",
 		},
 	})
+	return err
 }
 
-func TwitchUserBy(username string) *TwitchUser {
-	return TwitchUsersBy(username).First()
+//////////////////////
+//  USER RELATION   //
+//////////////////////
+
+// TwitchUsersBy fetches the users of said logins (up to 100), leaving out those who don't exist.
+func TwitchUsersBy(ctx context.Context, logins ...string) ([]TwitchUser, error) {
+	page, err := Get[TwitchUser](ctx, Helix, "/users", url.Values{"login": logins})
+	return page.Data, err
 }
 
-func (r *TwitchUserList) First() *TwitchUser {
-	users := r.Users
-	if len(users) > 0 {
-		return &users[0]
+// TwitchUserBy fetches the user of said login, returning nil if there's no such user.
+func TwitchUserBy(ctx context.Context, login string) (*TwitchUser, error) {
+	users, err := TwitchUsersBy(ctx, login)
+	if err != nil || len(users) == 0 {
+		return nil, err
 	}
-	return nil
+	return &users[0], nil
 }
 
 //////////////////////
 // HELPER FUNCTIONS //
 //////////////////////
 
-func perform[T interface{}](shouldParse bool, request Request) *T {
-	req := Build(request)
-	res, err := client.Do(req)
+// perform said oauth request, telling a rejected token apart from any other failure
+func exchange[T interface{}](request Request) (*T, error) {
+	res, err := client.Do(Build(request))
+	if err != nil {
+		return nil, err
+	}
+	defer res.Body.Close()
 
-	if err != nil || !shouldParse || (res.StatusCode != 200 && res.StatusCode != 202) {
-		return nil
+	switch {
+	case res.StatusCode == 400 || res.StatusCode == 401 || res.StatusCode == 403:
+		return nil, fmt.Errorf("%w (status %d)", ErrTokenRejected, res.StatusCode)
+	case res.StatusCode != 200:
+		return nil, fmt.Errorf("unexpected status %d", res.StatusCode)
 	}
 
 	var data T
-	json.NewDecoder(res.Body).Decode(&data)
-	return &data
+	if err := json.NewDecoder(res.Body).Decode(&data); err != nil && !errors.Is(err, io.EOF) {
+		return nil, err
+	}
+	return &data, nil
 }
 
 func helix(path string) string {
-	return fmt.Sprintf("%shelix%s", base_api_url, path)
+	return fmt.Sprintf("%shelix%s", BaseAPIURL, path)
 }
 
 func oauth2(path string) string {
-	return fmt.Sprintf("%s%s", base_oauth_url, path)
+	return fmt.Sprintf("%s%s", BaseOAuthURL, path)
 }
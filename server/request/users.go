@@ -0,0 +1,323 @@
+package request
+
+import (
+	"container/list"
+	"context"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	// UserTTL is how long a cached user is trusted, as logins and display names may change.
+	UserTTL = time.Hour
+	// UserCapacity is how many users are cached before the least recently used are evicted.
+	UserCapacity = 5000
+	// MissingTTL is how long a user not found is trusted to not exist, shorter as logins are freed up over time.
+	MissingTTL = 5 * time.Minute
+	// BatchWindow is how long lookups are collected before being sent together.
+	BatchWindow = 15 * time.Millisecond
+	// how long a batch may take, independent of the lookups awaiting it
+	batch_timeout = 15 * time.Second
+)
+
+var (
+	// helix rejects the whole batch if any login or id is malformed, so such are never sent
+	valid_login = regexp.MustCompile("^[a-z0-9_]{1,25}$")
+	valid_id    = regexp.MustCompile("^[0-9]+$")
+)
+
+type CachedUser struct {
+	ID          string `json:"id"`
+	Login       string `json:"login"`
+	DisplayName string `json:"display_name"`
+}
+
+type cached_entry struct {
+	user      CachedUser
+	expiresAt time.Time
+}
+
+type user_batch struct {
+	logins map[string]bool
+	ids    map[string]bool
+	done   chan struct{}
+	err    error
+}
+
+// UserCache maps logins, ids and display names onto one another, expiring entries after a while and
+// evicting the least recently used when full. Lookups of uncached users are batched into a single request.
+type UserCache struct {
+	ttl        time.Duration
+	capacity   int
+	mutex      sync.Mutex
+	order      *list.List // most recently used at the front
+	byLogin    map[string]*list.Element
+	byID       map[string]*list.Element
+	missing    map[string]time.Time // when each user not found may be looked up again, keyed as in flight
+	collecting *user_batch
+	inFlight   map[string]*user_batch // keyed as login:<login> or id:<id>
+}
+
+// Users is the cache used when looking up users, warmed by the chat.
+var Users = NewUserCache(UserTTL, UserCapacity)
+
+func NewUserCache(ttl time.Duration, capacity int) *UserCache {
+	return &UserCache{
+		ttl:      ttl,
+		capacity: capacity,
+		order:    list.New(),
+		byLogin:  make(map[string]*list.Element),
+		byID:     make(map[string]*list.Element),
+		missing:  make(map[string]time.Time),
+		inFlight: make(map[string]*user_batch),
+	}
+}
+
+// Remember caches said user, e.g. as seen in chat.
+func (r *UserCache) Remember(user CachedUser) {
+	if user.ID == "" || user.Login == "" {
+		return
+	}
+
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	r.store(user)
+}
+
+// ByLogin returns the user of said login, with or without a leading '@', returning nil if there's no such user.
+func (r *UserCache) ByLogin(ctx context.Context, login string) (*CachedUser, error) {
+	login = NormalizedLogin(login)
+	if !valid_login.MatchString(login) {
+		return nil, nil
+	}
+	return r.lookup(ctx, "login:", login)
+}
+
+// ByID returns the user of said id, returning nil if there's no such user.
+func (r *UserCache) ByID(ctx context.Context, id string) (*CachedUser, error) {
+	id = strings.TrimSpace(id)
+	if !valid_id.MatchString(id) {
+		return nil, nil
+	}
+	return r.lookup(ctx, "id:", id)
+}
+
+// NormalizedLogin returns said login as helix knows it, lower-cased and without a leading '@' as in mentions.
+func NormalizedLogin(login string) string {
+	return strings.ToLower(strings.TrimPrefix(strings.TrimSpace(login), "@"))
+}
+
+// Len returns the amount of users cached, including those expired but not yet evicted.
+func (r *UserCache) Len() int {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	return r.order.Len()
+}
+
+//////////////////////
+// HELPER FUNCTIONS //
+//////////////////////
+
+func (r *UserCache) lookup(ctx context.Context, kind string, value string) (*CachedUser, error) {
+	r.mutex.Lock()
+	if user, ok := r.cached(kind, value); ok {
+		r.mutex.Unlock()
+		return &user, nil
+	}
+	if r.known_missing(kind + value) {
+		r.mutex.Unlock()
+		return nil, nil
+	}
+
+	batch, ok := r.inFlight[kind+value]
+	if !ok {
+		batch = r.enqueue(kind, value)
+	}
+	r.mutex.Unlock()
+
+	select {
+	case <-batch.done:
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+
+	if batch.err != nil {
+		return nil, batch.err
+	}
+
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	if user, ok := r.cached(kind, value); ok {
+		return &user, nil
+	}
+	return nil, nil
+}
+
+// must be called while holding the mutex
+func (r *UserCache) cached(kind string, value string) (CachedUser, bool) {
+	index := r.byLogin
+	if kind == "id:" {
+		index = r.byID
+	}
+
+	element, ok := index[value]
+	if !ok {
+		return CachedUser{}, false
+	}
+
+	entry := element.Value.(*cached_entry)
+	if time.Now().After(entry.expiresAt) {
+		r.remove(element)
+		return CachedUser{}, false
+	}
+
+	r.order.MoveToFront(element)
+	return entry.user, true
+}
+
+// must be called while holding the mutex
+func (r *UserCache) known_missing(key string) bool {
+	until, ok := r.missing[key]
+	if ok && time.Now().After(until) {
+		delete(r.missing, key)
+		return false
+	}
+	return ok
+}
+
+// must be called while holding the mutex
+func (r *UserCache) enqueue(kind string, value string) *user_batch {
+	batch := r.collecting
+	if batch == nil {
+		batch = &user_batch{
+			logins: make(map[string]bool),
+			ids:    make(map[string]bool),
+			done:   make(chan struct{}),
+		}
+		r.collecting = batch
+		time.AfterFunc(BatchWindow, func() { r.send(batch) })
+	}
+
+	if kind == "id:" {
+		batch.ids[value] = true
+	} else {
+		batch.logins[value] = true
+	}
+	r.inFlight[kind+value] = batch
+
+	if len(batch.logins)+len(batch.ids) >= PageSize {
+		go r.send(batch) // helix accepts no more per request, send it right away
+	}
+	return batch
+}
+
+func (r *UserCache) send(batch *user_batch) {
+	r.mutex.Lock()
+	if r.collecting != batch {
+		r.mutex.Unlock()
+		return // already sent
+	}
+	r.collecting = nil
+	r.mutex.Unlock()
+
+	ctx, cancel := context.WithTimeout(context.Background(), batch_timeout)
+	defer cancel()
+
+	logins := make([]string, 0, len(batch.logins))
+	for login := range batch.logins {
+		logins = append(logins, login)
+	}
+
+	ids := make([]string, 0, len(batch.ids))
+	for id := range batch.ids {
+		ids = append(ids, id)
+	}
+
+	page, err := Get[TwitchUser](ctx, Helix, "/users", map[string][]string{"login": logins, "id": ids})
+
+	r.mutex.Lock()
+	for _, user := range page.Data {
+		r.store(CachedUser{ID: user.Id, Login: user.Login, DisplayName: user.DisplayName})
+	}
+	for login := range batch.logins {
+		r.settle(err, "login:", login)
+	}
+	for id := range batch.ids {
+		r.settle(err, "id:", id)
+	}
+	r.mutex.Unlock()
+
+	batch.err = err
+	close(batch.done)
+}
+
+// must be called while holding the mutex
+func (r *UserCache) settle(err error, kind string, value string) {
+	delete(r.inFlight, kind+value)
+	if err != nil {
+		return // unknown whether the user exists
+	}
+
+	if _, found := r.cached(kind, value); !found {
+		r.forget_missing()
+		r.missing[kind+value] = time.Now().Add(MissingTTL)
+	}
+}
+
+// must be called while holding the mutex
+func (r *UserCache) forget_missing() {
+	if r.capacity <= 0 || len(r.missing) < r.capacity {
+		return
+	}
+
+	now := time.Now()
+	for key, until := range r.missing {
+		if now.After(until) {
+			delete(r.missing, key)
+		}
+	}
+	for key := range r.missing {
+		if len(r.missing) < r.capacity {
+			break
+		}
+		delete(r.missing, key) // none expired, drop whichever to stay bounded
+	}
+}
+
+// must be called while holding the mutex
+func (r *UserCache) store(user CachedUser) {
+	user.Login = strings.ToLower(user.Login)
+	delete(r.missing, "login:"+user.Login)
+	delete(r.missing, "id:"+user.ID)
+
+	// a login may have been taken over by another user (or the user renamed), drop what's outdated
+	if element, ok := r.byLogin[user.Login]; ok {
+		r.remove(element)
+	}
+	if element, ok := r.byID[user.ID]; ok {
+		r.remove(element)
+	}
+
+	element := r.order.PushFront(&cached_entry{user: user, expiresAt: time.Now().Add(r.ttl)})
+	r.byLogin[user.Login] = element
+	r.byID[user.ID] = element
+
+	for r.capacity > 0 && r.order.Len() > r.capacity {
+		r.remove(r.order.Back())
+	}
+}
+
+// must be called while holding the mutex
+func (r *UserCache) remove(element *list.Element) {
+	entry := element.Value.(*cached_entry)
+	r.order.Remove(element)
+
+	if r.byLogin[entry.user.Login] == element {
+		delete(r.byLogin, entry.user.Login)
+	}
+	if r.byID[entry.user.ID] == element {
+		delete(r.byID, entry.user.ID)
+	}
+}
@@ -0,0 +1,116 @@
+package request_test
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/imoliwer/sound-point-twitch-bot/server/mock"
+	"github.com/imoliwer/sound-point-twitch-bot/server/request"
+)
+
+func TestLeastRecentlyUsedEvicted(t *testing.T) {
+	helix := mock.NewHelix(mock.NewTwitchID("client", "secret"))
+	mock.UseHelix(t, helix, "streamer", "42")
+	helix.AddUser(request.TwitchUser{Id: "2", Login: "second"})
+
+	users := request.NewUserCache(time.Hour, 2)
+	users.Remember(request.CachedUser{ID: "1", Login: "first"})
+	users.Remember(request.CachedUser{ID: "2", Login: "second"})
+	if _, err := users.ByLogin(context.Background(), "first"); err != nil {
+		t.Fatal(err)
+	}
+	users.Remember(request.CachedUser{ID: "3", Login: "third"})
+
+	if users.Len() != 2 {
+		t.Errorf("%d users cached, expected 2", users.Len())
+	}
+	for _, lookup := range []struct {
+		login    string
+		requests int
+	}{
+		{"first", 0},
+		{"third", 0},
+		{"second", 1}, // evicted, so looked up
+	} {
+		user, err := users.ByLogin(context.Background(), lookup.login)
+		if err != nil || user == nil || user.Login != lookup.login {
+			t.Errorf("%s looked up as %+v (%v)", lookup.login, user, err)
+		}
+		if requests := helix.Requests("/users"); requests != lookup.requests {
+			t.Errorf("%d requests after looking up %s, expected %d", requests, lookup.login, lookup.requests)
+		}
+	}
+}
+
+func TestExpiredUserLookedUpAgain(t *testing.T) {
+	helix := mock.NewHelix(mock.NewTwitchID("client", "secret"))
+	mock.UseHelix(t, helix, "streamer", "42")
+	helix.AddUser(request.TwitchUser{Id: "7", Login: "viewer", DisplayName: "Renamed"})
+
+	users := request.NewUserCache(time.Millisecond, 10)
+	users.Remember(request.CachedUser{ID: "7", Login: "viewer", DisplayName: "Viewer"})
+	time.Sleep(5 * time.Millisecond)
+
+	user, err := users.ByID(context.Background(), "7")
+	if err != nil || user == nil || user.DisplayName != "Renamed" {
+		t.Errorf("looked up as %+v (%v), expected the renamed user", user, err)
+	}
+	if requests := helix.Requests("/users"); requests != 1 {
+		t.Errorf("%d requests, expected the expired user looked up once", requests)
+	}
+}
+
+func TestLookupsBatched(t *testing.T) {
+	helix := mock.NewHelix(mock.NewTwitchID("client", "secret"))
+	mock.UseHelix(t, helix, "streamer", "42")
+	helix.AddUser(request.TwitchUser{Id: "7", Login: "viewer"})
+	helix.AddUser(request.TwitchUser{Id: "8", Login: "lurker"})
+
+	users := request.NewUserCache(time.Hour, 10)
+	lookups := map[string]string{
+		"@Viewer":    "7",
+		"lurker":     "8",
+		"ghost":      "",
+		"not a name": "",
+	}
+
+	var wait sync.WaitGroup
+	var mutex sync.Mutex
+	found := make(map[string]string)
+	for login := range lookups {
+		wait.Add(1)
+		go func(login string) {
+			defer wait.Done()
+			user, err := users.ByLogin(context.Background(), login)
+			if err != nil {
+				t.Errorf("%s failed: %s", login, err)
+				return
+			}
+
+			mutex.Lock()
+			defer mutex.Unlock()
+			if user != nil {
+				found[login] = user.ID
+			}
+		}(login)
+	}
+	wait.Wait()
+
+	for login, id := range lookups {
+		if found[login] != id {
+			t.Errorf("%q found as %q, expected %q", login, found[login], id)
+		}
+	}
+	if requests := helix.Requests("/users"); requests != 1 {
+		t.Errorf("%d requests, expected the lookups batched into one", requests)
+	}
+
+	if user, err := users.ByLogin(context.Background(), "ghost"); user != nil || err != nil {
+		t.Errorf("ghost looked up again as %+v (%v)", user, err)
+	}
+	if requests := helix.Requests("/users"); requests != 1 {
+		t.Errorf("%d requests, expected the missing user cached", requests)
+	}
+}
@@ -0,0 +1,544 @@
+package reward
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/imoliwer/sound-point-twitch-bot/server/app"
+	"github.com/imoliwer/sound-point-twitch-bot/server/engagement"
+	"github.com/imoliwer/sound-point-twitch-bot/server/model"
+	"github.com/imoliwer/sound-point-twitch-bot/server/permission"
+	"github.com/imoliwer/sound-point-twitch-bot/server/request"
+	"github.com/imoliwer/sound-point-twitch-bot/server/scheduler"
+	"github.com/imoliwer/sound-point-twitch-bot/server/sound"
+	"github.com/imoliwer/sound-point-twitch-bot/server/twitch_irc"
+	"github.com/imoliwer/sound-point-twitch-bot/server/util"
+)
+
+const (
+	// PollInterval is how often the rewards are synced (if changed) and their redemptions fetched.
+	PollInterval = 15 * time.Second
+	// how long a handled redemption is remembered once its status is set, guarding against handling it twice
+	handled_lifetime = time.Hour
+	request_timeout  = 30 * time.Second
+)
+
+const (
+	kind_sound      = "sound"
+	kind_conversion = "conversion"
+)
+
+// a redemption deployed (or converted, or refunded), along with the status yet to be set if updating it failed
+type handled_redemption struct {
+	redemption request.Redemption
+	at         time.Time
+	pending    string
+}
+
+// a custom reward as it should exist on twitch
+type mirrored struct {
+	kind     string
+	name     string // of the sound
+	points   uint64 // of the conversion, as of the settings it's mirrored from
+	rewardID string
+	changes  request.CustomRewardChanges
+}
+
+// Manager mirrors the sounds (and points conversions) as Channel Points custom rewards, and handles
+// their redemptions: fulfilling them once deployed (or converted) and refunding them otherwise.
+type Manager struct {
+	app       *app.Application
+	cover     *sound.DeploymentCover
+	mutex     sync.Mutex // serializes syncing and handling
+	synced    string     // fingerprint of the rewards last synced
+	handled   map[string]handled_redemption
+	task      *scheduler.RepeatingTask
+	pushed    func() bool // whether redemptions are currently pushed to us, in place of polling
+	tracker   *engagement.Tracker
+	cooldowns *sound.Cooldowns
+}
+
+func NewManager(application *app.Application, cover *sound.DeploymentCover) *Manager {
+	return &Manager{
+		app:     application,
+		cover:   cover,
+		handled: make(map[string]handled_redemption),
+	}
+}
+
+// Start syncs the rewards and polls their redemptions every said interval until stopped.
+func (r *Manager) Start(interval time.Duration) {
+	go r.poll()
+	r.task = scheduler.Every(interval, func(_ *scheduler.RepeatingTask) {
+		r.poll()
+	})
+}
+
+// UsePush skips polling the redemptions whilst said function tells they're pushed to us (e.g. by EventSub),
+// in which case they're expected to be passed to Handle.
+func (r *Manager) UsePush(pushed func() bool) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	r.pushed = pushed
+}
+
+// UseEngagement refunds the redemptions of sounds whose requirements the redeemer doesn't meet, as checked
+// against said tracker.
+func (r *Manager) UseEngagement(tracker *engagement.Tracker) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	r.tracker = tracker
+}
+
+// UseCooldowns refunds the redemptions of sounds on cooldown, and starts their cooldowns once played.
+func (r *Manager) UseCooldowns(cooldowns *sound.Cooldowns) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	r.cooldowns = cooldowns
+}
+
+func (r *Manager) Stop() {
+	if r.task != nil {
+		r.task.Cancel()
+	}
+}
+
+// Sync creates, updates and deletes the custom rewards on twitch to match the settings.
+func (r *Manager) Sync(ctx context.Context) error {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	return r.sync(ctx)
+}
+
+// Handle deploys the sound (or converts into bot points) of said redemption, fulfilling the redemption
+// if it succeeded and refunding it otherwise. Redemptions of rewards not managed by us are ignored.
+func (r *Manager) Handle(ctx context.Context, redemption request.Redemption) error {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	return r.handle(ctx, redemption)
+}
+
+func (r *Manager) Handler(engine *gin.Engine) {
+	engine.POST("/rewards/sync", func(ctx *gin.Context) {
+		timeout, cancel := context.WithTimeout(ctx.Request.Context(), request_timeout)
+		defer cancel()
+
+		if err := r.Sync(timeout); err != nil {
+			ctx.String(http.StatusBadGateway, "failed syncing rewards: %s", err)
+			return
+		}
+		ctx.String(http.StatusOK, "rewards have been synced")
+	})
+}
+
+//////////////////////
+// HELPER FUNCTIONS //
+//////////////////////
+
+func (r *Manager) poll() {
+	ctx, cancel := context.WithTimeout(context.Background(), request_timeout)
+	defer cancel()
+
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	if fingerprint_of(r.app.Settings()) != r.synced {
+		if err := r.sync(ctx); err != nil {
+			util.Log("Rewards", "Could not sync rewards: %s", err)
+			return
+		}
+	}
+
+	// never handled again, only their status is set
+	for id, handled := range r.handled {
+		if handled.pending == "" {
+			continue
+		}
+		if err := r.update_status(ctx, id); err != nil {
+			util.Log("Rewards", "Could not update redemption of '%s' again: %s", handled.redemption.Reward.Title, err)
+		}
+	}
+
+	if r.pushed != nil && r.pushed() {
+		return
+	}
+
+	broadcasterID := request.Profiles.Twitch.UserID()
+	for _, reward := range desired_of(r.app.Settings()) {
+		if reward.rewardID == "" {
+			continue
+		}
+
+		redemptions, err := request.UnfulfilledRedemptionsOf(ctx, broadcasterID, reward.rewardID)
+		if err != nil {
+			util.Log("Rewards", "Could not fetch redemptions of '%s': %s", reward.changes.Title, err)
+			continue
+		}
+
+		for _, redemption := range redemptions {
+			if err := r.handle(ctx, redemption); err != nil {
+				util.Log("Rewards", "Could not handle redemption of '%s': %s", reward.changes.Title, err)
+			}
+		}
+	}
+}
+
+// must be called while holding the mutex
+func (r *Manager) sync(ctx context.Context) error {
+	settings := r.app.Settings()
+	broadcasterID := request.Profiles.Twitch.UserID()
+	if broadcasterID == "" {
+		return errors.New("the id of the broadcaster is not known yet")
+	}
+
+	existing, err := request.ManageableRewardsOf(ctx, broadcasterID)
+	if err != nil {
+		return err
+	}
+
+	byID := make(map[string]request.CustomReward, len(existing))
+	for _, reward := range existing {
+		byID[reward.ID] = reward
+	}
+
+	claimed := make(map[string]bool)
+	assigned := make(map[*mirrored]string)
+	desired := desired_of(settings)
+
+	for index := range desired {
+		reward := &desired[index]
+		current, ok := byID[reward.rewardID]
+
+		// adopt a reward of the same title, e.g. when its id got lost from the settings
+		if !ok {
+			for _, candidate := range existing {
+				if !claimed[candidate.ID] && strings.EqualFold(candidate.Title, reward.changes.Title) {
+					current, ok = candidate, true
+					break
+				}
+			}
+		}
+
+		switch {
+		case !ok:
+			created, err := request.CreateCustomReward(ctx, broadcasterID, reward.changes)
+			if err != nil {
+				return fmt.Errorf("creating '%s': %w", reward.changes.Title, err)
+			}
+			util.Log("Rewards", "Created reward '%s'.", created.Title)
+			current = created
+		case differs(current, reward.changes):
+			if _, err := request.UpdateCustomReward(ctx, broadcasterID, current.ID, reward.changes); err != nil {
+				return fmt.Errorf("updating '%s': %w", reward.changes.Title, err)
+			}
+			util.Log("Rewards", "Updated reward '%s'.", reward.changes.Title)
+		}
+
+		claimed[current.ID] = true
+		assigned[reward] = current.ID
+	}
+
+	// every reward created by our client is mirrored from the settings, so those unclaimed are outdated
+	for _, reward := range existing {
+		if claimed[reward.ID] {
+			continue
+		}
+
+		if err := request.DeleteCustomReward(ctx, broadcasterID, reward.ID); err != nil && !request.IsStatus(err, http.StatusNotFound) {
+			return fmt.Errorf("deleting '%s': %w", reward.Title, err)
+		}
+		util.Log("Rewards", "Deleted reward '%s'.", reward.Title)
+	}
+
+	var changed bool
+	r.app.UpdateSettings(func(settings *app.Settings) {
+		changed = assign_ids(settings, assigned)
+	})
+	if changed {
+		r.app.RequestSave()
+	}
+
+	r.synced = fingerprint_of(r.app.Settings())
+	return nil
+}
+
+// must be called while holding the mutex
+func (r *Manager) handle(ctx context.Context, redemption request.Redemption) error {
+	now := time.Now()
+	for id, handled := range r.handled {
+		if handled.pending == "" && now.Sub(handled.at) > handled_lifetime {
+			delete(r.handled, id)
+		}
+	}
+
+	if handled, ok := r.handled[redemption.ID]; ok {
+		if handled.pending == "" {
+			return nil
+		}
+		return r.update_status(ctx, redemption.ID)
+	}
+
+	var reward *mirrored
+	desired := desired_of(r.app.Settings())
+	for index := range desired {
+		if desired[index].rewardID != "" && desired[index].rewardID == redemption.Reward.ID {
+			reward = &desired[index]
+			break
+		}
+	}
+
+	if reward == nil {
+		return nil // not one of ours
+	}
+
+	var outcome error
+	switch reward.kind {
+	case kind_sound:
+		outcome = r.deploy(ctx, reward.name, redemption)
+	case kind_conversion:
+		outcome = r.convert(ctx, reward.points, redemption)
+	}
+
+	status := request.RedemptionFulfilled
+	if outcome != nil {
+		status = request.RedemptionCanceled
+		util.Log("Rewards", "Refunding '%s' redeemed by %s: %s", redemption.Reward.Title, redemption.UserName, outcome)
+	}
+
+	// remembered until its status is set, as deploying twice is worse than leaving it in the queue
+	r.handled[redemption.ID] = handled_redemption{redemption: redemption, at: now, pending: status}
+	if err := r.update_status(ctx, redemption.ID); err != nil {
+		return err
+	}
+
+	if outcome == nil {
+		util.Log("Rewards", "Fulfilled '%s' redeemed by %s.", redemption.Reward.Title, redemption.UserName)
+	}
+	return nil
+}
+
+// set the status pending of said handled redemption, given up on once the redemption isn't unfulfilled anymore
+// must be called while holding the mutex
+func (r *Manager) update_status(ctx context.Context, id string) error {
+	handled := r.handled[id]
+	redemption := handled.redemption
+
+	err := request.UpdateRedemptionStatus(ctx, redemption.BroadcasterID, redemption.Reward.ID, id, handled.pending)
+	if err != nil && !request.IsStatus(err, http.StatusNotFound) {
+		return err
+	}
+
+	handled.pending, handled.at = "", time.Now()
+	r.handled[id] = handled
+	return nil
+}
+
+func (r *Manager) deploy(ctx context.Context, name string, redemption request.Redemption) error {
+	reference, ok := r.app.Settings().Audio.References[name]
+	if !ok {
+		return fmt.Errorf("sound '%s' no longer exists", name)
+	}
+
+	if !reference.IsEnabled() {
+		return fmt.Errorf("sound '%s' is disabled", name)
+	}
+
+	level, err := permission.Of(ctx, redemption.UserID, reference.Permission)
+	if err != nil {
+		return err
+	}
+	if !permission.Permits(level, reference.Permission) {
+		return fmt.Errorf("sound '%s' requires the permission of %s", name, reference.Permission)
+	}
+
+	if r.tracker != nil {
+		meets, err := r.tracker.Meets(ctx, redemption.UserID, reference.Requirements)
+		if err != nil {
+			return err
+		}
+		if !meets {
+			return errors.New("requirements of the sound not met")
+		}
+	}
+
+	// the cooldowns and the play are reserved at once, so redemptions at a time can't both pass
+	var reservation *sound.CooldownReservation
+	if r.cooldowns != nil {
+		reserved, remaining, ok := r.cooldowns.Reserve(name, redemption.UserID)
+		if !ok {
+			return fmt.Errorf("sound '%s' is on cooldown for another %s", name, remaining.Round(time.Second))
+		}
+		reservation = reserved
+	}
+
+	if _, ok := r.cover.Plays.Attempt(name, reference.MaxPlaysPerStream); !ok {
+		r.release(reservation)
+		return fmt.Errorf("sound '%s' was played the most times allowed this stream", name)
+	}
+
+	err = r.cover.Deploy(sound.NewRealDeployment(r.app, name, reference, redemption.Reward.Cost, &twitch_irc.UserState{
+		Id:          redemption.UserID,
+		DisplayName: redemption.UserName,
+		Login:       redemption.UserLogin,
+	}))
+	if err != nil {
+		r.cover.Plays.Undo(name)
+		r.release(reservation)
+		return err
+	}
+
+	r.cover.Played(name, reference)
+	if reservation != nil {
+		if err := r.cooldowns.Keep(ctx, reservation); err != nil {
+			util.Log("Rewards", "Could not save the cooldowns of '%s': %s", name, err)
+		}
+	}
+	return nil
+}
+
+// take back said reservation of cooldowns, if cooldowns are in use
+func (r *Manager) release(reservation *sound.CooldownReservation) {
+	if reservation != nil {
+		r.cooldowns.Release(reservation)
+	}
+}
+
+func (r *Manager) convert(ctx context.Context, points uint64, redemption request.Redemption) error {
+	userID, err := util.Uint64(redemption.UserID)
+	if err != nil {
+		return err
+	}
+
+	return model.AddPoints(ctx, r.app.Database, userID, points)
+}
+
+// the rewards that should exist according to said settings, in a stable order
+func desired_of(settings *app.Settings) []mirrored {
+	channelPoints := settings.ChannelPoints
+	if !channelPoints.Enabled {
+		return nil
+	}
+
+	population := make([]mirrored, 0)
+	names := make([]string, 0, len(settings.Audio.References))
+	for name := range settings.Audio.References {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		reference := settings.Audio.References[name]
+		reward := reference.Reward
+		if reward == nil || !reward.Enabled {
+			continue
+		}
+
+		population = append(population, mirrored{
+			kind:     kind_sound,
+			name:     name,
+			rewardID: reward.RewardID,
+			changes:  changes_of(reward.TitleOf(name), reward.Cost, reward.Prompt, reference.IsEnabled()),
+		})
+	}
+
+	for _, conversion := range channelPoints.Conversions {
+		population = append(population, mirrored{
+			kind:     kind_conversion,
+			points:   conversion.Points,
+			rewardID: conversion.RewardID,
+			changes:  changes_of(conversion.Title, conversion.Cost, conversion.Prompt, true),
+		})
+	}
+	return population
+}
+
+// a disabled reward is kept (paused rather than deleted), so its id and redemptions survive
+func changes_of(title string, cost uint64, prompt string, enabled bool) request.CustomRewardChanges {
+	return request.CustomRewardChanges{
+		Title:     title,
+		Prompt:    prompt,
+		Cost:      cost,
+		IsEnabled: enabled,
+		// redemptions have to remain in the queue, or they can't be refunded
+		ShouldRedemptionsSkipRequestQueue: false,
+	}
+}
+
+func differs(current request.CustomReward, changes request.CustomRewardChanges) bool {
+	return current.Title != changes.Title ||
+		current.Prompt != changes.Prompt ||
+		current.Cost != changes.Cost ||
+		current.IsEnabled != changes.IsEnabled ||
+		current.ShouldRedemptionsSkipRequestQueue != changes.ShouldRedemptionsSkipRequestQueue
+}
+
+// store the ids assigned into the settings (clearing those of rewards no longer desired), returning if any changed.
+// Conversions are matched by title, unique on twitch, as they may have been reordered since.
+func assign_ids(settings *app.Settings, assigned map[*mirrored]string) bool {
+	sounds := make(map[string]string)
+	conversions := make(map[string]string)
+	for reward, id := range assigned {
+		if reward.kind == kind_sound {
+			sounds[reward.name] = id
+		} else {
+			conversions[strings.ToLower(reward.changes.Title)] = id
+		}
+	}
+
+	changed := false
+	for name, reference := range settings.Audio.References {
+		if reference.Reward == nil || reference.Reward.RewardID == sounds[name] {
+			continue
+		}
+
+		reward := *reference.Reward
+		reward.RewardID = sounds[name]
+		reference.Reward = &reward
+		settings.Audio.References[name] = reference
+		changed = true
+	}
+
+	for index := range settings.ChannelPoints.Conversions {
+		conversion := &settings.ChannelPoints.Conversions[index]
+		if id := conversions[strings.ToLower(conversion.Title)]; conversion.RewardID != id {
+			conversion.RewardID = id
+			changed = true
+		}
+	}
+	return changed
+}
+
+// a fingerprint of the rewards as they should exist, empty when none should (nor are known to)
+func fingerprint_of(settings *app.Settings) string {
+	var builder strings.Builder
+	for _, reward := range desired_of(settings) {
+		fmt.Fprintf(&builder, "%s|%s|%+v;", reward.kind, reward.rewardID, reward.changes)
+	}
+
+	// rewards created before being disabled still have to be deleted
+	names := make([]string, 0, len(settings.Audio.References))
+	for name := range settings.Audio.References {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		reference := settings.Audio.References[name]
+		if reference.Reward != nil && reference.Reward.RewardID != "" && (!settings.ChannelPoints.Enabled || !reference.Reward.Enabled) {
+			fmt.Fprintf(&builder, "stale|%s|%s;", name, reference.Reward.RewardID)
+		}
+	}
+	for _, conversion := range settings.ChannelPoints.Conversions {
+		if conversion.RewardID != "" && !settings.ChannelPoints.Enabled {
+			fmt.Fprintf(&builder, "stale|%s;", conversion.RewardID)
+		}
+	}
+	return builder.String()
+}
@@ -0,0 +1,195 @@
+package reward
+
+import (
+	"context"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/gorilla/websocket"
+	"github.com/imoliwer/sound-point-twitch-bot/server/app"
+	"github.com/imoliwer/sound-point-twitch-bot/server/mock"
+	"github.com/imoliwer/sound-point-twitch-bot/server/request"
+	"github.com/imoliwer/sound-point-twitch-bot/server/sound"
+)
+
+var viewer = request.TwitchUser{Id: "7", Login: "viewer", DisplayName: "Viewer"}
+
+func TestRedemptionFulfilledOncePlayed(t *testing.T) {
+	manager, helix, cover := manager_of(t)
+	overlay := overlay_of(t, cover)
+	redemption := redeem(t, manager, helix)
+
+	if err := manager.Handle(context.Background(), redemption); err != nil {
+		t.Fatalf("handling failed: %s", err)
+	}
+
+	if status := status_of(helix, redemption); status != request.RedemptionFulfilled {
+		t.Errorf("redemption is %s, expected it fulfilled", status)
+	}
+
+	var deployed sound.RealDeployment
+	overlay.SetReadDeadline(time.Now().Add(time.Second))
+	if err := overlay.ReadJSON(&deployed); err != nil || deployed.ID != "honk" {
+		t.Errorf("overlay received %+v (%v), expected honk", deployed, err)
+	}
+}
+
+func TestRedemptionRefundedUnlessPlayed(t *testing.T) {
+	manager, helix, _ := manager_of(t) // no overlay connected to play it
+	redemption := redeem(t, manager, helix)
+
+	if err := manager.Handle(context.Background(), redemption); err != nil {
+		t.Fatalf("handling failed: %s", err)
+	}
+	if status := status_of(helix, redemption); status != request.RedemptionCanceled {
+		t.Errorf("redemption is %s, expected it canceled", status)
+	}
+}
+
+func TestRedemptionHandledOnce(t *testing.T) {
+	manager, helix, cover := manager_of(t)
+	overlay_of(t, cover)
+	redemption := redeem(t, manager, helix)
+
+	for attempt := 0; attempt < 2; attempt++ {
+		if err := manager.Handle(context.Background(), redemption); err != nil {
+			t.Fatalf("handling failed: %s", err)
+		}
+	}
+	if plays := cover.Plays.Of("honk"); plays != 1 {
+		t.Errorf("played %d times, expected once", plays)
+	}
+}
+
+func TestStatusRetriedWithoutPlayingAgain(t *testing.T) {
+	manager, helix, cover := manager_of(t)
+	overlay_of(t, cover)
+	redemption := redeem(t, manager, helix)
+
+	// twitch rejects every request until authorized anew
+	id := helix.ID()
+	profile := request.Profiles.Twitch.Profile()
+	id.Expire(profile.OAuthToken)
+	id.RevokeRefresh(profile.RefreshToken)
+
+	if err := manager.Handle(context.Background(), redemption); err == nil {
+		t.Fatal("handling succeeded though the status couldn't be updated")
+	}
+
+	// kept past the lifetime of those whose status is set
+	handled := manager.handled[redemption.ID]
+	handled.at = handled.at.Add(-2 * handled_lifetime)
+	manager.handled[redemption.ID] = handled
+
+	accessToken, refreshToken := id.Issue("streamer", "42", "channel:manage:redemptions")
+	if err := request.Profiles.Twitch.Authorize(&request.TwitchOAuthRefresh{AccessToken: accessToken, RefreshToken: refreshToken, ExpiresIn: 3600}); err != nil {
+		t.Fatalf("authorizing anew: %s", err)
+	}
+
+	if err := manager.Handle(context.Background(), redemption); err != nil {
+		t.Fatalf("retrying failed: %s", err)
+	}
+	if status := status_of(helix, redemption); status != request.RedemptionFulfilled {
+		t.Errorf("redemption is %s, expected it fulfilled", status)
+	}
+	if plays := cover.Plays.Of("honk"); plays != 1 {
+		t.Errorf("played %d times, expected once", plays)
+	}
+}
+
+func TestIDsAssignedToConversionsReordered(t *testing.T) {
+	synced := &app.Settings{ChannelPoints: app.ChannelPointsSettings{Enabled: true, Conversions: []app.PointsConversion{
+		{Title: "Small", Cost: 100, Points: 10},
+		{Title: "Large", Cost: 1000, Points: 150},
+	}}}
+	desired := desired_of(synced)
+	assigned := map[*mirrored]string{&desired[0]: "small-id", &desired[1]: "large-id"}
+
+	// reordered (and one removed) while syncing
+	current := &app.Settings{ChannelPoints: app.ChannelPointsSettings{Enabled: true, Conversions: []app.PointsConversion{
+		{Title: "Large", Cost: 1000, Points: 150},
+		{Title: "Medium", Cost: 500, Points: 60},
+	}}}
+	if !assign_ids(current, assigned) {
+		t.Fatal("no id was assigned")
+	}
+
+	for index, expected := range []string{"large-id", ""} {
+		if id := current.ChannelPoints.Conversions[index].RewardID; id != expected {
+			t.Errorf("conversion %d got %q, expected %q", index, id, expected)
+		}
+	}
+	if desired[0].points != 10 || desired[1].points != 150 {
+		t.Errorf("mirrored %d and %d points, expected those of the settings synced", desired[0].points, desired[1].points)
+	}
+}
+
+//////////////////////
+// HELPER FUNCTIONS //
+//////////////////////
+
+func manager_of(t *testing.T) (*Manager, *mock.Helix, *sound.DeploymentCover) {
+	settingsFile := app.SettingsFile
+	app.SettingsFile = filepath.Join(t.TempDir(), "settings.json")
+	t.Cleanup(func() { app.SettingsFile = settingsFile })
+
+	helix := mock.NewHelix(mock.NewTwitchID("client", "secret"))
+	mock.UseHelix(t, helix, "streamer", "42", "channel:manage:redemptions")
+
+	application := app.NewApplication(&app.Settings{
+		Audio: app.AudioSettings{References: map[string]app.AudioReference{
+			"honk": {Price: 10, FileName: "honk.wav", Reward: &app.AudioReward{Enabled: true, Cost: 100}},
+		}},
+		ChannelPoints: app.ChannelPointsSettings{Enabled: true},
+	}, nil, nil)
+
+	cover := sound.NewCover(1024, 1024)
+	return NewManager(application, cover), helix, cover
+}
+
+// connect an overlay to said cover, receiving what's deployed
+func overlay_of(t *testing.T, cover *sound.DeploymentCover) *websocket.Conn {
+	gin.SetMode(gin.TestMode)
+	engine := gin.New()
+	cover.Handler(engine)
+
+	server := httptest.NewServer(engine)
+	t.Cleanup(server.Close)
+
+	overlay, _, err := websocket.DefaultDialer.Dial("ws"+strings.TrimPrefix(server.URL, "http")+"/sound/deployment", nil)
+	if err != nil {
+		t.Fatalf("connecting overlay: %s", err)
+	}
+	t.Cleanup(func() { overlay.Close() })
+
+	// registered once upgraded, which may trail the dial by a moment
+	time.Sleep(50 * time.Millisecond)
+	return overlay
+}
+
+// sync the rewards and redeem the one of the sound as the viewer
+func redeem(t *testing.T, manager *Manager, helix *mock.Helix) request.Redemption {
+	if err := manager.Sync(context.Background()); err != nil {
+		t.Fatalf("syncing failed: %s", err)
+	}
+
+	rewards := helix.Rewards("42")
+	if len(rewards) != 1 {
+		t.Fatalf("expected a single reward, got %d", len(rewards))
+	}
+
+	redemption, ok := helix.Redeem(rewards[0].ID, viewer, "")
+	if !ok {
+		t.Fatal("could not redeem the reward")
+	}
+	return redemption
+}
+
+func status_of(helix *mock.Helix, redemption request.Redemption) string {
+	current, _ := helix.Redemption(redemption.ID)
+	return current.Status
+}
@@ -1,9 +1,13 @@
 package scheduler
 
-import "time"
+import (
+	"sync"
+	"time"
+)
 
 type internal_task struct {
 	cancel   chan bool
+	mutex    sync.Mutex // cancelling may race with the task finishing
 	finished bool
 }
 
@@ -61,6 +65,9 @@ func (r *internal_task) Wait() {
 }
 
 func (r *internal_task) cancel_internally(before func()) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
 	if r.finished {
 		return
 	}
@@ -0,0 +1,109 @@
+package secret
+
+import (
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/json"
+	"errors"
+
+	"golang.org/x/crypto/scrypt"
+)
+
+const (
+	envelope_version = 1
+	scrypt_n         = 1 << 15
+	scrypt_r         = 8
+	scrypt_p         = 1
+	key_length       = 32
+	salt_length      = 16
+)
+
+type envelope struct {
+	Version    int    `json:"version"`
+	KDF        string `json:"kdf"`
+	N          int    `json:"n"`
+	R          int    `json:"r"`
+	P          int    `json:"p"`
+	Salt       []byte `json:"salt"`
+	Nonce      []byte `json:"nonce"`
+	Ciphertext []byte `json:"ciphertext"`
+}
+
+type envelope_cipher struct {
+	salt []byte
+	aead cipher.AEAD
+}
+
+// derive a key from said passphrase with a fresh salt
+func new_cipher(passphrase string) (*envelope_cipher, error) {
+	salt := make([]byte, salt_length)
+	if _, err := rand.Read(salt); err != nil {
+		return nil, err
+	}
+	return derive_cipher(passphrase, salt, scrypt_n, scrypt_r, scrypt_p)
+}
+
+func derive_cipher(passphrase string, salt []byte, n int, r int, p int) (*envelope_cipher, error) {
+	key, err := scrypt.Key([]byte(passphrase), salt, n, r, p, key_length)
+	if err != nil {
+		return nil, err
+	}
+
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+
+	aead, err := cipher.NewGCM(block)
+	if err != nil {
+		return nil, err
+	}
+	return &envelope_cipher{salt: salt, aead: aead}, nil
+}
+
+func (r *envelope_cipher) seal(plain []byte) ([]byte, error) {
+	nonce := make([]byte, r.aead.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return nil, err
+	}
+
+	return json.MarshalIndent(envelope{
+		Version:    envelope_version,
+		KDF:        "scrypt",
+		N:          scrypt_n,
+		R:          scrypt_r,
+		P:          scrypt_p,
+		Salt:       r.salt,
+		Nonce:      nonce,
+		Ciphertext: r.aead.Seal(nil, nonce, plain, nil),
+	}, "", "  ")
+}
+
+func is_envelope(content []byte) bool {
+	return bytes.Contains(content, []byte(`"ciphertext"`))
+}
+
+// decrypt the envelope, returning the plain content and the cipher to reuse for later writes
+func open_envelope(content []byte, passphrase string) ([]byte, *envelope_cipher, error) {
+	var sealed envelope
+	if err := json.Unmarshal(content, &sealed); err != nil {
+		return nil, nil, err
+	}
+
+	if sealed.Version != envelope_version || sealed.KDF != "scrypt" {
+		return nil, nil, errors.New("unsupported secrets encryption")
+	}
+
+	cipher, err := derive_cipher(passphrase, sealed.Salt, sealed.N, sealed.R, sealed.P)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	plain, err := cipher.aead.Open(nil, sealed.Nonce, sealed.Ciphertext, nil)
+	if err != nil {
+		return nil, nil, errors.New("could not decrypt secrets, is the passphrase correct?")
+	}
+	return plain, cipher, nil
+}
@@ -0,0 +1,202 @@
+package secret
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"reflect"
+	"regexp"
+	"strings"
+	"sync"
+
+	"github.com/imoliwer/sound-point-twitch-bot/server/util"
+)
+
+const (
+	// Permission keeps the secrets file readable by the owner only.
+	Permission os.FileMode = 0600
+	// PassphraseVariable names the environment variable holding the passphrase used to encrypt the file.
+	PassphraseVariable = "SPTB_SECRETS_PASSPHRASE"
+)
+
+var template_regex = regexp.MustCompile(`^<.+>$`)
+
+// secrets which may be left empty, by json name
+var optional = map[string]bool{}
+
+func init() {
+	for _, field := range reflect.VisibleFields(reflect.TypeOf(Secrets{})) {
+		if field.Tag.Get("optional") == "true" {
+			optional[strings.Split(field.Tag.Get("json"), ",")[0]] = true
+		}
+	}
+}
+
+type Secrets struct {
+	TwitchClientID     string `json:"twitch_client_id" env:"SPTB_TWITCH_CLIENT_ID"`
+	TwitchClientSecret string `json:"twitch_client_secret" env:"SPTB_TWITCH_CLIENT_SECRET"`
+	TwitchAccessToken  string `json:"twitch_access_token" env:"SPTB_TWITCH_ACCESS_TOKEN"`
+	TwitchRefreshToken string `json:"twitch_refresh_token" env:"SPTB_TWITCH_REFRESH_TOKEN"`
+	BotAuthToken       string `json:"bot_auth_token" env:"SPTB_BOT_AUTH_TOKEN"`
+	BotRefreshToken    string `json:"bot_refresh_token,omitempty" env:"SPTB_BOT_REFRESH_TOKEN" optional:"true"`
+}
+
+// NeedsAuthorization returns which of the accounts lack a token, and thus have to be authorized.
+func (r Secrets) NeedsAuthorization() (broadcaster bool, bot bool) {
+	return is_unset(r.TwitchAccessToken) || is_unset(r.TwitchRefreshToken), is_unset(r.BotAuthToken)
+}
+
+type Store struct {
+	path       string
+	passphrase string
+	mutex      sync.RWMutex
+	stored     Secrets
+	cipher     *envelope_cipher
+	existed    bool
+}
+
+// Open reads the secrets file at said path, decrypting it with said passphrase if encrypted.
+// An absent file results in an empty store. When a passphrase is given, every write is encrypted.
+func Open(path string, passphrase string) (*Store, error) {
+	store := &Store{path: path, passphrase: passphrase}
+	content, err := os.ReadFile(path)
+
+	if errors.Is(err, os.ErrNotExist) {
+		return store, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	store.existed = true
+	if is_envelope(content) {
+		if passphrase == "" {
+			return nil, fmt.Errorf("'%s' is encrypted, set %s to decrypt it", path, PassphraseVariable)
+		}
+
+		plain, cipher, err := open_envelope(content, passphrase)
+		if err != nil {
+			return nil, err
+		}
+		store.cipher = cipher
+		content = plain
+	}
+
+	if err := json.Unmarshal(content, &store.stored); err != nil {
+		return nil, fmt.Errorf("'%s' is not valid json: %w", path, err)
+	}
+	return store, nil
+}
+
+// Existed returns whether the secrets file was present when opened.
+func (r *Store) Existed() bool {
+	return r.existed
+}
+
+// Get returns the secrets, with every environment variable that is set taking precedence over the file.
+func (r *Store) Get() Secrets {
+	r.mutex.RLock()
+	secrets := r.stored
+	r.mutex.RUnlock()
+
+	for_each_field(&secrets, func(_ string, variable string, value *string) {
+		if overridden, ok := os.LookupEnv(variable); ok && overridden != "" {
+			*value = overridden
+		}
+	})
+	return secrets
+}
+
+// Update applies said modification to the stored secrets and writes them to disk.
+func (r *Store) Update(modify func(*Secrets)) error {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	updated := r.stored
+	modify(&updated)
+
+	content, err := json.MarshalIndent(updated, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	if r.passphrase != "" {
+		if r.cipher == nil {
+			if r.cipher, err = new_cipher(r.passphrase); err != nil {
+				return err
+			}
+		}
+
+		if content, err = r.cipher.seal(content); err != nil {
+			return err
+		}
+	}
+
+	if err := util.WriteAtomically(r.path, content, Permission); err != nil {
+		return err
+	}
+
+	r.stored = updated
+	r.existed = true
+	return nil
+}
+
+// Validate returns every problem found within the (environment overridden) secrets.
+func (r *Store) Validate() []error {
+	return r.validate(func(string) bool { return true })
+}
+
+// ValidateCredentials returns every problem found within the client credentials, which unlike the tokens
+// can't be obtained by authorizing.
+func (r *Store) ValidateCredentials() []error {
+	return r.validate(func(name string) bool {
+		return name == "twitch_client_id" || name == "twitch_client_secret"
+	})
+}
+
+func (r *Store) validate(includes func(name string) bool) []error {
+	problems := make([]error, 0)
+	secrets := r.Get()
+
+	for_each_field(&secrets, func(name string, variable string, value *string) {
+		if !includes(name) || (optional[name] && *value == "") {
+			return
+		}
+
+		switch {
+		case *value == "":
+			problems = append(problems, fmt.Errorf("%s: must be set, either within '%s' or through %s", name, r.path, variable))
+		case template_regex.MatchString(*value):
+			problems = append(problems, fmt.Errorf("%s: still contains the template value %s", name, *value))
+		}
+	})
+
+	if includes("bot_auth_token") && secrets.BotAuthToken != "" && !is_unset(secrets.BotAuthToken) && !strings.HasPrefix(secrets.BotAuthToken, "oauth:") {
+		problems = append(problems, errors.New("bot_auth_token: must start with \"oauth:\""))
+	}
+	return problems
+}
+
+// Template returns the secrets a newly created file consists of.
+// Tokens are left empty, as they are obtained by authorizing.
+func Template() Secrets {
+	return Secrets{
+		TwitchClientID:     "<your_client_id>",
+		TwitchClientSecret: "<your_client_secret>",
+	}
+}
+
+func is_unset(value string) bool {
+	return value == "" || template_regex.MatchString(value)
+}
+
+// call said handle with the json name, environment variable and a pointer to the value of every secret
+func for_each_field(secrets *Secrets, handle func(name string, variable string, value *string)) {
+	value := reflect.ValueOf(secrets).Elem()
+	for index := 0; index < value.NumField(); index++ {
+		field := value.Type().Field(index)
+		name := strings.Split(field.Tag.Get("json"), ",")[0]
+		handle(name, field.Tag.Get("env"), value.Field(index).Addr().Interface().(*string))
+	}
+}
@@ -0,0 +1,70 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"os"
+	"sync"
+
+	"github.com/gin-gonic/gin"
+	"github.com/imoliwer/sound-point-twitch-bot/server/app"
+	"github.com/imoliwer/sound-point-twitch-bot/server/auth"
+	"github.com/imoliwer/sound-point-twitch-bot/server/util"
+)
+
+// await_authorization serves the authorization routes alone until every account lacking a token has been
+// authorized, returning false if interrupted by said shutdown channel beforehand.
+func await_authorization(application *app.Application, authorizer *auth.Authorizer, address string, shutdown chan os.Signal) bool {
+	needsBroadcaster, needsBot := application.Secrets.Get().NeedsAuthorization()
+	if !needsBroadcaster && !needsBot {
+		return true
+	}
+
+	var mutex sync.Mutex
+	pending := map[string]bool{
+		auth.AccountBroadcaster: needsBroadcaster,
+		auth.AccountBot:         needsBot,
+	}
+
+	done := make(chan bool, 1)
+	authorizer.OnAuthorized(func(account string, _ string) {
+		mutex.Lock()
+		defer mutex.Unlock()
+
+		if !pending[account] {
+			return
+		}
+
+		pending[account] = false
+		if !pending[auth.AccountBroadcaster] && !pending[auth.AccountBot] {
+			done <- true
+		}
+	})
+
+	engine := gin.New()
+	engine.Use(gin.Recovery())
+	authorizer.Handler(engine)
+
+	server := &http.Server{Addr: address, Handler: engine}
+	go func() {
+		if err := server.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			panic(err)
+		}
+	}()
+	defer server.Shutdown(context.Background())
+
+	util.Log("Auth", "Authorization required before the bot can start, visit:")
+	for _, account := range []string{auth.AccountBroadcaster, auth.AccountBot} {
+		if pending[account] {
+			util.Log("Auth", "  %s: %s", account, authorizer.LoginURL(account))
+		}
+	}
+
+	select {
+	case <-done:
+		return true
+	case <-shutdown:
+		return false
+	}
+}
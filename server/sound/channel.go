@@ -1,9 +1,12 @@
 package sound
 
 import (
+	"errors"
 	"net/http"
+	"sync"
 
 	"github.com/gorilla/websocket"
+	"github.com/imoliwer/sound-point-twitch-bot/server/app"
 	"github.com/imoliwer/sound-point-twitch-bot/server/twitch_irc"
 )
 
@@ -11,6 +14,7 @@ type GlobalDeployment struct {
 	Price    uint64 `json:"price"`
 	ID       string `json:"id"`
 	FileName string `json:"file_name"`
+	Volume   uint64 `json:"volume"` // in percent
 }
 
 type RealDeployment struct {
@@ -18,18 +22,50 @@ type RealDeployment struct {
 	State *twitch_irc.UserState `json:"userstate"`
 }
 
+// TTSDeployment is a message read aloud, the speech sent along as a data url rather than a file.
+type TTSDeployment struct {
+	GlobalDeployment
+	Text  string                `json:"text"`
+	Audio string                `json:"audio"`
+	State *twitch_irc.UserState `json:"userstate"`
+}
+
 type TestDeployment struct {
 	GlobalDeployment
 	Tester string `json:"tester"`
 }
 
+// NewRealDeployment is said sound played by said user for said price, at its volume (reaching the target loudness, if any).
+func NewRealDeployment(application *app.Application, name string, reference app.AudioReference, price uint64, state *twitch_irc.UserState) RealDeployment {
+	return RealDeployment{
+		GlobalDeployment: GlobalDeployment{
+			Price:    price,
+			ID:       name,
+			FileName: reference.FileName,
+			Volume:   reference.PlaybackVolume(application.Settings().Audio.TargetLoudness),
+		},
+		State: state,
+	}
+}
+
+// ErrNoOverlay is returned when deploying while no overlay (browser source) is connected to receive it.
+var ErrNoOverlay = errors.New("no overlay is connected")
+
 type DeploymentCover struct {
+	Plays    *PlayCounter
+	Surge    *SurgeTracker
+	Groups   *GroupSelector
+	packs    *Packs
 	upgrader websocket.Upgrader
+	mutex    sync.Mutex
 	clients  map[*websocket.Conn]bool
 }
 
 func NewCover(readBuffer int, writebuffer int) *DeploymentCover {
 	return &DeploymentCover{
+		Plays:  NewPlayCounter(),
+		Surge:  NewSurgeTracker(),
+		Groups: NewGroupSelector(),
 		upgrader: websocket.Upgrader{
 			ReadBufferSize:  readBuffer,
 			WriteBufferSize: writebuffer,
@@ -40,12 +76,45 @@ func NewCover(readBuffer int, writebuffer int) *DeploymentCover {
 }
 
 func (r *DeploymentCover) Broadcast(obj interface{}) {
+	r.Deploy(obj)
+}
+
+// UsePacks keeps sounds of packs from being deployed for those who haven't unlocked them.
+func (r *DeploymentCover) UsePacks(packs *Packs) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	r.packs = packs
+}
+
+// Deploy sends said deployment to every connected overlay, failing unless at least one received it.
+func (r *DeploymentCover) Deploy(obj interface{}) error {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	if deployment, ok := obj.(RealDeployment); ok && r.packs != nil && deployment.State != nil {
+		if permitted, _ := r.packs.Permits(deployment.State.Id, deployment.ID); !permitted {
+			return ErrLocked
+		}
+	}
+
+	delivered := 0
 	for client := range r.clients {
-		client.WriteJSON(obj)
+		if err := client.WriteJSON(obj); err == nil {
+			delivered++
+		}
 	}
+
+	if delivered == 0 {
+		return ErrNoOverlay
+	}
+	return nil
 }
 
 func (r *DeploymentCover) register(conn *websocket.Conn) {
+	r.mutex.Lock()
+	r.clients[conn] = true
+	r.mutex.Unlock()
+
 	go func() {
 		for {
 			if _, _, err := conn.ReadMessage(); err == nil {
@@ -59,6 +128,8 @@ func (r *DeploymentCover) register(conn *websocket.Conn) {
 }
 
 func (r *DeploymentCover) unregister(conn *websocket.Conn) {
+	r.mutex.Lock()
 	delete(r.clients, conn)
+	r.mutex.Unlock()
 	conn.Close()
 }
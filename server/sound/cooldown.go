@@ -0,0 +1,222 @@
+package sound
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/imoliwer/sound-point-twitch-bot/server/app"
+	"github.com/imoliwer/sound-point-twitch-bot/server/model"
+)
+
+// the sound of a cooldown of every sound, as a user may be kept from playing any
+const any_sound = ""
+
+type cooldown_key struct {
+	sound  string
+	userID string // empty for a cooldown of everyone
+}
+
+// Cooldowns keep sounds from being played again too soon, by anyone (per sound) or by the same user
+// (per sound, or of any sound), and tell when every sound was last played. They're kept in the database
+// as well, surviving restarts.
+type Cooldowns struct {
+	app     *app.Application
+	mutex   sync.Mutex
+	running map[cooldown_key]time.Time
+	used    map[string]time.Time // when last played, per sound
+}
+
+func NewCooldowns(application *app.Application) *Cooldowns {
+	return &Cooldowns{
+		app:     application,
+		running: make(map[cooldown_key]time.Time),
+		used:    make(map[string]time.Time),
+	}
+}
+
+// Load reads the cooldowns still running from the database, deleting those expired.
+func (r *Cooldowns) Load(ctx context.Context) error {
+	now := time.Now()
+	if _, err := r.app.Database.NewDelete().Model((*model.SoundCooldown)(nil)).Where("until <= ?", now).Exec(ctx); err != nil {
+		return err
+	}
+
+	var cooldowns []model.SoundCooldown
+	if err := r.app.Database.NewSelect().Model(&cooldowns).Scan(ctx); err != nil {
+		return err
+	}
+
+	var usages []model.SoundUsage
+	if err := r.app.Database.NewSelect().Model(&usages).Scan(ctx); err != nil {
+		return err
+	}
+
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	for _, cooldown := range cooldowns {
+		r.running[cooldown_key{cooldown.Sound, cooldown.UserID}] = cooldown.Until
+	}
+	for _, usage := range usages {
+		r.used[usage.Sound] = usage.LastUsed
+	}
+	return nil
+}
+
+// LastUsed returns when said sound was last played, falling back to when it was as of older versions.
+func (r *Cooldowns) LastUsed(name string, reference app.AudioReference) uint64 {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	if at, ok := r.used[name]; ok {
+		return uint64(at.UnixMilli())
+	}
+	return reference.LastUsed
+}
+
+// Remaining returns how long until said user may play said sound, the longest of its cooldowns.
+// An empty user tells the cooldown of everyone only.
+func (r *Cooldowns) Remaining(name string, userID string) time.Duration {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	return r.remaining_of(time.Now(), name, userID)
+}
+
+// CooldownReservation holds the cooldowns of a play reserved, until kept once played or released otherwise.
+type CooldownReservation struct {
+	name    string
+	at      time.Time
+	started []model.SoundCooldown
+}
+
+// Reserve starts every cooldown of said sound for said user, unless one is running, in which case it returns
+// how long until said user may play it. The cooldowns are only kept in memory until kept (or released).
+func (r *Cooldowns) Reserve(name string, userID string) (*CooldownReservation, time.Duration, bool) {
+	audio := r.app.Settings().Audio
+	reference, ok := audio.References[name]
+	if !ok {
+		return &CooldownReservation{name: name}, 0, true
+	}
+
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	now := time.Now()
+	if remaining := r.remaining_of(now, name, userID); remaining > 0 {
+		return nil, remaining, false
+	}
+
+	reservation := &CooldownReservation{name: name, at: now, started: make([]model.SoundCooldown, 0, 3)}
+	add := func(key cooldown_key, cooldown app.Milliseconds) {
+		if cooldown > 0 && (key.userID != "" || key.sound != any_sound) {
+			reservation.started = append(reservation.started, model.SoundCooldown{Sound: key.sound, UserID: key.userID, Until: now.Add(cooldown.Duration())})
+		}
+	}
+
+	add(cooldown_key{name, ""}, reference.Cooldown)
+	if userID != "" {
+		add(cooldown_key{name, userID}, reference.UserCooldown)
+		add(cooldown_key{any_sound, userID}, audio.UserCooldown)
+	}
+
+	for key, until := range r.running {
+		if !until.After(now) {
+			delete(r.running, key)
+		}
+	}
+	for _, cooldown := range reservation.started {
+		r.running[cooldown_key{cooldown.Sound, cooldown.UserID}] = cooldown.Until
+	}
+	return reservation, 0, true
+}
+
+// Release takes back the cooldowns of said reservation, e.g. as the sound couldn't be played.
+func (r *Cooldowns) Release(reservation *CooldownReservation) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	for _, cooldown := range reservation.started {
+		key := cooldown_key{cooldown.Sound, cooldown.UserID}
+		if r.running[key].Equal(cooldown.Until) { // unless started anew since
+			delete(r.running, key)
+		}
+	}
+}
+
+// Keep saves the cooldowns of said reservation, as its sound was played, remembering when it was played.
+func (r *Cooldowns) Keep(ctx context.Context, reservation *CooldownReservation) error {
+	if reservation.at.IsZero() {
+		return nil
+	}
+
+	r.mutex.Lock()
+	r.used[reservation.name] = reservation.at
+	r.mutex.Unlock()
+
+	// not within the settings, as saving them with every play would rotate their backups away
+	usage := model.SoundUsage{Sound: reservation.name, LastUsed: reservation.at}
+	_, err := r.app.Database.
+		NewInsert().
+		Model(&usage).
+		On("CONFLICT (sound) DO UPDATE").
+		Set("last_used = EXCLUDED.last_used").
+		Exec(ctx)
+	if err != nil || len(reservation.started) == 0 {
+		return err
+	}
+
+	_, err = r.app.Database.
+		NewInsert().
+		Model(&reservation.started).
+		On("CONFLICT (sound, user_id) DO UPDATE").
+		Set("until = EXCLUDED.until").
+		Exec(ctx)
+	return err
+}
+
+// Reset removes every cooldown of said sound, of everyone and of every user.
+func (r *Cooldowns) Reset(ctx context.Context, name string) error {
+	r.mutex.Lock()
+	for key := range r.running {
+		if key.sound == name {
+			delete(r.running, key)
+		}
+	}
+	r.mutex.Unlock()
+
+	_, err := r.app.Database.NewDelete().Model((*model.SoundCooldown)(nil)).Where("sound = ?", name).Exec(ctx)
+	return err
+}
+
+//////////////////////
+// HELPER FUNCTIONS //
+//////////////////////
+
+// the longest of the cooldowns of said sound, of said user too unless empty
+// must be called while holding the mutex
+func (r *Cooldowns) remaining_of(now time.Time, name string, userID string) time.Duration {
+	remaining := r.remaining_of_key(now, cooldown_key{name, ""})
+	if userID != "" {
+		remaining = longest(remaining, r.remaining_of_key(now, cooldown_key{name, userID}), r.remaining_of_key(now, cooldown_key{any_sound, userID}))
+	}
+	return remaining
+}
+
+// must be called while holding the mutex
+func (r *Cooldowns) remaining_of_key(now time.Time, key cooldown_key) time.Duration {
+	if remaining := r.running[key].Sub(now); remaining > 0 {
+		return remaining
+	}
+	return 0
+}
+
+func longest(durations ...time.Duration) time.Duration {
+	var population time.Duration
+	for _, duration := range durations {
+		if duration > population {
+			population = duration
+		}
+	}
+	return population
+}
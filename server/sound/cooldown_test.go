@@ -0,0 +1,98 @@
+package sound
+
+import (
+	"context"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/imoliwer/sound-point-twitch-bot/server/app"
+)
+
+func TestCooldownReservedOnce(t *testing.T) {
+	cooldowns := cooldowns_of(app.AudioReference{Price: 10, FileName: "honk.wav", Cooldown: app.Milliseconds(time.Minute.Milliseconds())})
+
+	var reserved int32
+	var group sync.WaitGroup
+	for attempt := 0; attempt < 8; attempt++ {
+		group.Add(1)
+		go func() {
+			defer group.Done()
+			if _, _, ok := cooldowns.Reserve("honk", "7"); ok {
+				atomic.AddInt32(&reserved, 1)
+			}
+		}()
+	}
+	group.Wait()
+
+	if reserved != 1 {
+		t.Errorf("reserved %d times, expected once", reserved)
+	}
+	if remaining := cooldowns.Remaining("honk", ""); remaining <= 0 {
+		t.Error("cooldown isn't running once reserved")
+	}
+}
+
+func TestCooldownReleased(t *testing.T) {
+	cooldowns := cooldowns_of(app.AudioReference{Price: 10, FileName: "honk.wav", UserCooldown: app.Milliseconds(time.Minute.Milliseconds())})
+
+	reservation, _, ok := cooldowns.Reserve("honk", "7")
+	if !ok {
+		t.Fatal("could not reserve")
+	}
+	if _, remaining, ok := cooldowns.Reserve("honk", "7"); ok || remaining <= 0 {
+		t.Fatal("reserved again while on cooldown")
+	}
+
+	cooldowns.Release(reservation)
+	if _, _, ok := cooldowns.Reserve("honk", "7"); !ok {
+		t.Error("still on cooldown once released")
+	}
+}
+
+func TestFirstPlayCountedOnce(t *testing.T) {
+	plays := NewPlayCounter()
+
+	first, ok := plays.Attempt("honk", 2)
+	if !ok || first != 0 {
+		t.Fatalf("first attempt counted %d before (%t)", first, ok)
+	}
+	if second, ok := plays.Attempt("honk", 2); !ok || second != 1 {
+		t.Errorf("second attempt counted %d before (%t), expected 1", second, ok)
+	}
+	if _, ok := plays.Attempt("honk", 2); ok {
+		t.Error("played more than the most allowed")
+	}
+}
+
+func TestLastUsedKeptApartFromSettings(t *testing.T) {
+	cooldowns := cooldowns_of(app.AudioReference{Price: 10, FileName: "honk.wav", LastUsed: 1})
+	cooldowns.app.Database = database_of(t)
+
+	reservation, _, _ := cooldowns.Reserve("honk", "7")
+	if err := cooldowns.Keep(context.Background(), reservation); err != nil {
+		t.Fatalf("keeping failed: %s", err)
+	}
+	if reference := cooldowns.app.Settings().Audio.References["honk"]; reference.LastUsed != 1 {
+		t.Error("last use was written into the settings")
+	}
+
+	loaded := NewCooldowns(cooldowns.app)
+	if err := loaded.Load(context.Background()); err != nil {
+		t.Fatalf("loading failed: %s", err)
+	}
+	if lastUsed := loaded.LastUsed("honk", app.AudioReference{LastUsed: 1}); lastUsed != uint64(reservation.at.UnixMilli()) {
+		t.Errorf("last used at %d, expected %d", lastUsed, reservation.at.UnixMilli())
+	}
+}
+
+//////////////////////
+// HELPER FUNCTIONS //
+//////////////////////
+
+func cooldowns_of(reference app.AudioReference) *Cooldowns {
+	return NewCooldowns(app.NewApplication(&app.Settings{
+		Audio: app.AudioSettings{References: map[string]app.AudioReference{"honk": reference}},
+	}, nil, nil))
+}
@@ -1,29 +1,48 @@
 package sound
 
 import (
+	"crypto/rand"
+	"encoding/hex"
 	"errors"
 	"fmt"
+	"io"
+	"mime/multipart"
 	"net/http"
 	"os"
+	"path/filepath"
+	"regexp"
 	"strings"
+	"time"
 
 	"github.com/gin-gonic/gin"
 	"github.com/imoliwer/sound-point-twitch-bot/server/app"
 	"github.com/imoliwer/sound-point-twitch-bot/server/util"
 )
 
+// Directory is where uploaded sounds are stored, replaced by the one of the config at startup.
+var Directory = "web/public/sounds"
+
+// what the multipart encoding of an upload may take besides the file itself
+const multipart_overhead = 64 * 1024
+
+var unsafe_name_regex = regexp.MustCompile(`[^a-z0-9_-]+`)
+
 func checkAndCreatePath() {
-	if _, err := os.Stat("web/public/sounds"); errors.Is(err, os.ErrNotExist) {
-		os.Mkdir("web/public/sounds", os.ModeDir)
+	if _, err := os.Stat(Directory); errors.Is(err, os.ErrNotExist) {
+		os.MkdirAll(Directory, 0755)
 	}
 }
 
+func path_of(fileName string) string {
+	return filepath.Join(Directory, fileName)
+}
+
 func WithCORSAndRecovery(engine *gin.Engine) *gin.Engine {
 	engine.Use(
 		func(ctx *gin.Context) {
 			ctx.Writer.Header().Set("Access-Control-Allow-Origin", "*")
 			ctx.Writer.Header().Set("Access-Control-Allow-Headers", "Content-Type, Content-Length, Accept-Encoding, X-CSRF-Token, Authorization, accept, origin, Cache-Control, X-Requested-With")
-			ctx.Writer.Header().Set("Access-Control-Allow-Methods", "POST, GET, DELETE")
+			ctx.Writer.Header().Set("Access-Control-Allow-Methods", "POST, GET, PUT, PATCH, DELETE")
 
 			if ctx.Request.Method == "OPTIONS" {
 				ctx.AbortWithStatus(204)
@@ -41,15 +60,53 @@ func (r *DeploymentCover) Handler(engine *gin.Engine) {
 	engine.GET("/sound/deployment", func(ctx *gin.Context) {
 		socket, err := r.upgrader.Upgrade(ctx.Writer, ctx.Request, nil)
 		if err != nil {
-			panic(err)
+			return // the upgrader has responded with the error already
 		}
-		r.clients[socket] = true
+		r.register(socket)
 	})
 }
 
-func AllSoundsHandler(engine *gin.Engine, app *app.Application) {
+func FilesHandler(engine *gin.Engine) {
+	checkAndCreatePath()
+	engine.Static("/sound/files", Directory)
+}
+
+// SoundState is a sound as configured, along with how long until anyone may play it again and what it costs now.
+type SoundState struct {
+	app.AudioReference
+	CooldownRemaining float64 `json:"cooldown_remaining_seconds"`
+	EffectivePrice    uint64  `json:"effective_price"` // for those not subscribed
+}
+
+func AllSoundsHandler(engine *gin.Engine, application *app.Application, cover *DeploymentCover, cooldowns *Cooldowns) {
 	engine.GET("/sounds", func(ctx *gin.Context) {
-		ctx.JSON(http.StatusOK, app.Settings.Audio.References)
+		references := application.Settings().Audio.References
+		population := make(map[string]SoundState, len(references))
+		for name, reference := range references {
+			reference.LastUsed = cooldowns.LastUsed(name, reference)
+			population[name] = SoundState{
+				AudioReference:    reference,
+				CooldownRemaining: cooldowns.Remaining(name, "").Seconds(),
+				EffectivePrice:    cover.PriceOf(name, reference, 0),
+			}
+		}
+		ctx.JSON(http.StatusOK, population)
+	})
+}
+
+func CooldownHandler(engine *gin.Engine, application *app.Application, cooldowns *Cooldowns) {
+	engine.DELETE("/sound/:id/cooldown", func(ctx *gin.Context) {
+		id := ctx.Param("id")
+		if _, ok := application.Settings().Audio.References[id]; !ok {
+			ctx.String(http.StatusNotFound, "sound was not found")
+			return
+		}
+
+		if err := cooldowns.Reset(ctx.Request.Context(), id); err != nil {
+			ctx.String(http.StatusInternalServerError, "failed resetting cooldown")
+			return
+		}
+		ctx.String(http.StatusOK, "cooldown has been reset")
 	})
 }
 
@@ -61,21 +118,97 @@ func DeleteHandler(engine *gin.Engine, application *app.Application) {
 			return
 		}
 
-		references := application.Settings.Audio.References
-		audioReference, ok := references[id]
+		var audioReference app.AudioReference
+		var ok bool
+		application.UpdateSettings(func(settings *app.Settings) {
+			if audioReference, ok = settings.Audio.References[id]; ok {
+				delete(settings.Audio.References, id)
+				settings.Audio.Forget(id)
+			}
+		})
 
 		if !ok {
-			ctx.String(http.StatusBadRequest, "sound was not found")
+			ctx.String(http.StatusNotFound, "sound was not found")
 			return
 		}
 
-		os.Remove(fmt.Sprintf("web/public/sounds/%s", audioReference.FileName))
-		delete(references, id)
+		os.Remove(path_of(audioReference.FileName))
+		if audioReference.OriginalFileName != "" {
+			os.Remove(original_path_of(audioReference.OriginalFileName))
+		}
+		if !save_library(ctx, application) {
+			return
+		}
 		ctx.String(http.StatusOK, "sound has been deleted")
 	})
 }
 
-func UploadHandler(engine *gin.Engine, application *app.Application) {
+// SoundChanges are the fields of a sound to edit, those absent being left as they are.
+type SoundChanges struct {
+	Price             *uint64      `json:"price"`
+	Cooldown          *uint64      `json:"cooldown"`      // in milliseconds
+	UserCooldown      *uint64      `json:"user_cooldown"` // in milliseconds
+	Volume            *uint64      `json:"volume"`
+	Tags              *[]string    `json:"tags"`
+	Description       *string      `json:"description"`
+	Enabled           *bool        `json:"enabled"`
+	Permission        *string      `json:"permission"`
+	MaxPlaysPerStream *uint64      `json:"max_plays_per_stream"`
+	Pricing           *app.Pricing `json:"pricing"` // replaced as a whole, an empty object fixing the price again
+}
+
+func EditHandler(engine *gin.Engine, application *app.Application) {
+	engine.PATCH("/sound/:id", func(ctx *gin.Context) {
+		id := ctx.Param("id")
+		if _, ok := application.Settings().Audio.References[id]; !ok {
+			ctx.String(http.StatusNotFound, "sound was not found")
+			return
+		}
+
+		var changes SoundChanges
+		if err := ctx.ShouldBindJSON(&changes); err != nil {
+			ctx.String(http.StatusBadRequest, "invalid changes: %s", err)
+			return
+		}
+
+		if changes.Volume != nil && *changes.Volume > app.MaxVolume {
+			ctx.String(http.StatusBadRequest, "volume must be within 0 and %d", app.MaxVolume)
+			return
+		}
+
+		if changes.Permission != nil && app.PermissionRank(*changes.Permission) < 0 {
+			ctx.String(http.StatusBadRequest, "unknown permission level, known are %s", strings.Join(app.PermissionLevels, ", "))
+			return
+		}
+
+		if changes.Pricing != nil {
+			if problems := changes.Pricing.Problems("pricing"); len(problems) > 0 {
+				ctx.String(http.StatusBadRequest, "invalid pricing: %s", problems[0])
+				return
+			}
+		}
+
+		var reference app.AudioReference
+		var ok bool
+		application.UpdateSettings(func(settings *app.Settings) {
+			if reference, ok = settings.Audio.References[id]; ok {
+				apply_changes(&reference, changes)
+				settings.Audio.References[id] = reference
+			}
+		})
+
+		if !ok {
+			ctx.String(http.StatusNotFound, "sound was not found")
+			return
+		}
+		if !save_library(ctx, application) {
+			return
+		}
+		ctx.JSON(http.StatusOK, reference)
+	})
+}
+
+func UploadHandler(engine *gin.Engine, application *app.Application, processor *Processor) {
 	engine.POST("/sound", func(ctx *gin.Context) {
 		price := ctx.Query("price")
 		if price == "" {
@@ -95,38 +228,131 @@ func UploadHandler(engine *gin.Engine, application *app.Application) {
 			return
 		}
 
-		references := application.Settings.Audio.References
-		_, exists := references[name]
-
-		if exists {
+		if _, exists := application.Settings().Audio.References[name]; exists {
 			ctx.String(http.StatusBadRequest, "a sound with that name already exists")
 			return
 		}
 
+		if _, isGroup := application.Settings().Audio.Groups[name]; isGroup {
+			ctx.String(http.StatusBadRequest, "a group with that name already exists")
+			return
+		}
+
+		upload := application.Settings().Audio.Upload
+		maxSize := upload.MaxSize()
+
+		// the body is read no further than the limit (and what multipart encoding takes besides)
+		if ctx.Request.ContentLength > maxSize+multipart_overhead {
+			ctx.String(http.StatusRequestEntityTooLarge, "audio file must be at most %d KB", maxSize/1024)
+			return
+		}
+		ctx.Request.Body = http.MaxBytesReader(ctx.Writer, ctx.Request.Body, maxSize+multipart_overhead)
+
 		file, err := ctx.FormFile("file")
 		if err != nil {
-			ctx.String(http.StatusBadRequest, "no audio file provided")
+			ctx.String(http.StatusBadRequest, "no audio file provided, or larger than %d KB", maxSize/1024)
+			return
+		}
+
+		if file.Size > maxSize {
+			ctx.String(http.StatusRequestEntityTooLarge, "audio file must be at most %d KB", maxSize/1024)
+			return
+		}
+
+		content, err := read_upload(file, maxSize)
+		if err != nil {
+			ctx.String(http.StatusBadRequest, "could not read audio file")
 			return
 		}
 
-		filePath := fmt.Sprintf("web/public/sounds/%s", file.Filename)
-		if _, err := os.Stat(filePath); os.IsExist(err) {
-			ctx.String(http.StatusBadRequest, "file already exists")
+		// the format is told by the content alone, never by the name or type the client claims
+		info, err := ProbeBytes(content)
+		if errors.Is(err, ErrUnknownFormat) {
+			ctx.String(http.StatusUnsupportedMediaType, "unsupported audio format, must be one of %s", strings.Join(Formats, ", "))
+			return
+		}
+		if err != nil {
+			ctx.String(http.StatusBadRequest, "malformed %s file: %s", info.Format, err)
+			return
+		}
+
+		if maxDuration := upload.MaxDuration(); info.Duration > maxDuration {
+			ctx.String(http.StatusBadRequest, "audio must be at most %s long, got %s", maxDuration, info.Duration.Round(time.Millisecond))
+			return
+		}
+
+		// only measurable of pcm, the others are played as they are; measured before anything is written
+		var loudness *float64
+		if measured, err := Loudness(content); err == nil {
+			loudness = &measured
+		} else if !errors.Is(err, ErrLoudnessUnsupported) {
+			util.Log("Sounds", "Could not measure the loudness of '%s': %s", name, err)
+		}
+
+		fileName, err := file_name_of(name, info.Format)
+		if err != nil {
+			ctx.String(http.StatusInternalServerError, "failed naming file")
 			return
 		}
 
 		checkAndCreatePath()
-		if err := ctx.SaveUploadedFile(file, filePath); err != nil {
-			ctx.String(http.StatusInternalServerError, "failed saving file, perhaps it already exists?")
+		if err := write_exclusively(path_of(fileName), content); err != nil {
+			util.Log("Sounds", "Could not save '%s': %s", fileName, err)
+			ctx.String(http.StatusInternalServerError, "failed saving file")
 			return
 		}
 
-		references[name] = app.AudioReference{
-			Price:    util.ForceUint64(price),
-			FileName: file.Filename,
-			Cooldown: util.ForceUint64(cooldown),
+		reference := app.AudioReference{
+			Price:      util.ForceUint64(price),
+			FileName:   fileName,
+			Cooldown:   app.Milliseconds(util.ForceUint64(cooldown)),
+			Duration:   info.Duration.Seconds(),
+			Format:     info.Format,
+			SampleRate: info.SampleRate,
+			Bitrate:    info.Bitrate,
+			Loudness:   loudness,
 		}
-		ctx.String(http.StatusOK, "uploaded new sound successfully")
+
+		// named alike by another upload in the meantime
+		var exists bool
+		application.UpdateSettings(func(settings *app.Settings) {
+			if _, exists = settings.Audio.References[name]; !exists {
+				settings.Audio.References[name] = reference
+			}
+		})
+
+		if exists {
+			os.Remove(path_of(fileName))
+			ctx.String(http.StatusBadRequest, "a sound with that name already exists")
+			return
+		}
+
+		if application.Settings().Audio.Processing.Enabled {
+			processor.Enqueue(name) // played as uploaded until processed
+		}
+		if !save_library(ctx, application) {
+			return
+		}
+		ctx.JSON(http.StatusOK, reference)
+	})
+}
+
+// ProcessHandler processes a sound anew from its original, e.g. once the target loudness changed.
+func ProcessHandler(engine *gin.Engine, application *app.Application, processor *Processor) {
+	engine.POST("/sound/:id/process", func(ctx *gin.Context) {
+		id := ctx.Param("id")
+		if _, ok := application.Settings().Audio.References[id]; !ok {
+			ctx.String(http.StatusNotFound, "sound was not found")
+			return
+		}
+
+		if !application.Settings().Audio.Processing.Enabled {
+			ctx.String(http.StatusConflict, "processing is disabled")
+			return
+		}
+
+		processor.Enqueue(id)
+		ctx.JSON(http.StatusAccepted, application.Settings().Audio.References[id])
 	})
 }
 
@@ -138,7 +364,7 @@ func TestHandler(engine *gin.Engine, appPtr *app.Application, cover *DeploymentC
 			return
 		}
 
-		reference, ok := appPtr.Settings.Audio.References[id]
+		reference, ok := appPtr.Settings().Audio.References[id]
 		if !ok {
 			ctx.String(http.StatusBadRequest, "could not find sound with passed id")
 			return
@@ -149,6 +375,7 @@ func TestHandler(engine *gin.Engine, appPtr *app.Application, cover *DeploymentC
 				ID:       id,
 				Price:    reference.Price,
 				FileName: reference.FileName,
+				Volume:   reference.PlaybackVolume(appPtr.Settings().Audio.TargetLoudness),
 			},
 			Tester: "Broadcaster",
 		})
@@ -156,9 +383,169 @@ func TestHandler(engine *gin.Engine, appPtr *app.Application, cover *DeploymentC
 	})
 }
 
-func RegisterAll(engine *gin.Engine, appPtr *app.Application, cover *DeploymentCover) {
-	AllSoundsHandler(engine, appPtr)
-	UploadHandler(engine, appPtr)
+// save the settings right away rather than debounced, as a reload in the meantime would otherwise
+// swap in the library as it was, losing the change and orphaning (or missing) its files
+func save_library(ctx *gin.Context, application *app.Application) bool {
+	if err := application.Flush(); err != nil {
+		util.Log("Sounds", "Could not save the settings: %s", err)
+		ctx.String(http.StatusInternalServerError, "failed saving settings")
+		return false
+	}
+	return true
+}
+
+// a name of the server's own, the part told by the sound merely easing recognition
+func file_name_of(name string, format string) (string, error) {
+	base := strings.Trim(unsafe_name_regex.ReplaceAllString(strings.ToLower(name), "-"), "-")
+	if len(base) > 32 {
+		base = base[:32]
+	}
+	if base == "" {
+		base = "sound"
+	}
+
+	suffix := make([]byte, 4)
+	if _, err := rand.Read(suffix); err != nil {
+		return "", err
+	}
+	return fmt.Sprintf("%s-%s.%s", base, hex.EncodeToString(suffix), format), nil
+}
+
+func read_upload(file *multipart.FileHeader, maxSize int64) ([]byte, error) {
+	opened, err := file.Open()
+	if err != nil {
+		return nil, err
+	}
+	defer opened.Close()
+
+	content, err := io.ReadAll(io.LimitReader(opened, maxSize+1))
+	if err == nil && int64(len(content)) > maxSize {
+		err = errors.New("file larger than allowed")
+	}
+	return content, err
+}
+
+// never replacing what exists
+func write_exclusively(path string, content []byte) error {
+	file, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0644)
+	if err != nil {
+		return err
+	}
+
+	if _, err := file.Write(content); err != nil {
+		file.Close()
+		os.Remove(path)
+		return err
+	}
+	return file.Close()
+}
+
+func apply_changes(reference *app.AudioReference, changes SoundChanges) {
+	if changes.Price != nil {
+		reference.Price = *changes.Price
+	}
+	if changes.Cooldown != nil {
+		reference.Cooldown = app.Milliseconds(*changes.Cooldown)
+	}
+	if changes.UserCooldown != nil {
+		reference.UserCooldown = app.Milliseconds(*changes.UserCooldown)
+	}
+	if changes.Volume != nil {
+		volume := *changes.Volume
+		reference.Volume = &volume
+	}
+	if changes.Tags != nil {
+		reference.Tags = normalized_tags(*changes.Tags)
+	}
+	if changes.Description != nil {
+		reference.Description = strings.TrimSpace(*changes.Description)
+	}
+	if changes.Enabled != nil {
+		enabled := *changes.Enabled
+		reference.Enabled = &enabled
+	}
+	if changes.Permission != nil {
+		reference.Permission = *changes.Permission
+	}
+	if changes.MaxPlaysPerStream != nil {
+		reference.MaxPlaysPerStream = *changes.MaxPlaysPerStream
+	}
+	if changes.Pricing != nil {
+		reference.Pricing = changes.Pricing
+		if changes.Pricing.IsFixed() {
+			reference.Pricing = nil
+		}
+	}
+}
+
+// lowercase and trimmed, leaving out those empty or repeated
+func normalized_tags(tags []string) []string {
+	population := make([]string, 0, len(tags))
+	seen := make(map[string]bool)
+	for _, tag := range tags {
+		tag = strings.ToLower(strings.TrimSpace(tag))
+		if tag == "" || seen[tag] {
+			continue
+		}
+		seen[tag] = true
+		population = append(population, tag)
+	}
+	return population
+}
+
+// PacksHandler tells the packs along with how many unlocked them, and unlocks (or locks) them for users free of charge.
+func PacksHandler(engine *gin.Engine, application *app.Application, packs *Packs) {
+	engine.GET("/packs", func(ctx *gin.Context) {
+		population := make(map[string]PackState)
+		for name, pack := range application.Settings().Audio.Packs {
+			population[name] = PackState{SoundPack: pack, Owners: packs.OwnersOf(name)}
+		}
+		ctx.JSON(http.StatusOK, population)
+	})
+
+	engine.PUT("/packs/:id/owners/:user", func(ctx *gin.Context) {
+		id := ctx.Param("id")
+		if _, ok := application.Settings().Audio.Packs[id]; !ok {
+			ctx.String(http.StatusNotFound, "pack was not found")
+			return
+		}
+
+		user := ctx.Param("user")
+		if _, err := util.Uint64(user); err != nil {
+			ctx.String(http.StatusBadRequest, "user must be a twitch user id")
+			return
+		}
+
+		if err := packs.Grant(ctx.Request.Context(), user, id); err != nil {
+			ctx.String(http.StatusInternalServerError, "failed unlocking pack")
+			return
+		}
+		ctx.String(http.StatusOK, "pack has been unlocked")
+	})
+
+	engine.DELETE("/packs/:id/owners/:user", func(ctx *gin.Context) {
+		if err := packs.Revoke(ctx.Request.Context(), ctx.Param("user"), ctx.Param("id")); err != nil {
+			ctx.String(http.StatusInternalServerError, "failed locking pack")
+			return
+		}
+		ctx.String(http.StatusOK, "pack has been locked")
+	})
+}
+
+// PackState is a pack as configured, along with how many users unlocked it.
+type PackState struct {
+	app.SoundPack
+	Owners int `json:"owners"`
+}
+
+func RegisterAll(engine *gin.Engine, appPtr *app.Application, cover *DeploymentCover, processor *Processor, cooldowns *Cooldowns, packs *Packs) {
+	FilesHandler(engine)
+	AllSoundsHandler(engine, appPtr, cover, cooldowns)
+	CooldownHandler(engine, appPtr, cooldowns)
+	PacksHandler(engine, appPtr, packs)
+	UploadHandler(engine, appPtr, processor)
+	ProcessHandler(engine, appPtr, processor)
+	EditHandler(engine, appPtr)
 	DeleteHandler(engine, appPtr)
 	TestHandler(engine, appPtr, cover)
 }
@@ -0,0 +1,111 @@
+package sound
+
+import (
+	"math/rand"
+	"sync"
+	"time"
+
+	"github.com/imoliwer/sound-point-twitch-bot/server/app"
+)
+
+// GroupSelector picks the sounds of groups, remembering where each group left off.
+type GroupSelector struct {
+	mutex  sync.Mutex
+	random *rand.Rand
+	next   map[string]int             // of round robin groups, the index of the sound up next
+	drawn  map[string]map[string]bool // of shuffled groups, the sounds picked since all of them last were
+}
+
+func NewGroupSelector() *GroupSelector {
+	return &GroupSelector{
+		random: rand.New(rand.NewSource(time.Now().UnixNano())),
+		next:   make(map[string]int),
+		drawn:  make(map[string]map[string]bool),
+	}
+}
+
+// Pick picks a sound of said group among those said to be available, false if none of them is.
+func (r *GroupSelector) Pick(name string, group app.SoundGroup, available func(string) bool) (string, bool) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	candidates := make([]app.GroupMember, 0, len(group.Sounds))
+	for _, member := range group.Sounds {
+		if available(member.Sound) {
+			candidates = append(candidates, member)
+		}
+	}
+	if len(candidates) == 0 {
+		return "", false
+	}
+
+	switch group.SelectionOf() {
+	case app.SelectionRoundRobin:
+		return r.round_robin(name, group, available)
+	case app.SelectionShuffle:
+		return r.shuffled(name, candidates), true
+	}
+	return r.weighted(candidates), true
+}
+
+//////////////////////
+// HELPER FUNCTIONS //
+//////////////////////
+
+// must be called while holding the mutex
+func (r *GroupSelector) round_robin(name string, group app.SoundGroup, available func(string) bool) (string, bool) {
+	count := len(group.Sounds)
+	for offset := 0; offset < count; offset++ {
+		index := (r.next[name] + offset) % count
+		if sound := group.Sounds[index].Sound; available(sound) {
+			r.next[name] = (index + 1) % count
+			return sound, true
+		}
+	}
+	return "", false
+}
+
+// must be called while holding the mutex
+func (r *GroupSelector) shuffled(name string, candidates []app.GroupMember) string {
+	drawn, ok := r.drawn[name]
+	if !ok {
+		drawn = make(map[string]bool)
+		r.drawn[name] = drawn
+	}
+
+	remaining := make([]app.GroupMember, 0, len(candidates))
+	for _, member := range candidates {
+		if !drawn[member.Sound] {
+			remaining = append(remaining, member)
+		}
+	}
+
+	// exhausted, as far as those available go
+	if len(remaining) == 0 {
+		for sound := range drawn {
+			delete(drawn, sound)
+		}
+		remaining = candidates
+	}
+
+	sound := r.weighted(remaining)
+	drawn[sound] = true
+	return sound
+}
+
+// must be called while holding the mutex
+func (r *GroupSelector) weighted(candidates []app.GroupMember) string {
+	total := uint64(0)
+	for _, member := range candidates {
+		total += member.WeightOf()
+	}
+
+	roll := uint64(r.random.Int63n(int64(total)))
+	for _, member := range candidates {
+		if roll < member.WeightOf() {
+			return member.Sound
+		}
+		roll -= member.WeightOf()
+	}
+	return candidates[len(candidates)-1].Sound
+}
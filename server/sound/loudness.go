@@ -0,0 +1,211 @@
+package sound
+
+import (
+	"encoding/binary"
+	"errors"
+	"math"
+)
+
+// ErrLoudnessUnsupported is returned for audio that can't be decoded without a codec, i.e. anything but pcm wav.
+var ErrLoudnessUnsupported = errors.New("loudness can only be measured of pcm wav")
+
+// ErrSilent is returned for audio too quiet (or short) to have any loudness.
+var ErrSilent = errors.New("audio is silent")
+
+// as specified by ITU-R BS.1770-4
+const (
+	loudness_block  = 0.4  // seconds
+	loudness_step   = 0.1  // seconds, the blocks overlapping by 75%
+	absolute_gate   = -70. // LUFS
+	relative_gate   = -10. // LU below the loudness of the blocks above the absolute gate
+	loudness_offset = -0.691
+	surround_weight = 1.41
+)
+
+// Loudness measures the integrated loudness of said audio in LUFS.
+func Loudness(content []byte) (float64, error) {
+	if len(content) < 12 || string(content[:4]) != "RIFF" || string(content[8:12]) != "WAVE" {
+		return 0, ErrLoudnessUnsupported
+	}
+
+	chunks, err := wav_chunks_of(content)
+	if err != nil {
+		return 0, err
+	}
+
+	channels, err := pcm_of(chunks)
+	if err != nil {
+		return 0, err
+	}
+	return integrated_loudness(channels, float64(chunks.sampleRate))
+}
+
+// GainOf returns the factor said loudness has to be amplified by to reach said target.
+func GainOf(loudness float64, target float64) float64 {
+	return math.Pow(10, (target-loudness)/20)
+}
+
+//////////////////////
+// HELPER FUNCTIONS //
+//////////////////////
+
+// the samples of every channel, within -1 and 1
+func pcm_of(chunks wav_chunks) ([][]float64, error) {
+	if chunks.channels == 0 || chunks.blockAlign == 0 {
+		return nil, errors.New("wav without channels")
+	}
+
+	width := int(chunks.bitsPerSample+7) / 8
+	if width*int(chunks.channels) > int(chunks.blockAlign) {
+		return nil, errors.New("wav of an invalid block alignment")
+	}
+
+	var decode func([]byte) float64
+	switch {
+	case chunks.formatTag == 1 && width == 1:
+		decode = func(b []byte) float64 { return (float64(b[0]) - 128) / 128 } // the only unsigned width
+	case chunks.formatTag == 1 && width == 2:
+		decode = func(b []byte) float64 { return float64(int16(binary.LittleEndian.Uint16(b))) / (1 << 15) }
+	case chunks.formatTag == 1 && width == 3:
+		decode = func(b []byte) float64 {
+			return float64(int32(uint32(b[0])<<8|uint32(b[1])<<16|uint32(b[2])<<24)>>8) / (1 << 23)
+		}
+	case chunks.formatTag == 1 && width == 4:
+		decode = func(b []byte) float64 { return float64(int32(binary.LittleEndian.Uint32(b))) / (1 << 31) }
+	case chunks.formatTag == 3 && width == 4:
+		decode = func(b []byte) float64 { return float64(math.Float32frombits(binary.LittleEndian.Uint32(b))) }
+	case chunks.formatTag == 3 && width == 8:
+		decode = func(b []byte) float64 { return math.Float64frombits(binary.LittleEndian.Uint64(b)) }
+	default:
+		return nil, ErrLoudnessUnsupported
+	}
+
+	frames := len(chunks.data) / int(chunks.blockAlign)
+	channels := make([][]float64, chunks.channels)
+	for channel := range channels {
+		channels[channel] = make([]float64, frames)
+	}
+
+	for frame := 0; frame < frames; frame++ {
+		offset := frame * int(chunks.blockAlign)
+		for channel := range channels {
+			channels[channel][frame] = decode(chunks.data[offset+channel*width:])
+		}
+	}
+	return channels, nil
+}
+
+func integrated_loudness(channels [][]float64, sampleRate float64) (float64, error) {
+	blockSize := int(loudness_block * sampleRate)
+	stepSize := int(loudness_step * sampleRate)
+	if len(channels) == 0 || blockSize == 0 || stepSize == 0 || len(channels[0]) < blockSize {
+		return 0, ErrSilent
+	}
+
+	// the mean square of every block, weighted and summed over the channels
+	blocks := make([]float64, (len(channels[0])-blockSize)/stepSize+1)
+	for index, samples := range channels {
+		weight := channel_weight(index, len(channels))
+		if weight == 0 {
+			continue
+		}
+
+		filtered := k_weighted(samples, sampleRate)
+		for block := range blocks {
+			sum := 0.
+			for _, sample := range filtered[block*stepSize : block*stepSize+blockSize] {
+				sum += sample * sample
+			}
+			blocks[block] += weight * sum / float64(blockSize)
+		}
+	}
+
+	relative, ok := gated_loudness(blocks, absolute_gate)
+	if !ok {
+		return 0, ErrSilent
+	}
+	loudness, ok := gated_loudness(blocks, relative+relative_gate)
+	if !ok {
+		return 0, ErrSilent
+	}
+	return loudness, nil
+}
+
+// the loudness of the blocks louder than said gate, if any
+func gated_loudness(blocks []float64, gate float64) (float64, bool) {
+	sum, count := 0., 0
+	for _, power := range blocks {
+		if power > 0 && loudness_of(power) > gate {
+			sum += power
+			count++
+		}
+	}
+
+	if count == 0 {
+		return 0, false
+	}
+	return loudness_of(sum / float64(count)), true
+}
+
+func loudness_of(power float64) float64 {
+	return loudness_offset + 10*math.Log10(power)
+}
+
+// left, right and center count as they are, the surround channels amplified and the low frequency one left out
+func channel_weight(index int, channels int) float64 {
+	switch {
+	case channels < 6:
+		if index >= 3 {
+			return surround_weight
+		}
+		return 1
+	case index == 3:
+		return 0 // lfe of 5.1
+	case index >= 4:
+		return surround_weight
+	}
+	return 1
+}
+
+// the samples passed through the two stages of the k-weighting filter, a high shelf and a high pass,
+// of which the coefficients are derived for any sample rate (as libebur128 does)
+func k_weighted(samples []float64, sampleRate float64) []float64 {
+	// high shelf, modelling the acoustic effect of the head
+	k := math.Tan(math.Pi * 1681.974450955533 / sampleRate)
+	q := 0.7071752369554196
+	vh := math.Pow(10, 3.999843853973347/20)
+	vb := math.Pow(vh, 0.4996667741545416)
+	a0 := 1 + k/q + k*k
+	shelf := biquad{
+		b: [3]float64{(vh + vb*k/q + k*k) / a0, 2 * (k*k - vh) / a0, (vh - vb*k/q + k*k) / a0},
+		a: [2]float64{2 * (k*k - 1) / a0, (1 - k/q + k*k) / a0},
+	}
+
+	// high pass, the "revised low frequency b-curve"
+	k = math.Tan(math.Pi * 38.13547087602444 / sampleRate)
+	q = 0.5003270373238773
+	a0 = 1 + k/q + k*k
+	pass := biquad{
+		b: [3]float64{1, -2, 1},
+		a: [2]float64{2 * (k*k - 1) / a0, (1 - k/q + k*k) / a0},
+	}
+
+	return pass.apply(shelf.apply(samples))
+}
+
+type biquad struct {
+	b [3]float64
+	a [2]float64 // a0 being normalized to 1
+}
+
+func (r biquad) apply(samples []float64) []float64 {
+	filtered := make([]float64, len(samples))
+	var x1, x2, y1, y2 float64
+	for index, x := range samples {
+		y := r.b[0]*x + r.b[1]*x1 + r.b[2]*x2 - r.a[0]*y1 - r.a[1]*y2
+		x2, x1 = x1, x
+		y2, y1 = y1, y
+		filtered[index] = y
+	}
+	return filtered
+}
@@ -0,0 +1,59 @@
+package sound
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"math"
+	"testing"
+)
+
+func TestLoudnessOfTone(t *testing.T) {
+	loudness, err := Loudness(wav_of(48000, 2*48000, 0.5))
+	if err != nil {
+		t.Fatalf("not measurable: %s", err)
+	}
+	// a full scale sine measures -3 LUFS (at 1 kHz), this one half as loud
+	if loudness < -10 || loudness > -8 {
+		t.Errorf("measured %.2f LUFS, expected about -9", loudness)
+	}
+}
+
+func TestLoudnessOfLowSampleRates(t *testing.T) {
+	for _, sampleRate := range []uint32{1, 5, 9} {
+		content := wav_of(sampleRate, 40, 0.5)
+		if _, err := Loudness(content); !errors.Is(err, ErrSilent) {
+			t.Errorf("at %d Hz got %v, expected it unmeasurable", sampleRate, err)
+		}
+		if _, err := ProbeBytes(content); err == nil {
+			t.Errorf("a sample rate of %d Hz was accepted", sampleRate)
+		}
+	}
+}
+
+//////////////////////
+// HELPER FUNCTIONS //
+//////////////////////
+
+// a mono 16 bit wav of said frames, a 1 kHz sine of said amplitude
+func wav_of(sampleRate uint32, frames int, amplitude float64) []byte {
+	dataSize := uint32(frames * 2)
+
+	var buffer bytes.Buffer
+	buffer.WriteString("RIFF")
+	binary.Write(&buffer, binary.LittleEndian, 36+dataSize)
+	buffer.WriteString("WAVEfmt ")
+	for _, field := range []interface{}{
+		uint32(16), uint16(1), uint16(1), sampleRate, sampleRate * 2, uint16(2), uint16(16),
+	} {
+		binary.Write(&buffer, binary.LittleEndian, field)
+	}
+	buffer.WriteString("data")
+	binary.Write(&buffer, binary.LittleEndian, dataSize)
+
+	for frame := 0; frame < frames; frame++ {
+		sample := amplitude * math.Sin(2*math.Pi*1000*float64(frame)/float64(sampleRate))
+		binary.Write(&buffer, binary.LittleEndian, int16(sample*math.MaxInt16))
+	}
+	return buffer.Bytes()
+}
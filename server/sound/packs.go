@@ -0,0 +1,159 @@
+package sound
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/imoliwer/sound-point-twitch-bot/server/app"
+	"github.com/imoliwer/sound-point-twitch-bot/server/model"
+	"github.com/imoliwer/sound-point-twitch-bot/server/util"
+	"github.com/uptrace/bun"
+)
+
+// ErrLocked is returned when deploying a sound of a pack its player hasn't unlocked.
+var ErrLocked = errors.New("sound is of a pack not unlocked")
+
+// Packs tell which packs of sounds every user unlocked. They're kept in the database, mirrored in memory.
+type Packs struct {
+	app   *app.Application
+	mutex sync.Mutex
+	owned map[string]map[string]bool // by user, then by pack
+}
+
+func NewPacks(application *app.Application) *Packs {
+	return &Packs{
+		app:   application,
+		owned: make(map[string]map[string]bool),
+	}
+}
+
+// Load reads every pack unlocked from the database.
+func (r *Packs) Load(ctx context.Context) error {
+	var ownerships []model.PackOwnership
+	if err := r.app.Database.NewSelect().Model(&ownerships).Scan(ctx); err != nil {
+		return err
+	}
+
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	for _, ownership := range ownerships {
+		r.add(ownership.UserID, ownership.Pack)
+	}
+	return nil
+}
+
+// Owns returns whether said user unlocked said pack.
+func (r *Packs) Owns(userID string, pack string) bool {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	return r.owned[userID][pack]
+}
+
+// Permits returns whether said user may play said sound, and if not, the packs any of which would unlock it.
+func (r *Packs) Permits(userID string, sound string) (bool, []string) {
+	packs := r.app.Settings().Audio.PacksOf(sound)
+	if len(packs) == 0 {
+		return true, nil
+	}
+
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	for _, pack := range packs {
+		if r.owned[userID][pack] {
+			return true, nil
+		}
+	}
+	return false, packs
+}
+
+// Unlock spends the points of said user on said pack, returning false if the user has too few.
+func (r *Packs) Unlock(ctx context.Context, userID string, pack string, price uint64) (bool, error) {
+	id, err := util.Uint64(userID)
+	if err != nil {
+		return false, err
+	}
+
+	spent := false
+	err = r.app.Database.RunInTx(ctx, nil, func(ctx context.Context, tx bun.Tx) error {
+		if spent, err = model.SpendPoints(ctx, tx, id, price); err != nil || !spent {
+			return err
+		}
+		return r.insert(ctx, tx, userID, pack)
+	})
+	if err != nil || !spent {
+		return false, err
+	}
+
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	r.add(userID, pack)
+	return true, nil
+}
+
+// Grant unlocks said pack for said user free of charge.
+func (r *Packs) Grant(ctx context.Context, userID string, pack string) error {
+	if err := r.insert(ctx, r.app.Database, userID, pack); err != nil {
+		return err
+	}
+
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	r.add(userID, pack)
+	return nil
+}
+
+// Revoke locks said pack again for said user, without refunding anything.
+func (r *Packs) Revoke(ctx context.Context, userID string, pack string) error {
+	_, err := r.app.Database.
+		NewDelete().
+		Model((*model.PackOwnership)(nil)).
+		Where("pack = ? AND user_id = ?", pack, userID).
+		Exec(ctx)
+	if err != nil {
+		return err
+	}
+
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	delete(r.owned[userID], pack)
+	return nil
+}
+
+// OwnersOf returns how many users unlocked said pack.
+func (r *Packs) OwnersOf(pack string) int {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	count := 0
+	for _, packs := range r.owned {
+		if packs[pack] {
+			count++
+		}
+	}
+	return count
+}
+
+//////////////////////
+// HELPER FUNCTIONS //
+//////////////////////
+
+func (r *Packs) insert(ctx context.Context, db bun.IDB, userID string, pack string) error {
+	_, err := db.
+		NewInsert().
+		Model(&model.PackOwnership{Pack: pack, UserID: userID, UnlockedAt: time.Now()}).
+		On("CONFLICT (pack, user_id) DO NOTHING").
+		Exec(ctx)
+	return err
+}
+
+// must be called while holding the mutex
+func (r *Packs) add(userID string, pack string) {
+	packs, ok := r.owned[userID]
+	if !ok {
+		packs = make(map[string]bool)
+		r.owned[userID] = packs
+	}
+	packs[pack] = true
+}
@@ -0,0 +1,136 @@
+package sound
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/imoliwer/sound-point-twitch-bot/server/model"
+	"github.com/imoliwer/sound-point-twitch-bot/server/util"
+	"github.com/uptrace/bun"
+)
+
+const (
+	// how far the start of a stream may differ as told by events and polls, which round it differently
+	same_stream_tolerance = time.Minute
+	save_plays_timeout    = 5 * time.Second
+)
+
+// PlayCounter counts how often every sound has been played during the current stream. Once loaded
+// the counts are kept in the database as well, surviving restarts in the middle of a stream.
+type PlayCounter struct {
+	mutex  sync.Mutex
+	plays  map[string]uint64
+	stream time.Time // started at, of the stream counted
+	db     *bun.DB
+}
+
+func NewPlayCounter() *PlayCounter {
+	return &PlayCounter{plays: make(map[string]uint64)}
+}
+
+// Load reads the counts of the last stream from said database, keeping them there from now on.
+func (r *PlayCounter) Load(ctx context.Context, db *bun.DB) error {
+	var counted []model.SoundPlays
+	if err := db.NewSelect().Model(&counted).Scan(ctx); err != nil {
+		return err
+	}
+
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	r.db = db
+	for _, plays := range counted {
+		r.plays[plays.Sound] = plays.Plays
+		r.stream = plays.Stream
+	}
+	return nil
+}
+
+// Attempt counts a play of said sound unless it has been played said most times already (zero being unlimited),
+// returning how often it was played before.
+func (r *PlayCounter) Attempt(name string, most uint64) (uint64, bool) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	played := r.plays[name]
+	if most > 0 && played >= most {
+		return played, false
+	}
+	r.plays[name]++
+	r.save(name)
+	return played, true
+}
+
+// Undo takes back a play counted, e.g. as the deployment failed.
+func (r *PlayCounter) Undo(name string) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	if r.plays[name] > 0 {
+		r.plays[name]--
+		r.save(name)
+	}
+}
+
+func (r *PlayCounter) Of(name string) uint64 {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	return r.plays[name]
+}
+
+// Begin counts the plays of the stream started at said time, forgetting every play unless they're of that
+// very stream, as the bot restarted (or the stream was polled) while it's live.
+func (r *PlayCounter) Begin(startedAt time.Time) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	if !r.stream.IsZero() && abs(r.stream.Sub(startedAt)) <= same_stream_tolerance {
+		return
+	}
+
+	r.plays = make(map[string]uint64)
+	r.stream = startedAt
+	if r.db == nil {
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), save_plays_timeout)
+	defer cancel()
+	if _, err := r.db.NewDelete().Model((*model.SoundPlays)(nil)).Where("1 = 1").Exec(ctx); err != nil {
+		util.Log("Sounds", "Could not forget the plays of the last stream: %s", err)
+	}
+}
+
+//////////////////////
+// HELPER FUNCTIONS //
+//////////////////////
+
+// must be called while holding the mutex, as saved in the order counted
+func (r *PlayCounter) save(name string) {
+	if r.db == nil {
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), save_plays_timeout)
+	defer cancel()
+
+	plays := model.SoundPlays{Sound: name, Stream: r.stream, Plays: r.plays[name]}
+	_, err := r.db.
+		NewInsert().
+		Model(&plays).
+		On("CONFLICT (sound) DO UPDATE").
+		Set("stream = EXCLUDED.stream").
+		Set("plays = EXCLUDED.plays").
+		Exec(ctx)
+	if err != nil {
+		util.Log("Sounds", "Could not save the plays of '%s': %s", name, err)
+	}
+}
+
+func abs(duration time.Duration) time.Duration {
+	if duration < 0 {
+		return -duration
+	}
+	return duration
+}
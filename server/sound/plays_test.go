@@ -0,0 +1,77 @@
+package sound
+
+import (
+	"context"
+	"database/sql"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/imoliwer/sound-point-twitch-bot/server/model"
+	"github.com/uptrace/bun"
+	"github.com/uptrace/bun/dialect/sqlitedialect"
+	"github.com/uptrace/bun/driver/sqliteshim"
+)
+
+func TestPlaysKeptAcrossRestarts(t *testing.T) {
+	db := database_of(t)
+	startedAt := time.Date(2026, 10, 19, 18, 0, 0, 0, time.UTC)
+
+	plays := plays_of(t, db)
+	plays.Begin(startedAt)
+	plays.Attempt("honk", 2)
+
+	// restarted in the middle of the stream, told so by a poll rounding its start differently
+	restarted := plays_of(t, db)
+	restarted.Begin(startedAt.Add(300 * time.Millisecond))
+	if played := restarted.Of("honk"); played != 1 {
+		t.Fatalf("played %d times once restarted, expected once", played)
+	}
+	if played, _ := restarted.Attempt("honk", 2); played != 1 {
+		t.Errorf("the second play was told to be play %d", played+1)
+	}
+}
+
+func TestPlaysForgottenOnceStreamStarts(t *testing.T) {
+	db := database_of(t)
+	startedAt := time.Date(2026, 10, 19, 18, 0, 0, 0, time.UTC)
+
+	plays := plays_of(t, db)
+	plays.Begin(startedAt)
+	plays.Attempt("honk", 2)
+	plays.Begin(startedAt.Add(24 * time.Hour))
+
+	if played := plays.Of("honk"); played != 0 {
+		t.Errorf("played %d times in the next stream, expected none", played)
+	}
+	if played := plays_of(t, db).Of("honk"); played != 0 {
+		t.Errorf("played %d times in the next stream once restarted, expected none", played)
+	}
+}
+
+//////////////////////
+// HELPER FUNCTIONS //
+//////////////////////
+
+func database_of(t *testing.T) *bun.DB {
+	sqlDb, err := sql.Open(sqliteshim.ShimName, filepath.Join(t.TempDir(), "bot.db"))
+	if err != nil {
+		t.Fatalf("opening database: %s", err)
+	}
+
+	db := bun.NewDB(sqlDb, sqlitedialect.New())
+	t.Cleanup(func() { db.Close() })
+
+	if err := model.Migrate(context.Background(), db); err != nil {
+		t.Fatalf("migrating database: %s", err)
+	}
+	return db
+}
+
+func plays_of(t *testing.T, db *bun.DB) *PlayCounter {
+	plays := NewPlayCounter()
+	if err := plays.Load(context.Background(), db); err != nil {
+		t.Fatalf("loading plays: %s", err)
+	}
+	return plays
+}
@@ -0,0 +1,107 @@
+package sound
+
+import (
+	"math"
+	"sync"
+	"time"
+
+	"github.com/imoliwer/sound-point-twitch-bot/server/app"
+	"github.com/imoliwer/sound-point-twitch-bot/server/twitch_irc"
+)
+
+// SurgeTracker tells how many plays of every sound are recent, each play wearing off by half every half life.
+type SurgeTracker struct {
+	mutex  sync.Mutex
+	recent map[string]surge_level
+}
+
+func NewSurgeTracker() *SurgeTracker {
+	return &SurgeTracker{recent: make(map[string]surge_level)}
+}
+
+// Record counts a play of said sound.
+func (r *SurgeTracker) Record(name string, halfLife time.Duration) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	now := time.Now()
+	r.recent[name] = surge_level{plays: r.level_of(name, halfLife, now) + 1, at: now}
+}
+
+// Of returns how many plays of said sound are recent, those partially worn off counting partially.
+func (r *SurgeTracker) Of(name string, halfLife time.Duration) float64 {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	return r.level_of(name, halfLife, time.Now())
+}
+
+// PriceOf returns the price said sound currently has, for a buyer of said subscription tier (zero if not subscribed).
+func (r *DeploymentCover) PriceOf(name string, reference app.AudioReference, tier twitch_irc.SubscriptionTier) uint64 {
+	return r.PriceOfPlay(name, reference, tier, r.Plays.Of(name))
+}
+
+// PriceOfPlay returns the price of a play of said sound, played said times before during the stream,
+// as counted when attempted (so two plays at once can't both be the first).
+func (r *DeploymentCover) PriceOfPlay(name string, reference app.AudioReference, tier twitch_irc.SubscriptionTier, played uint64) uint64 {
+	pricing := reference.Pricing
+	if pricing == nil {
+		return reference.Price
+	}
+
+	if pricing.FirstPlayFree && played == 0 {
+		return 0
+	}
+
+	price := float64(reference.Price)
+	if surge := pricing.Surge; surge != nil {
+		price *= surge.Multiplier(r.Surge.Of(name, surge.HalfLifeOf()))
+	}
+
+	// overlapping happy hours don't add up, the best of them applies
+	now, happyHour := time.Now(), uint64(0)
+	for _, candidate := range pricing.HappyHours {
+		if candidate.DiscountPercent > happyHour && candidate.Active(now) {
+			happyHour = candidate.DiscountPercent
+		}
+	}
+	price = discounted(price, happyHour)
+
+	if discounts := pricing.SubscriberDiscounts; discounts != nil && tier >= twitch_irc.Tier1 {
+		price = discounted(price, discounts.PercentOf(int(tier-twitch_irc.Tier1)+1))
+	}
+	return uint64(math.Round(price))
+}
+
+// Played records a play of said sound, raising its price if surge pricing applies.
+func (r *DeploymentCover) Played(name string, reference app.AudioReference) {
+	halfLife := app.DefaultSurgeHalfLife
+	if reference.Pricing != nil && reference.Pricing.Surge != nil {
+		halfLife = reference.Pricing.Surge.HalfLifeOf()
+	}
+	r.Surge.Record(name, halfLife)
+}
+
+//////////////////////
+// HELPER FUNCTIONS //
+//////////////////////
+
+type surge_level struct {
+	plays float64
+	at    time.Time
+}
+
+// must be called while holding the mutex
+func (r *SurgeTracker) level_of(name string, halfLife time.Duration, now time.Time) float64 {
+	level, ok := r.recent[name]
+	if !ok || halfLife <= 0 {
+		return 0
+	}
+	return level.plays * math.Pow(0.5, float64(now.Sub(level.at))/float64(halfLife))
+}
+
+func discounted(price float64, percent uint64) float64 {
+	if percent >= 100 {
+		return 0
+	}
+	return price * float64(100-percent) / 100
+}
@@ -0,0 +1,47 @@
+package sound
+
+import (
+	"testing"
+
+	"github.com/imoliwer/sound-point-twitch-bot/server/app"
+	"github.com/imoliwer/sound-point-twitch-bot/server/twitch_irc"
+)
+
+func TestPriceOfPlay(t *testing.T) {
+	always := func(percent uint64) app.HappyHour {
+		return app.HappyHour{Start: "00:00", End: "00:00", DiscountPercent: percent}
+	}
+
+	for name, test := range map[string]struct {
+		price   uint64
+		pricing *app.Pricing
+		tier    twitch_irc.SubscriptionTier
+		played  uint64
+		recent  int
+		expect  uint64
+	}{
+		"fixed":                       {101, nil, twitch_irc.Tier3, 0, 3, 101},
+		"first play free":             {100, &app.Pricing{FirstPlayFree: true}, 0, 0, 0, 0},
+		"second play paid":            {100, &app.Pricing{FirstPlayFree: true}, 0, 1, 0, 100},
+		"surge by every play":         {100, &app.Pricing{Surge: &app.SurgePricing{StepPercent: 50}}, 0, 0, 2, 200},
+		"surge up to the most":        {100, &app.Pricing{Surge: &app.SurgePricing{StepPercent: 50, MaxPercent: 60}}, 0, 0, 2, 160},
+		"half rounded up":             {5, &app.Pricing{SubscriberDiscounts: &app.SubscriberDiscounts{Tier1: 50}}, twitch_irc.Tier1, 0, 0, 3},
+		"fraction rounded to nearest": {100, &app.Pricing{SubscriberDiscounts: &app.SubscriberDiscounts{Tier2: 33}}, twitch_irc.Tier2, 0, 0, 67},
+		"prime not discounted":        {100, &app.Pricing{SubscriberDiscounts: &app.SubscriberDiscounts{Tier1: 50}}, twitch_irc.TierPrime, 0, 0, 100},
+		"whole discount":              {100, &app.Pricing{SubscriberDiscounts: &app.SubscriberDiscounts{Tier3: 100}}, twitch_irc.Tier3, 0, 0, 0},
+		"discount beyond whole":       {100, &app.Pricing{HappyHours: []app.HappyHour{always(150)}}, 0, 0, 0, 0},
+		"best happy hour only":        {100, &app.Pricing{HappyHours: []app.HappyHour{always(20), always(50)}}, 0, 0, 0, 50},
+		"discounts one after another": {100, &app.Pricing{HappyHours: []app.HappyHour{always(50)}, SubscriberDiscounts: &app.SubscriberDiscounts{Tier3: 50}}, twitch_irc.Tier3, 0, 0, 25},
+		"surge before discounts":      {100, &app.Pricing{Surge: &app.SurgePricing{StepPercent: 100}, HappyHours: []app.HappyHour{always(50)}}, 0, 0, 1, 100},
+	} {
+		cover := NewCover(0, 0)
+		reference := app.AudioReference{Price: test.price, Pricing: test.pricing}
+		for index := 0; index < test.recent; index++ {
+			cover.Played("honk", reference)
+		}
+
+		if price := cover.PriceOfPlay("honk", reference, test.tier, test.played); price != test.expect {
+			t.Errorf("%s: priced at %d, expected %d", name, price, test.expect)
+		}
+	}
+}
@@ -0,0 +1,360 @@
+package sound
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"time"
+)
+
+// formats of audio recognized
+const (
+	FormatWAV  = "wav"
+	FormatMP3  = "mp3"
+	FormatOGG  = "ogg"
+	FormatFLAC = "flac"
+)
+
+// Formats recognized, in the order they're listed to users.
+var Formats = []string{FormatMP3, FormatOGG, FormatWAV, FormatFLAC}
+
+var ErrUnknownFormat = errors.New("unknown audio format")
+
+// the lowest sample rate of wav accepted, that of telephony
+const min_sample_rate = 8000
+
+// AudioInfo is what probing an audio file tells about it.
+type AudioInfo struct {
+	Format     string        `json:"format"`
+	Duration   time.Duration `json:"duration"`
+	SampleRate uint32        `json:"sample_rate"`
+	Channels   uint16        `json:"channels"`
+	Bitrate    uint32        `json:"bitrate"` // in bits per second, averaged over the whole file
+}
+
+// Probe reads the audio file at said path, telling its format and duration without decoding it.
+func Probe(path string) (AudioInfo, error) {
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return AudioInfo{}, err
+	}
+	return ProbeBytes(content)
+}
+
+// ProbeBytes is Probe for audio already read, the format being told by its magic bytes rather than any name.
+func ProbeBytes(content []byte) (AudioInfo, error) {
+	info, err := probe_format(content)
+	if err != nil {
+		return info, err
+	}
+
+	// the bitrate of a wav is exact, that of the others averaged over what the file takes
+	if info.Bitrate == 0 && info.Duration > 0 {
+		info.Bitrate = uint32(float64(len(content)*8) / info.Duration.Seconds())
+	}
+	return info, nil
+}
+
+//////////////////////
+// HELPER FUNCTIONS //
+//////////////////////
+
+func probe_format(content []byte) (AudioInfo, error) {
+	switch {
+	case len(content) >= 12 && string(content[:4]) == "RIFF" && string(content[8:12]) == "WAVE":
+		return probe_wav(content)
+	case len(content) >= 4 && string(content[:4]) == "OggS":
+		return probe_ogg(content)
+	case len(content) >= 4 && string(content[:4]) == "fLaC":
+		return probe_flac(content)
+	case len(content) >= 3 && string(content[:3]) == "ID3":
+		// a tag may precede flac as well, however rarely
+		if rest := content[min_of(id3_end(content), len(content)):]; len(rest) >= 4 && string(rest[:4]) == "fLaC" {
+			return probe_flac(rest)
+		}
+		return probe_mp3(content)
+	case len(content) >= 2 && content[0] == 0xFF && content[1]&0xE0 == 0xE0:
+		return probe_mp3(content)
+	}
+	return AudioInfo{}, ErrUnknownFormat
+}
+
+// the "fmt " and "data" chunks of a wav
+type wav_chunks struct {
+	formatTag     uint16 // 1 being integer pcm, 3 floating point
+	channels      uint16
+	sampleRate    uint32
+	byteRate      uint32
+	blockAlign    uint16
+	bitsPerSample uint16
+	data          []byte
+}
+
+func wav_chunks_of(content []byte) (wav_chunks, error) {
+	var chunks wav_chunks
+	hasFormat := false
+
+	for offset := 12; offset+8 <= len(content); {
+		id, size := string(content[offset:offset+4]), binary.LittleEndian.Uint32(content[offset+4:offset+8])
+		body := offset + 8
+
+		switch id {
+		case "fmt ":
+			if body+16 > len(content) {
+				return chunks, io.ErrUnexpectedEOF
+			}
+			chunks.formatTag = binary.LittleEndian.Uint16(content[body:])
+			chunks.channels = binary.LittleEndian.Uint16(content[body+2:])
+			chunks.sampleRate = binary.LittleEndian.Uint32(content[body+4:])
+			chunks.byteRate = binary.LittleEndian.Uint32(content[body+8:])
+			chunks.blockAlign = binary.LittleEndian.Uint16(content[body+12:])
+			chunks.bitsPerSample = binary.LittleEndian.Uint16(content[body+14:])
+
+			// WAVE_FORMAT_EXTENSIBLE tells the actual format by the first two bytes of its sub format
+			if chunks.formatTag == 0xFFFE && body+26 <= len(content) {
+				chunks.formatTag = binary.LittleEndian.Uint16(content[body+24:])
+			}
+			hasFormat = true
+		case "data":
+			// truncated, or streamed without knowing its size
+			chunks.data = content[body:min_of(body+int(size), len(content))]
+		}
+
+		// chunks are padded to an even size
+		offset = body + int(size) + int(size&1)
+	}
+
+	if !hasFormat || chunks.byteRate == 0 {
+		return chunks, errors.New("wav without format")
+	}
+	return chunks, nil
+}
+
+func probe_wav(content []byte) (AudioInfo, error) {
+	chunks, err := wav_chunks_of(content)
+	if err != nil {
+		return AudioInfo{Format: FormatWAV}, err
+	}
+
+	if chunks.sampleRate < min_sample_rate {
+		return AudioInfo{Format: FormatWAV}, fmt.Errorf("sample rate of %d Hz is below %d Hz", chunks.sampleRate, min_sample_rate)
+	}
+
+	return AudioInfo{
+		Format:     FormatWAV,
+		Duration:   time.Duration(float64(len(chunks.data)) / float64(chunks.byteRate) * float64(time.Second)),
+		SampleRate: chunks.sampleRate,
+		Channels:   chunks.channels,
+		Bitrate:    chunks.byteRate * 8,
+	}, nil
+}
+
+// the duration is told by the total samples within the stream info, the block always coming first
+func probe_flac(content []byte) (AudioInfo, error) {
+	info := AudioInfo{Format: FormatFLAC}
+	if len(content) < 8+34 || content[4]&0x7F != 0 {
+		return info, errors.New("flac without stream info")
+	}
+
+	streamInfo := content[8 : 8+34]
+	packed := binary.BigEndian.Uint64(streamInfo[10:18])
+	info.SampleRate = uint32(packed >> 44)
+	info.Channels = uint16(packed>>41&0x7) + 1
+	samples := packed & 0xFFFFFFFFF // 36 bits, zero if unknown
+
+	if info.SampleRate == 0 {
+		return info, errors.New("flac of an invalid sample rate")
+	}
+	info.Duration = time.Duration(float64(samples) / float64(info.SampleRate) * float64(time.Second))
+	return info, nil
+}
+
+// the offset right after the id3 tag at the start of said content
+func id3_end(content []byte) int {
+	if len(content) < 10 {
+		return len(content)
+	}
+
+	// the size is "synchsafe", 7 bits per byte
+	size := int(content[6])<<21 | int(content[7])<<14 | int(content[8])<<7 | int(content[9])
+	end := 10 + size
+	if content[5]&0x10 != 0 {
+		end += 10 // footer
+	}
+	return end
+}
+
+var (
+	// kbps by version (1 or 2, the latter covering 2.5) and layer, by bitrate index
+	mp3_bitrates = map[[2]int][16]int{
+		{1, 1}: {0, 32, 64, 96, 128, 160, 192, 224, 256, 288, 320, 352, 384, 416, 448},
+		{1, 2}: {0, 32, 48, 56, 64, 80, 96, 112, 128, 160, 192, 224, 256, 320, 384},
+		{1, 3}: {0, 32, 40, 48, 56, 64, 80, 96, 112, 128, 160, 192, 224, 256, 320},
+		{2, 1}: {0, 32, 48, 56, 64, 80, 96, 112, 128, 144, 160, 176, 192, 224, 256},
+		{2, 2}: {0, 8, 16, 24, 32, 40, 48, 56, 64, 80, 96, 112, 128, 144, 160},
+		{2, 3}: {0, 8, 16, 24, 32, 40, 48, 56, 64, 80, 96, 112, 128, 144, 160},
+	}
+	// hz by version bits, by sample rate index
+	mp3_sample_rates = map[int][3]int{
+		3: {44100, 48000, 32000}, // 1
+		2: {22050, 24000, 16000}, // 2
+		0: {11025, 12000, 8000},  // 2.5
+	}
+)
+
+type mp3_frame struct {
+	length     int
+	samples    int
+	sampleRate int
+	channels   uint16
+}
+
+// every frame is walked, as the bitrate may vary from one to another
+func probe_mp3(content []byte) (AudioInfo, error) {
+	info := AudioInfo{Format: FormatMP3}
+
+	offset := 0
+	if len(content) >= 3 && string(content[:3]) == "ID3" {
+		offset = id3_end(content)
+	}
+
+	var seconds float64
+	frames := 0
+	for offset+4 <= len(content) {
+		frame, ok := mp3_frame_at(content[offset:])
+		if !ok {
+			offset++ // resynchronize
+			continue
+		}
+
+		if frames == 0 {
+			info.SampleRate, info.Channels = uint32(frame.sampleRate), frame.channels
+		}
+		seconds += float64(frame.samples) / float64(frame.sampleRate)
+		offset += frame.length
+		frames++
+	}
+
+	if frames == 0 {
+		return info, errors.New("mp3 without frames")
+	}
+	info.Duration = time.Duration(seconds * float64(time.Second))
+	return info, nil
+}
+
+func mp3_frame_at(content []byte) (mp3_frame, bool) {
+	header := binary.BigEndian.Uint32(content)
+	if header>>21 != 0x7FF {
+		return mp3_frame{}, false
+	}
+
+	versionBits := int(header>>19) & 3
+	layerBits := int(header>>17) & 3
+	bitrateIndex := int(header>>12) & 15
+	sampleRateIndex := int(header>>10) & 3
+	padding := int(header>>9) & 1
+	channelMode := int(header>>6) & 3
+
+	if versionBits == 1 || layerBits == 0 || bitrateIndex == 0 || bitrateIndex == 15 || sampleRateIndex == 3 {
+		return mp3_frame{}, false
+	}
+
+	version := 1
+	if versionBits != 3 {
+		version = 2
+	}
+	layer := 4 - layerBits
+
+	bitrate := mp3_bitrates[[2]int{version, layer}][bitrateIndex] * 1000
+	sampleRate := mp3_sample_rates[versionBits][sampleRateIndex]
+
+	frame := mp3_frame{sampleRate: sampleRate, channels: 2}
+	if channelMode == 3 {
+		frame.channels = 1
+	}
+
+	switch {
+	case layer == 1:
+		frame.samples = 384
+		frame.length = (12*bitrate/sampleRate + padding) * 4
+	case layer == 3 && version == 2:
+		frame.samples = 576
+		frame.length = 72*bitrate/sampleRate + padding
+	default:
+		frame.samples = 1152
+		frame.length = 144*bitrate/sampleRate + padding
+	}
+
+	if frame.length < 4 {
+		return mp3_frame{}, false
+	}
+	return frame, true
+}
+
+// the duration is told by the granule position of the last page, in samples
+func probe_ogg(content []byte) (AudioInfo, error) {
+	info := AudioInfo{Format: FormatOGG}
+
+	var granule uint64
+	var preSkip uint64
+	first := true
+
+	for offset := 0; offset+27 <= len(content); {
+		if string(content[offset:offset+4]) != "OggS" {
+			return info, errors.New("malformed ogg page")
+		}
+
+		segments := int(content[offset+26])
+		if offset+27+segments > len(content) {
+			break
+		}
+
+		size := 0
+		for _, lacing := range content[offset+27 : offset+27+segments] {
+			size += int(lacing)
+		}
+
+		body := offset + 27 + segments
+		if position := binary.LittleEndian.Uint64(content[offset+6:]); position != ^uint64(0) {
+			granule = position
+		}
+
+		if first {
+			first = false
+			packet := content[body:min_of(body+size, len(content))]
+
+			switch {
+			case len(packet) >= 16 && bytes.HasPrefix(packet, []byte("\x01vorbis")):
+				info.Channels = uint16(packet[11])
+				info.SampleRate = binary.LittleEndian.Uint32(packet[12:])
+			case len(packet) >= 16 && bytes.HasPrefix(packet, []byte("OpusHead")):
+				info.Channels = uint16(packet[9])
+				preSkip = uint64(binary.LittleEndian.Uint16(packet[10:]))
+				info.SampleRate = 48000 // opus is always decoded at 48 kHz, whatever the input rate
+			default:
+				return info, errors.New("ogg of neither vorbis nor opus")
+			}
+		}
+
+		offset = body + size
+	}
+
+	if info.SampleRate == 0 {
+		return info, errors.New("ogg without header")
+	}
+	if granule > preSkip {
+		granule -= preSkip
+	}
+	info.Duration = time.Duration(float64(granule) / float64(info.SampleRate) * float64(time.Second))
+	return info, nil
+}
+
+func min_of(a int, b int) int {
+	if a < b {
+		return a
+	}
+	return b
+}
@@ -0,0 +1,455 @@
+package sound
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"math"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/imoliwer/sound-point-twitch-bot/server/app"
+	"github.com/imoliwer/sound-point-twitch-bot/server/util"
+)
+
+const (
+	// the originals are kept within a directory of their own, beside those processed
+	originals_directory = "originals"
+	processing_timeout  = 2 * time.Minute
+	// kept before and after what's trimmed, so nothing audible is cut off
+	silence_padding = 20 * time.Millisecond
+	// dBFS, the gain applied never clipping the loudest sample
+	peak_ceiling = -1.
+	// what ffmpeg decodes into, and encodes to
+	decoded_rate      = 48000
+	decoded_channels  = 2
+	processed_bitrate = "192k"
+)
+
+// Processor converts sounds one at a time in the order queued, keeping their originals.
+// Anything but pcm wav is decoded (and the result encoded into mp3) with ffmpeg, if available,
+// and otherwise only pcm wav is processed, into pcm wav.
+type Processor struct {
+	app     *app.Application
+	mutex   sync.Mutex
+	queue   []string
+	queued  map[string]bool
+	wake    chan struct{}
+	context context.Context
+	cancel  context.CancelFunc
+	done    chan struct{}
+}
+
+// the outcome of processing a sound
+type processed struct {
+	fileName string
+	original string
+	info     AudioInfo
+	loudness *float64
+}
+
+func NewProcessor(application *app.Application) *Processor {
+	ctx, cancel := context.WithCancel(context.Background())
+	return &Processor{
+		app:     application,
+		queued:  make(map[string]bool),
+		wake:    make(chan struct{}, 1),
+		context: ctx,
+		cancel:  cancel,
+		done:    make(chan struct{}),
+	}
+}
+
+// Start processes what's queued until stopped, queueing anew the sounds of which processing was interrupted.
+func (r *Processor) Start() {
+	go r.run()
+
+	for name, reference := range r.app.Settings().Audio.References {
+		if reference.Processing == app.ProcessingPending || reference.Processing == app.ProcessingRunning {
+			r.Enqueue(name)
+		}
+	}
+}
+
+// Stop stops processing, cancelling what's being processed (which is processed anew on the next start).
+func (r *Processor) Stop() {
+	r.cancel()
+	<-r.done
+}
+
+// Enqueue queues said sound to be processed, unless queued already.
+func (r *Processor) Enqueue(name string) {
+	r.mutex.Lock()
+	if r.queued[name] {
+		r.mutex.Unlock()
+		return
+	}
+	r.queued[name] = true
+	r.queue = append(r.queue, name)
+	r.mutex.Unlock()
+
+	r.update(name, func(reference *app.AudioReference) {
+		reference.Processing, reference.ProcessingError = app.ProcessingPending, ""
+	})
+
+	select {
+	case r.wake <- struct{}{}:
+	default: // awake already
+	}
+}
+
+//////////////////////
+// HELPER FUNCTIONS //
+//////////////////////
+
+func (r *Processor) run() {
+	defer close(r.done)
+	for {
+		select {
+		case <-r.context.Done():
+			return
+		case <-r.wake:
+		}
+
+		for {
+			r.mutex.Lock()
+			if len(r.queue) == 0 || r.context.Err() != nil {
+				r.mutex.Unlock()
+				break
+			}
+			name := r.queue[0]
+			r.queue = r.queue[1:]
+			delete(r.queued, name)
+			r.mutex.Unlock()
+
+			r.process(name)
+		}
+	}
+}
+
+func (r *Processor) process(name string) {
+	reference, ok := r.app.Settings().Audio.References[name]
+	if !ok {
+		return // deleted while queued
+	}
+
+	r.update(name, func(reference *app.AudioReference) {
+		reference.Processing = app.ProcessingRunning
+	})
+
+	ctx, cancel := context.WithTimeout(r.context, processing_timeout)
+	defer cancel()
+
+	result, err := r.convert(ctx, reference)
+	if err != nil {
+		if r.context.Err() != nil {
+			return // left running, so it's processed anew on the next start
+		}
+
+		util.Log("Sounds", "Could not process '%s': %s", name, err)
+		r.update(name, func(reference *app.AudioReference) {
+			reference.Processing, reference.ProcessingError = app.ProcessingFailed, err.Error()
+		})
+		return
+	}
+
+	var replaced string
+	updated := r.update(name, func(reference *app.AudioReference) {
+		replaced = reference.FileName
+		reference.FileName = result.fileName
+		reference.OriginalFileName = result.original
+		reference.Duration = result.info.Duration.Seconds()
+		reference.Format = result.info.Format
+		reference.SampleRate = result.info.SampleRate
+		reference.Bitrate = result.info.Bitrate
+		reference.Loudness = result.loudness
+		reference.Processing, reference.ProcessingError = app.ProcessingDone, ""
+	})
+
+	switch {
+	case !updated: // deleted while processed
+		os.Remove(path_of(result.fileName))
+		os.Remove(original_path_of(result.original))
+	case replaced != result.fileName:
+		os.Remove(path_of(replaced)) // a copy of the original, or what it was processed into before
+	}
+	util.Log("Sounds", "Processed '%s' into '%s'.", name, result.fileName)
+}
+
+func (r *Processor) convert(ctx context.Context, reference app.AudioReference) (processed, error) {
+	audio := r.app.Settings().Audio
+	settings := audio.Processing
+
+	ffmpeg, err := ffmpeg_of(settings)
+	if err != nil {
+		return processed{}, err
+	}
+
+	// the original is never touched again, what's processed anew being processed from it
+	original := reference.OriginalFileName
+	if original == "" {
+		original = reference.FileName
+		if err := keep_original(original); err != nil {
+			return processed{}, fmt.Errorf("keeping the original: %w", err)
+		}
+	}
+
+	content, err := os.ReadFile(original_path_of(original))
+	if err != nil {
+		return processed{}, err
+	}
+
+	channels, sampleRate, err := decode(ctx, ffmpeg, content)
+	if err != nil {
+		return processed{}, err
+	}
+
+	if settings.TrimSilence {
+		channels = trim_silence(channels, sampleRate, settings.SilenceThresholdOf())
+		if len(channels[0]) == 0 {
+			return processed{}, ErrSilent
+		}
+	}
+
+	var loudness *float64
+	if measured, err := integrated_loudness(channels, float64(sampleRate)); err == nil {
+		if audio.TargetLoudness != nil {
+			measured += 20 * math.Log10(normalize(channels, measured, *audio.TargetLoudness))
+		}
+		loudness = &measured
+	}
+
+	encoded, format := encode_wav(channels, sampleRate), FormatWAV
+	if ffmpeg != "" {
+		encoded, err = run_ffmpeg(ctx, ffmpeg, encoded,
+			"-f", "wav", "-i", "pipe:0", "-codec:a", "libmp3lame", "-b:a", processed_bitrate, "-f", "mp3", "pipe:1")
+		if err != nil {
+			return processed{}, fmt.Errorf("encoding: %w", err)
+		}
+		format = FormatMP3
+	}
+
+	info, err := ProbeBytes(encoded)
+	if err != nil {
+		return processed{}, fmt.Errorf("probing what was processed: %w", err)
+	}
+
+	// replacing what's played (if of the same name) at once, so it's never played halfway written
+	fileName := strings.TrimSuffix(original, filepath.Ext(original)) + "." + format
+	temporary := path_of("." + fileName + ".tmp")
+	if err := os.WriteFile(temporary, encoded, 0644); err != nil {
+		return processed{}, err
+	}
+	if err := os.Rename(temporary, path_of(fileName)); err != nil {
+		os.Remove(temporary)
+		return processed{}, err
+	}
+
+	return processed{fileName: fileName, original: original, info: info, loudness: loudness}, nil
+}
+
+// change said sound, if it still exists, returning whether it did
+func (r *Processor) update(name string, change func(*app.AudioReference)) bool {
+	var ok bool
+	r.app.UpdateSettings(func(settings *app.Settings) {
+		var reference app.AudioReference
+		if reference, ok = settings.Audio.References[name]; ok {
+			change(&reference)
+			settings.Audio.References[name] = reference
+		}
+	})
+
+	if ok {
+		r.app.RequestSave()
+	}
+	return ok
+}
+
+func original_path_of(fileName string) string {
+	return filepath.Join(Directory, originals_directory, fileName)
+}
+
+// copy the file of a sound never processed into the directory of originals
+func keep_original(fileName string) error {
+	content, err := os.ReadFile(path_of(fileName))
+	if err != nil {
+		return err
+	}
+
+	if err := os.MkdirAll(filepath.Join(Directory, originals_directory), 0755); err != nil {
+		return err
+	}
+
+	// kept already by a run that failed later on
+	if err := write_exclusively(original_path_of(fileName), content); err != nil && !errors.Is(err, os.ErrExist) {
+		return err
+	}
+	return nil
+}
+
+// the path of ffmpeg, empty if neither configured nor within the PATH
+func ffmpeg_of(settings app.ProcessingSettings) (string, error) {
+	if settings.FFmpegPath != "" {
+		path, err := exec.LookPath(settings.FFmpegPath)
+		if err != nil {
+			return "", fmt.Errorf("ffmpeg configured is unavailable: %w", err)
+		}
+		return path, nil
+	}
+
+	path, err := exec.LookPath("ffmpeg")
+	if err != nil {
+		return "", nil
+	}
+	return path, nil
+}
+
+func run_ffmpeg(ctx context.Context, ffmpeg string, input []byte, arguments ...string) ([]byte, error) {
+	var output, errorOutput bytes.Buffer
+	command := exec.CommandContext(ctx, ffmpeg, append([]string{"-v", "error", "-nostdin"}, arguments...)...)
+	command.Stdin = bytes.NewReader(input)
+	command.Stdout = &output
+	command.Stderr = &errorOutput
+
+	if err := command.Run(); err != nil {
+		if message := strings.TrimSpace(errorOutput.String()); message != "" {
+			return nil, fmt.Errorf("%w: %s", err, message)
+		}
+		return nil, err
+	}
+	return output.Bytes(), nil
+}
+
+// the samples of every channel and their rate, pcm wav decoded as it is and anything else through ffmpeg
+func decode(ctx context.Context, ffmpeg string, content []byte) ([][]float64, uint32, error) {
+	info, err := ProbeBytes(content)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	if info.Format == FormatWAV {
+		chunks, err := wav_chunks_of(content)
+		if err != nil {
+			return nil, 0, err
+		}
+
+		channels, err := pcm_of(chunks)
+		if err == nil || !errors.Is(err, ErrLoudnessUnsupported) || ffmpeg == "" {
+			return channels, chunks.sampleRate, err
+		}
+	}
+
+	if ffmpeg == "" {
+		return nil, 0, fmt.Errorf("decoding %s requires ffmpeg", info.Format)
+	}
+
+	// raw, as wav written into a pipe can't tell its size
+	raw, err := run_ffmpeg(ctx, ffmpeg, content,
+		"-i", "pipe:0", "-f", "f32le", "-acodec", "pcm_f32le", "-ac", fmt.Sprint(decoded_channels), "-ar", fmt.Sprint(decoded_rate), "pipe:1")
+	if err != nil {
+		return nil, 0, fmt.Errorf("decoding: %w", err)
+	}
+
+	frames := len(raw) / (4 * decoded_channels)
+	channels := make([][]float64, decoded_channels)
+	for channel := range channels {
+		channels[channel] = make([]float64, frames)
+		for frame := 0; frame < frames; frame++ {
+			bits := binary.LittleEndian.Uint32(raw[(frame*decoded_channels+channel)*4:])
+			channels[channel][frame] = float64(math.Float32frombits(bits))
+		}
+	}
+	return channels, decoded_rate, nil
+}
+
+// cut what's below said threshold (in dBFS) off the start and end, but for a little padding
+func trim_silence(channels [][]float64, sampleRate uint32, threshold float64) [][]float64 {
+	limit := math.Pow(10, threshold/20)
+	audible := func(frame int) bool {
+		for _, samples := range channels {
+			if math.Abs(samples[frame]) > limit {
+				return true
+			}
+		}
+		return false
+	}
+
+	frames := len(channels[0])
+	start, end := 0, frames
+	for start < frames && !audible(start) {
+		start++
+	}
+	for end > start && !audible(end-1) {
+		end--
+	}
+
+	if start == end {
+		start, end = 0, 0 // silent throughout
+	} else {
+		padding := int(silence_padding.Seconds() * float64(sampleRate))
+		start, end = int(math.Max(0, float64(start-padding))), int(math.Min(float64(frames), float64(end+padding)))
+	}
+
+	trimmed := make([][]float64, len(channels))
+	for index, samples := range channels {
+		trimmed[index] = samples[start:end]
+	}
+	return trimmed
+}
+
+// amplify (or attenuate) the samples towards said target, as far as the peak ceiling allows, returning the gain applied
+func normalize(channels [][]float64, loudness float64, target float64) float64 {
+	peak := 0.
+	for _, samples := range channels {
+		for _, sample := range samples {
+			peak = math.Max(peak, math.Abs(sample))
+		}
+	}
+
+	gain := GainOf(loudness, target)
+	if ceiling := math.Pow(10, peak_ceiling/20); peak > 0 && peak*gain > ceiling {
+		gain = ceiling / peak
+	}
+
+	for _, samples := range channels {
+		for index := range samples {
+			samples[index] *= gain
+		}
+	}
+	return gain
+}
+
+// 16 bit pcm, played by any browser
+func encode_wav(channels [][]float64, sampleRate uint32) []byte {
+	frames, count := len(channels[0]), len(channels)
+	dataSize := uint32(frames * count * 2)
+
+	var buffer bytes.Buffer
+	buffer.Grow(44 + int(dataSize))
+	buffer.WriteString("RIFF")
+	binary.Write(&buffer, binary.LittleEndian, 36+dataSize)
+	buffer.WriteString("WAVEfmt ")
+	for _, field := range []interface{}{
+		uint32(16), uint16(1), uint16(count), sampleRate, sampleRate * uint32(count) * 2, uint16(count * 2), uint16(16),
+	} {
+		binary.Write(&buffer, binary.LittleEndian, field)
+	}
+	buffer.WriteString("data")
+	binary.Write(&buffer, binary.LittleEndian, dataSize)
+
+	sample := make([]byte, 2)
+	for frame := 0; frame < frames; frame++ {
+		for _, samples := range channels {
+			value := math.Max(-1, math.Min(1, samples[frame]))
+			binary.LittleEndian.PutUint16(sample, uint16(int16(math.Round(value*math.MaxInt16))))
+			buffer.Write(sample)
+		}
+	}
+	return buffer.Bytes()
+}
@@ -0,0 +1,235 @@
+package sound
+
+import (
+	"fmt"
+	"html/template"
+	"net/http"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/imoliwer/sound-point-twitch-bot/server/app"
+	"github.com/imoliwer/sound-point-twitch-bot/server/twitch_irc"
+	"github.com/imoliwer/sound-point-twitch-bot/server/util"
+)
+
+// PublicPath is where viewers find the sounds, relative to the public url.
+const PublicPath = "/public/sounds"
+
+// PublicSound is what viewers are shown of a sound, those disabled never being shown.
+type PublicSound struct {
+	Name              string   `json:"name"`
+	Price             uint64   `json:"price"`
+	EffectivePrice    uint64   `json:"effective_price"` // as the price currently is, e.g. surged or discounted
+	Description       string   `json:"description,omitempty"`
+	Tags              []string `json:"tags,omitempty"`
+	Duration          float64  `json:"duration_seconds,omitempty"`
+	Permission        string   `json:"permission"`
+	Available         bool     `json:"available"`
+	CooldownRemaining uint64   `json:"cooldown_remaining_seconds"`
+	PlaysLeft         *uint64  `json:"plays_left_this_stream,omitempty"` // absent if unlimited
+	Packs             []string `json:"packs,omitempty"`                  // any of which has to be unlocked to play it
+	Sounds            []string `json:"sounds,omitempty"`                 // of a group, one of which is picked
+}
+
+// PublicSounds returns the sounds and groups matching said search term (every one if empty), ordered by name, the
+// cooldowns and prices being those of said viewer (if nil, only the cooldowns of everyone and the prices of those
+// not subscribed).
+func PublicSounds(application *app.Application, cover *DeploymentCover, cooldowns *Cooldowns, search string, viewer *twitch_irc.UserState) []PublicSound {
+	population := make([]PublicSound, 0)
+	userID, tier := "", twitch_irc.SubscriptionTier(0)
+	if viewer != nil {
+		userID, tier = viewer.Id, viewer.SubscriptionTier()
+	}
+
+	audio := application.Settings().Audio
+	publicOf := func(name string, reference app.AudioReference) PublicSound {
+		permission := reference.Permission
+		if permission == "" {
+			permission = app.PermissionEveryone
+		}
+
+		public := PublicSound{
+			Name:              name,
+			Price:             reference.Price,
+			EffectivePrice:    cover.PriceOf(name, reference, tier),
+			Description:       reference.Description,
+			Tags:              reference.Tags,
+			Duration:          reference.Duration,
+			Permission:        permission,
+			CooldownRemaining: util.WholeSeconds(cooldowns.Remaining(name, userID)),
+			Packs:             audio.PacksOf(name),
+		}
+
+		if most := reference.MaxPlaysPerStream; most > 0 {
+			left := uint64(0)
+			if played := cover.Plays.Of(name); played < most {
+				left = most - played
+			}
+			public.PlaysLeft = &left
+		}
+
+		public.Available = public.CooldownRemaining == 0 && (public.PlaysLeft == nil || *public.PlaysLeft > 0)
+		return public
+	}
+
+	for name, reference := range audio.References {
+		if reference.IsEnabled() && Matches(name, reference, search) {
+			population = append(population, publicOf(name, reference))
+		}
+	}
+
+	for name, group := range audio.Groups {
+		matches := Matches(name, app.AudioReference{}, search)
+		members := make([]PublicSound, 0, len(group.Sounds))
+		for _, member := range group.Sounds {
+			if reference, ok := audio.References[member.Sound]; ok && reference.IsEnabled() {
+				members = append(members, publicOf(member.Sound, reference))
+				matches = matches || Matches(member.Sound, reference, search)
+			}
+		}
+
+		if matches && len(members) > 0 {
+			population = append(population, public_group_of(name, members, audio.PacksOf(name)))
+		}
+	}
+
+	sort.Slice(population, func(i, j int) bool {
+		return population[i].Name < population[j].Name
+	})
+	return population
+}
+
+// Matches returns whether said sound is matched by said search term, through its name, tags or description.
+func Matches(name string, reference app.AudioReference, search string) bool {
+	search = strings.ToLower(strings.TrimSpace(search))
+	if search == "" || strings.Contains(name, search) || strings.Contains(strings.ToLower(reference.Description), search) {
+		return true
+	}
+
+	for _, tag := range reference.Tags {
+		if strings.Contains(tag, search) {
+			return true
+		}
+	}
+	return false
+}
+
+// PublicHandler serves the sounds to viewers, as a page and as json, neither telling anything but what they may see.
+func PublicHandler(engine *gin.Engine, application *app.Application, cover *DeploymentCover, cooldowns *Cooldowns) {
+	engine.GET(PublicPath+".json", func(ctx *gin.Context) {
+		ctx.JSON(http.StatusOK, PublicSounds(application, cover, cooldowns, ctx.Query("q"), nil))
+	})
+
+	engine.GET(PublicPath, func(ctx *gin.Context) {
+		search := ctx.Query("q")
+		page := public_page{
+			Channel: application.Settings().TwitchBot.Channel,
+			Search:  search,
+			Sounds:  PublicSounds(application, cover, cooldowns, search, nil),
+		}
+
+		ctx.Status(http.StatusOK)
+		ctx.Header("Content-Type", "text/html; charset=utf-8")
+		if err := public_template.Execute(ctx.Writer, page); err != nil {
+			ctx.Error(err)
+		}
+	})
+}
+
+//////////////////////
+// HELPER FUNCTIONS //
+//////////////////////
+
+// a group as its most approachable sound, available if any of its sounds is
+func public_group_of(name string, members []PublicSound, packs []string) PublicSound {
+	group := members[0]
+	group.Name, group.Description, group.Tags, group.Duration, group.PlaysLeft = name, "", nil, 0, nil
+	group.Packs, group.Sounds = packs, make([]string, 0, len(members))
+
+	for _, member := range members {
+		group.Sounds = append(group.Sounds, member.Name)
+		if member.Price < group.Price {
+			group.Price = member.Price
+		}
+		if member.EffectivePrice < group.EffectivePrice {
+			group.EffectivePrice = member.EffectivePrice
+		}
+		if member.CooldownRemaining < group.CooldownRemaining {
+			group.CooldownRemaining = member.CooldownRemaining
+		}
+		if app.PermissionRank(member.Permission) < app.PermissionRank(group.Permission) {
+			group.Permission = member.Permission
+		}
+		group.Available = group.Available || member.Available
+	}
+	return group
+}
+
+type public_page struct {
+	Channel string
+	Search  string
+	Sounds  []PublicSound
+}
+
+var public_template = template.Must(template.New("sounds").Funcs(template.FuncMap{
+	"seconds": func(seconds float64) string {
+		return fmt.Sprintf("%.1fs", seconds)
+	},
+	"join": func(values []string) string {
+		return strings.Join(values, ", ")
+	},
+	"remaining": func(seconds uint64) string {
+		return (time.Duration(seconds) * time.Second).String()
+	},
+}).Parse(`<!DOCTYPE html>
+<html lang="en">
+<head>
+	<meta charset="utf-8">
+	<meta name="viewport" content="width=device-width, initial-scale=1">
+	<title>Sounds{{if .Channel}} of {{.Channel}}{{end}}</title>
+	<style>
+		body { margin: 0 auto; max-width: 960px; padding: 16px; background: #18181b; color: #efeff1; font-family: Arial, sans-serif; }
+		form { margin-bottom: 16px; }
+		input { padding: 6px; background: #26262c; color: inherit; border: 1px solid #3a3a3d; border-radius: 4px; }
+		table { width: 100%; border-collapse: collapse; }
+		th, td { padding: 8px; text-align: left; border-bottom: 1px solid #3a3a3d; vertical-align: top; }
+		.tag { display: inline-block; margin: 0 4px 4px 0; padding: 2px 6px; background: #3a3a3d; border-radius: 4px; font-size: 12px; }
+		.available { color: #00f593; }
+		.unavailable { color: #adadb8; }
+	</style>
+</head>
+<body>
+	<h1>Sounds{{if .Channel}} of {{.Channel}}{{end}}</h1>
+	<form method="get">
+		<input type="search" name="q" value="{{.Search}}" placeholder="Search by name, tag or description">
+	</form>
+	{{if .Sounds}}
+	<table>
+		<tr><th>Name</th><th>Price</th><th>Length</th><th>Description</th><th>Status</th></tr>
+		{{range .Sounds}}
+		<tr>
+			<td>{{.Name}}</td>
+			<td>{{if ne .EffectivePrice .Price}}<s>{{.Price}}</s> {{end}}{{.EffectivePrice}}</td>
+			<td>{{if .Duration}}{{seconds .Duration}}{{end}}</td>
+			<td>
+				{{if .Sounds}}One of {{join .Sounds}}{{else}}{{.Description}}{{end}}
+				<div>{{range .Tags}}<span class="tag">{{.}}</span>{{end}}</div>
+				{{if .Packs}}<div class="unavailable">Unlocked with {{join .Packs}}</div>{{end}}
+			</td>
+			<td>
+				{{if .Available}}<span class="available">Available</span>
+				{{else if .CooldownRemaining}}<span class="unavailable">On cooldown, {{remaining .CooldownRemaining}} left</span>
+				{{else}}<span class="unavailable">Played out this stream</span>{{end}}
+				{{if ne .Permission "everyone"}}<div class="unavailable">{{.Permission}} and above</div>{{end}}
+			</td>
+		</tr>
+		{{end}}
+	</table>
+	{{else}}
+	<p>No sounds{{if .Search}} match "{{.Search}}"{{end}}.</p>
+	{{end}}
+</body>
+</html>
+`))
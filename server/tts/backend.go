@@ -0,0 +1,72 @@
+package tts
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"os/exec"
+	"strings"
+
+	"github.com/imoliwer/sound-point-twitch-bot/server/app"
+)
+
+// Backend reads text aloud, returning the speech as wav.
+type Backend interface {
+	Synthesize(ctx context.Context, text string) ([]byte, error)
+}
+
+// BackendOf returns the backend of the engine configured, failing if its binary can't be found.
+func BackendOf(settings app.TTSSettings) (Backend, error) {
+	switch settings.EngineOf() {
+	case app.TTSEspeak:
+		path, err := binary_of(settings.BinaryPath, "espeak-ng", "espeak")
+		if err != nil {
+			return nil, err
+		}
+		return &Espeak{Path: path, Voice: settings.Voice}, nil
+	case app.TTSPiper:
+		path, err := binary_of(settings.BinaryPath, "piper")
+		if err != nil {
+			return nil, err
+		}
+		return &Piper{Path: path, Model: settings.Model}, nil
+	case app.TTSFake:
+		return &Fake{}, nil
+	}
+	return nil, fmt.Errorf("unknown engine '%s'", settings.Engine)
+}
+
+//////////////////////
+// HELPER FUNCTIONS //
+//////////////////////
+
+// the binary configured, or the first of said names found within the PATH
+func binary_of(configured string, names ...string) (string, error) {
+	if configured != "" {
+		names = []string{configured}
+	}
+
+	for _, name := range names {
+		if path, err := exec.LookPath(name); err == nil {
+			return path, nil
+		}
+	}
+	return "", fmt.Errorf("%s can't be found", strings.Join(names, " or "))
+}
+
+// run said binary with said text as its input, returning its output
+func run(ctx context.Context, path string, text string, arguments ...string) ([]byte, error) {
+	var output, errorOutput bytes.Buffer
+	command := exec.CommandContext(ctx, path, arguments...)
+	command.Stdin = strings.NewReader(text)
+	command.Stdout = &output
+	command.Stderr = &errorOutput
+
+	if err := command.Run(); err != nil {
+		if message := strings.TrimSpace(errorOutput.String()); message != "" {
+			return nil, fmt.Errorf("%w: %s", err, message)
+		}
+		return nil, err
+	}
+	return output.Bytes(), nil
+}
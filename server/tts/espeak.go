@@ -0,0 +1,28 @@
+package tts
+
+import (
+	"context"
+	"errors"
+)
+
+// Espeak reads text aloud through espeak (or espeak-ng), offline and quickly, if robotic.
+type Espeak struct {
+	Path  string
+	Voice string // e.g. "en-us", the default of espeak when empty
+}
+
+func (r *Espeak) Synthesize(ctx context.Context, text string) ([]byte, error) {
+	arguments := []string{"--stdout", "--stdin"}
+	if r.Voice != "" {
+		arguments = append(arguments, "-v", r.Voice)
+	}
+
+	output, err := run(ctx, r.Path, text, arguments...)
+	if err != nil {
+		return nil, err
+	}
+	if len(output) == 0 {
+		return nil, errors.New("espeak produced no audio")
+	}
+	return output, nil
+}
@@ -0,0 +1,43 @@
+package tts_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/imoliwer/sound-point-twitch-bot/server/app"
+	"github.com/imoliwer/sound-point-twitch-bot/server/sound"
+	"github.com/imoliwer/sound-point-twitch-bot/server/tts"
+)
+
+func TestFakeBackendOfSettings(t *testing.T) {
+	backend, err := tts.BackendOf(app.TTSSettings{Engine: app.TTSFake})
+	if err != nil {
+		t.Fatalf("no backend: %s", err)
+	}
+	if _, ok := backend.(*tts.Fake); !ok {
+		t.Errorf("got %T, expected the fake backend", backend)
+	}
+}
+
+func TestFakeSynthesizesPlayableSpeech(t *testing.T) {
+	speech, err := (&tts.Fake{}).Synthesize(context.Background(), "hello chat")
+	if err != nil {
+		t.Fatalf("synthesizing failed: %s", err)
+	}
+
+	info, err := sound.ProbeBytes(speech)
+	if err != nil {
+		t.Fatalf("speech isn't valid audio: %s", err)
+	}
+	if info.Format != "wav" || info.Channels != 1 || info.SampleRate != 16000 {
+		t.Errorf("got %+v, expected mono wav at 16 kHz", info)
+	}
+	if expected := 500 * time.Millisecond; info.Duration != expected {
+		t.Errorf("lasts %s, expected %s for 10 characters", info.Duration, expected)
+	}
+
+	if _, err := sound.Loudness(speech); err != nil {
+		t.Errorf("loudness of the speech isn't measurable: %s", err)
+	}
+}
@@ -0,0 +1,87 @@
+package tts
+
+import (
+	"errors"
+	"regexp"
+	"strings"
+	"unicode"
+)
+
+var (
+	ErrLink      = errors.New("message contains a link")
+	ErrProfanity = errors.New("message contains a blocked word")
+)
+
+// blocked unless allowed explicitly, in the locales the bot speaks
+var default_blocked_words = []string{
+	"fuck", "fucking", "motherfucker", "shit", "bitch", "cunt", "asshole", "dick", "pussy", "whore",
+	"scheiße", "scheisse", "arschloch", "fotze", "hurensohn", "wichser", "schlampe", "hure",
+}
+
+var link_regex = regexp.MustCompile(`(?i)(\b[a-z][a-z0-9+.-]*://|\bwww\.|\b[a-z0-9-]+\.(com|net|org|tv|gg|io|de|co|me|ly|be|xyz|info|ru|uk|link|app)\b)`)
+
+// as letters are commonly disguised
+var leet_replacer = strings.NewReplacer("0", "o", "1", "i", "3", "e", "4", "a", "5", "s", "7", "t", "@", "a", "$", "s")
+
+// Filter returns said text with its whitespace collapsed, failing with ErrLink if it contains a link
+// (unless allowed), or with ErrProfanity if it contains a word blocked by default or by said words (or phrases, of words in a row).
+func Filter(text string, allowLinks bool, blockedWords []string) (string, error) {
+	text = strings.Join(strings.Fields(text), " ")
+
+	if !allowLinks && link_regex.MatchString(text) {
+		return "", ErrLink
+	}
+
+	words := words_of(text)
+	for _, phrase := range append(default_blocked_words, blockedWords...) {
+		if blocked := words_of(phrase); len(blocked) > 0 && contains_phrase(words, blocked) {
+			return "", ErrProfanity
+		}
+	}
+	return text, nil
+}
+
+//////////////////////
+// HELPER FUNCTIONS //
+//////////////////////
+
+// the words of said text, normalized and undisguised
+func words_of(text string) []string {
+	words := strings.FieldsFunc(leet_replacer.Replace(strings.ToLower(text)), func(char rune) bool {
+		return !unicode.IsLetter(char)
+	})
+	for index, word := range words {
+		words[index] = normalized(word)
+	}
+	return words
+}
+
+// whether said words contain those of said phrase in a row
+func contains_phrase(words []string, phrase []string) bool {
+	for start := 0; start+len(phrase) <= len(words); start++ {
+		matches := true
+		for offset, word := range phrase {
+			if words[start+offset] != word {
+				matches = false
+				break
+			}
+		}
+		if matches {
+			return true
+		}
+	}
+	return false
+}
+
+// lower case and without letters repeated, e.g. "Fuuuck" as "fuck"
+func normalized(word string) string {
+	var builder strings.Builder
+	var previous rune
+	for _, char := range strings.ToLower(word) {
+		if char != previous {
+			builder.WriteRune(char)
+		}
+		previous = char
+	}
+	return builder.String()
+}
@@ -0,0 +1,24 @@
+package tts_test
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/imoliwer/sound-point-twitch-bot/server/tts"
+)
+
+func TestBlockedPhrases(t *testing.T) {
+	blocked := []string{"bad words", "n00b"}
+
+	for text, expected := range map[string]error{
+		"these are BAAAD, words":  tts.ErrProfanity,
+		"what a noob":             tts.ErrProfanity,
+		"bad things, kind words":  nil,
+		"words bad in that order": nil,
+		"sh1t happens":            tts.ErrProfanity,
+	} {
+		if _, err := tts.Filter(text, false, blocked); !errors.Is(err, expected) {
+			t.Errorf("%q filtered with %v, expected %v", text, err, expected)
+		}
+	}
+}
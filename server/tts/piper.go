@@ -0,0 +1,28 @@
+package tts
+
+import (
+	"context"
+	"io/ioutil"
+	"os"
+)
+
+// Piper reads text aloud through piper, offline and rather natural, with the voice of said model.
+type Piper struct {
+	Path  string
+	Model string // the path of the .onnx voice
+}
+
+func (r *Piper) Synthesize(ctx context.Context, text string) ([]byte, error) {
+	// piper writes wav to files only
+	output, err := ioutil.TempFile("", "tts-*.wav")
+	if err != nil {
+		return nil, err
+	}
+	output.Close()
+	defer os.Remove(output.Name())
+
+	if _, err := run(ctx, r.Path, text, "--model", r.Model, "--output_file", output.Name()); err != nil {
+		return nil, err
+	}
+	return ioutil.ReadFile(output.Name())
+}
@@ -125,6 +125,8 @@ func badges_handler(value string) interface{} {
 			singleVersions.Append(BadgeBroadcaster)
 		case "bits-charity":
 			singleVersions.Append(BadgeBitsCharity)
+		case "vip":
+			singleVersions.Append(BadgeVIP)
 		case "subscriber":
 			badgeList.Subscriber = util.Uint32(badgeValue)
 		case "bits":
@@ -5,9 +5,11 @@ import (
 	"fmt"
 	"regexp"
 	"strings"
+	"sync"
 
 	"github.com/google/uuid"
 	"github.com/imoliwer/sound-point-twitch-bot/server/app"
+	"github.com/imoliwer/sound-point-twitch-bot/server/request"
 	"github.com/imoliwer/sound-point-twitch-bot/server/util"
 	"golang.org/x/net/websocket"
 )
@@ -22,17 +24,31 @@ var client_join_regex *regexp.Regexp
 var client_part_regex *regexp.Regexp
 var privmsg_regex = *regexp.MustCompile(`(?m)^(.+):(.+)!(.+)@(.+)\.tmi\.twitch\.tv PRIVMSG #(.+) :(.+)$`)
 var noticemsg_regex = *regexp.MustCompile(`(?m)^(.+):tmi\.twitch\.tv USERNOTICE #(.+)(?:(:(.*)))$`)
+var auth_failed_regex = *regexp.MustCompile(`(?m)^:tmi\.twitch\.tv NOTICE \* :(Login authentication failed|Improperly formatted auth)`)
+
+// ErrNotConnected tells a message couldn't be sent, as the client isn't connected at the moment.
+var ErrNotConnected = errors.New("not connected to the irc server")
 
 type Client struct {
 	App        *app.Application
+	mutex      sync.Mutex // guards the connection, channels and handlers
 	connection *websocket.Conn
-	channels   map[string]bool
+	channels   map[string]bool // joined, or to be joined once connected
+	stopped    bool
 	onMessage  func(client *Client, state *MessageState)
 	onNotice   func(client *Client, state *MessageState)
+	onAuthFail func(client *Client)
 }
 
-func NewClient(app *app.Application) Client {
-	return Client{
+func NewClient(app *app.Application) *Client {
+	// enforce lowercase on nick, which can't change without restarting
+	nick := strings.ToLower(app.Settings().TwitchBot.Name)
+
+	// assign the regex(es) dependant on the nick
+	client_join_regex = regexp.MustCompile(fmt.Sprintf(`(?m)^:%[1]s!%[1]s@%[1]s\.tmi\.twitch\.tv JOIN #(.+)$`, nick))
+	client_part_regex = regexp.MustCompile(fmt.Sprintf(`(?m)^:%[1]s!%[1]s@%[1]s\.tmi\.twitch\.tv PART #(.+)$`, nick))
+
+	return &Client{
 		App:        app,
 		connection: nil,
 		channels:   map[string]bool{},
@@ -40,11 +56,38 @@ func NewClient(app *app.Application) Client {
 }
 
 func (r *Client) Listen() {
-	botSettings := r.App.Settings.TwitchBot
-	connection, err := websocket.Dial("ws://irc-ws.chat.twitch.tv:80", "", "http://twitch.tv:80/")
+	if err := r.connect(); err != nil {
+		panic(err)
+	}
+}
 
+// Reconnect replaces the current connection with a new one, authenticating with the current bot token
+// and joining every channel joined beforehand. Nothing is done once stopped.
+func (r *Client) Reconnect() error {
+	r.mutex.Lock()
+	if r.stopped {
+		r.mutex.Unlock()
+		return nil
+	}
+	r.close()
+	r.mutex.Unlock()
+
+	return r.connect()
+}
+
+func (r *Client) connect() error {
+	connection, err := websocket.Dial("ws://irc-ws.chat.twitch.tv:80", "", "http://twitch.tv:80/")
 	if err != nil {
-		panic(err)
+		return err
+	}
+
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	// connected while another connection took its place, or stopped meanwhile
+	if r.stopped || r.connection != nil {
+		connection.Close()
+		return nil
 	}
 
 	// assign the connection and notify
@@ -52,27 +95,24 @@ func (r *Client) Listen() {
 	r.connection = connection
 
 	// handle receiving data
-	r.StartReading(r.App)
+	r.start_reading(connection)
 
-	// enforce lowercase on nick
-	nick := strings.ToLower(botSettings.Name)
-
-	// assign the regex(es) dependant on the nick
-	client_join_regex = regexp.MustCompile(fmt.Sprintf(`(?m)^:%[1]s!%[1]s@%[1]s\.tmi\.twitch\.tv JOIN #(.+)$`, nick))
-	client_part_regex = regexp.MustCompile(fmt.Sprintf(`(?m)^:%[1]s!%[1]s@%[1]s\.tmi\.twitch\.tv PART #(.+)$`, nick))
-
-	// forward the nick and pass
-	util.SendMultipleString(
-		connection,
-		[]util.FormatableString{
-			util.NewFormatableString("PASS %s", botSettings.AuthToken),
-			util.NewFormatableString("NICK %s", nick),
-			util.NewFormatableString("CAP REQ :%s %s %s", CAP_COMMANDS, CAP_TAGS, CAP_MEMBERSHIP),
-		},
-	)
+	// forward the nick and pass, joining the channels joined beforehand
+	messages := []util.FormatableString{
+		util.NewFormatableString("PASS oauth:%s", request.Profiles.Bot.Profile().OAuthToken),
+		util.NewFormatableString("NICK %s", strings.ToLower(r.App.Settings().TwitchBot.Name)),
+		util.NewFormatableString("CAP REQ :%s %s %s", CAP_COMMANDS, CAP_TAGS, CAP_MEMBERSHIP),
+	}
+	for channel := range r.channels {
+		messages = append(messages, util.NewFormatableString("JOIN #%s", channel))
+	}
+	return util.SendMultipleString(connection, messages)
 }
 
 func (r *Client) WithHandler(id string, handler func(client *Client, state *MessageState)) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
 	switch id {
 	case "message":
 		r.onMessage = handler
@@ -81,78 +121,116 @@ func (r *Client) WithHandler(id string, handler func(client *Client, state *Mess
 	}
 }
 
-func (r *Client) Chat(channel string, message string, args ...any) {
-	util.SendString(
-		r.connection,
-		"PRIVMSG #%s :%s",
-		channel,
-		fmt.Sprintf(message, args...),
-	)
+// OnAuthenticationFailed sets the handler called whenever the irc server rejects the bot token,
+// after which the server closes the connection.
+func (r *Client) OnAuthenticationFailed(handler func(client *Client)) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	r.onAuthFail = handler
+}
+
+// Chat sends said message to said channel, failing with ErrNotConnected while disconnected.
+func (r *Client) Chat(channel string, message string, args ...any) error {
+	return r.send("PRIVMSG #%s :%s", channel, fmt.Sprintf(message, args...))
 }
 
-func (r *Client) ReplyTo(parentMsgId uuid.UUID, channel string, message string, args ...any) {
-	util.SendString(
-		r.connection,
-		"@reply-parent-msg-id=%s PRIVMSG #%s :%s",
-		parentMsgId.String(),
-		channel,
-		fmt.Sprintf(message, args...),
-	)
+// ReplyTo replies to said message within said channel, failing with ErrNotConnected while disconnected.
+func (r *Client) ReplyTo(parentMsgId uuid.UUID, channel string, message string, args ...any) error {
+	return r.send("@reply-parent-msg-id=%s PRIVMSG #%s :%s", parentMsgId.String(), channel, fmt.Sprintf(message, args...))
 }
 
+// Join joins said channel, or remembers to join it once connected if disconnected at the moment.
 func (r *Client) Join(channel string) (bool, error) {
 	return r.join_or_part(channel, true)
 }
 
+// Part parts from said channel, or forgets to join it once connected if disconnected at the moment.
 func (r *Client) Part(channel string) (bool, error) {
 	return r.join_or_part(channel, false)
 }
 
 func (r *Client) join_or_part(channel string, join bool) (bool, error) {
-	conn := r.connection
-	if conn == nil {
-		return false, errors.New("not connected to to the irc server")
-	}
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
 
 	lowercased := strings.ToLower(channel)
-	hasJoined := r.HasJoined(lowercased)
+	hasJoined := r.channels[lowercased]
 
 	if join {
 		if hasJoined {
 			return false, errors.New(fmt.Sprintf("already joined the channel %s", channel))
 		}
-		util.SendString(conn, "JOIN #%s", lowercased)
 		r.channels[lowercased] = true
 	} else {
 		if !hasJoined {
 			return false, errors.New(fmt.Sprintf("not a part of the channel %s", channel))
 		}
-		util.SendString(conn, "PART #%s", lowercased)
 		delete(r.channels, lowercased)
 	}
-	return true, nil
+
+	// sent on connecting otherwise
+	if r.connection == nil {
+		return true, nil
+	}
+
+	command := "PART"
+	if join {
+		command = "JOIN"
+	}
+	return true, util.SendString(r.connection, "%s #%s", command, lowercased)
 }
 
 func (r *Client) HasJoined(channel string) bool {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
 	return r.channels[strings.ToLower(channel)]
 }
 
+// Stop closes the connection for good, reconnecting being left alone afterwards.
 func (r *Client) Stop() {
-	conn := r.connection
-	if conn == nil {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	r.stopped = true
+	r.close()
+}
+
+//////////////////////
+// HELPER FUNCTIONS //
+//////////////////////
+
+func (r *Client) send(data string, args ...any) error {
+	r.mutex.Lock()
+	connection := r.connection
+	r.mutex.Unlock()
+
+	if connection == nil {
+		return ErrNotConnected
+	}
+	return util.SendString(connection, data, args...)
+}
+
+// must be called while holding the mutex
+func (r *Client) close() {
+	if r.connection == nil {
 		return
 	}
-	conn.Close()
+	r.connection.Close()
 	r.connection = nil
 }
 
-func (r *Client) StartReading(app *app.Application) {
-	go func(connection *websocket.Conn) {
+// must be called while holding the mutex
+func (r *Client) start_reading(connection *websocket.Conn) {
+	go func() {
 		for {
 			var data string
 
 			if err := websocket.Message.Receive(connection, &data); err != nil {
-				r.Stop()
+				r.mutex.Lock()
+				if r.connection == connection { // leave a connection replaced by reconnecting be
+					r.close()
+				}
+				r.mutex.Unlock()
 				break
 			}
 
@@ -161,16 +239,24 @@ func (r *Client) StartReading(app *app.Application) {
 				continue
 			}
 
-			r.handle_message(app, connection, data)
+			r.handle_message(data)
 		}
-	}(r.connection)
+	}()
 }
 
-func (r *Client) handle_message(
-	app *app.Application,
-	connection *websocket.Conn,
-	data string,
-) {
+func (r *Client) handle_message(data string) {
+	r.mutex.Lock()
+	onMessage, onNotice, onAuthFail := r.onMessage, r.onNotice, r.onAuthFail
+	r.mutex.Unlock()
+
+	if auth_failed_regex.MatchString(data) {
+		util.Log("Twitch IRC", "Login authentication failed.")
+		if onAuthFail != nil {
+			go onAuthFail(r) // the handler may reconnect, which must not be done from the reading routine
+		}
+		return
+	}
+
 	if matches := client_join_regex.FindStringSubmatch(data); len(matches) > 0 {
 		util.Log("Channel", "Joined %s", matches[1])
 		return
@@ -183,16 +269,33 @@ func (r *Client) handle_message(
 
 	if matches := privmsg_regex.FindStringSubmatch(data); len(matches) > 0 {
 		state := ProcessMessageState(matches, "PRIVMSG")
-		r.call_if_present(r.onMessage, &state)
+		remember_users(&state)
+		r.call_if_present(onMessage, &state)
 		return
 	}
 
 	if matches := noticemsg_regex.FindStringSubmatch(data); len(matches) > 0 {
 		state := ProcessMessageState(matches, "USERNOTICE")
-		r.call_if_present(r.onNotice, &state)
+		remember_users(&state)
+		r.call_if_present(onNotice, &state)
 	}
 }
 
+// warm the user cache with the users the tags tell us about, sparing lookups later on
+func remember_users(state *MessageState) {
+	request.Users.Remember(request.CachedUser{
+		ID:          state.User.Id,
+		Login:       state.User.Login,
+		DisplayName: state.User.DisplayName,
+	})
+
+	request.Users.Remember(request.CachedUser{
+		ID:          state.Reply.UserId,
+		Login:       state.Reply.UserLogin,
+		DisplayName: state.Reply.UserDisplayName,
+	})
+}
+
 func (r *Client) call_if_present(handler func(client *Client, state *MessageState), state *MessageState) {
 	if handler != nil {
 		handler(r, state)
@@ -0,0 +1,35 @@
+package twitch_irc
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/google/uuid"
+	"github.com/imoliwer/sound-point-twitch-bot/server/app"
+)
+
+func TestSendingWhileDisconnected(t *testing.T) {
+	client := NewClient(app.NewApplication(&app.Settings{
+		TwitchBot: app.TwitchBotSettings{Name: "SoundBot", Channel: "streamer"},
+	}, nil, nil))
+
+	if err := client.ReplyTo(uuid.New(), "streamer", "hello"); !errors.Is(err, ErrNotConnected) {
+		t.Errorf("replying responded %v, expected it not connected", err)
+	}
+	if err := client.Chat("streamer", "hello"); !errors.Is(err, ErrNotConnected) {
+		t.Errorf("chatting responded %v, expected it not connected", err)
+	}
+
+	// joined once connected
+	if joined, err := client.Join("Streamer"); !joined || err != nil {
+		t.Fatalf("joining responded %t (%v)", joined, err)
+	}
+	if !client.HasJoined("streamer") {
+		t.Error("channel wasn't remembered")
+	}
+
+	client.Stop()
+	if err := client.Reconnect(); err != nil || client.connection != nil {
+		t.Errorf("reconnected once stopped (%v)", err)
+	}
+}
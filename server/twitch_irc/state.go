@@ -49,6 +49,7 @@ const (
 	BadgeGlhfPledge
 	BadgeBroadcaster
 	BadgeBitsCharity
+	BadgeVIP
 )
 
 var objectify_handlers = map[string]objectify_handler{
@@ -88,6 +89,7 @@ type UserState struct {
 	IsSubscriber bool             `json:"subscriber"`
 	IsTurbo      bool             `json:"turbo"`
 	Type         UserType         `json:"user-type"`
+	Login        string           `json:"login"`
 }
 
 type ReplyState struct {
@@ -172,6 +174,20 @@ func (r *BadgeList) Is(flag util.Flag) bool {
 	return r.single_versions.Has(flag)
 }
 
+// SubscriptionTier returns the tier told by the subscriber badge, zero if not subscribed. Tiers 2 and 3 are only told
+// apart if the channel has badges of their own for them (versions from 2000 and 3000), Prime counting as tier 1.
+func (r *UserState) SubscriptionTier() SubscriptionTier {
+	switch {
+	case !r.IsSubscriber:
+		return 0
+	case r.Badges.Subscriber >= 3000:
+		return Tier3
+	case r.Badges.Subscriber >= 2000:
+		return Tier2
+	}
+	return Tier1
+}
+
 func (r *MessageState) IsNotice() bool {
 	return r.Notice.Type > 0
 }
@@ -182,9 +198,17 @@ func ProcessMessageState(data []string, t string) MessageState {
 		Text:        state_text(data, t),
 	}
 	objectify_irc(data[1], &messageState, objectify_handlers)
+	messageState.User.Login = state_login(data, t, &messageState)
 	return messageState
 }
 
+func state_login(data []string, t string, state *MessageState) string {
+	if t == "PRIVMSG" {
+		return strings.ToLower(data[2])
+	}
+	return strings.ToLower(state.Notice.Login) // USERNOTICE
+}
+
 func state_channel_name(data []string, t string) string {
 	if t == "PRIVMSG" {
 		return data[5]
@@ -1,6 +1,9 @@
 package util
 
-import "strconv"
+import (
+	"strconv"
+	"time"
+)
 
 // bool relation
 func RequireBool(value string) bool {
@@ -36,3 +39,11 @@ func Uint16(value string) uint16 {
 	ui64, _ := strconv.ParseUint(value, 10, 16)
 	return uint16(ui64)
 }
+
+// duration relation, rounded up so what's remaining is never told to be nothing
+func WholeSeconds(duration time.Duration) uint64 {
+	if duration <= 0 {
+		return 0
+	}
+	return uint64((duration + time.Second - 1) / time.Second)
+}
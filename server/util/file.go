@@ -0,0 +1,51 @@
+package util
+
+import (
+	"os"
+	"path/filepath"
+)
+
+// WriteAtomically writes said content to path by means of a synced temporary file renamed into place,
+// meaning the file is either fully replaced or left untouched.
+func WriteAtomically(path string, content []byte, permission os.FileMode) error {
+	directory := filepath.Dir(path)
+	temp, err := os.CreateTemp(directory, filepath.Base(path)+".tmp-*")
+	if err != nil {
+		return err
+	}
+
+	tempName := temp.Name()
+	defer os.Remove(tempName) // no-op once renamed
+
+	if _, err := temp.Write(content); err != nil {
+		temp.Close()
+		return err
+	}
+	if err := temp.Chmod(permission); err != nil {
+		temp.Close()
+		return err
+	}
+	if err := temp.Sync(); err != nil {
+		temp.Close()
+		return err
+	}
+	if err := temp.Close(); err != nil {
+		return err
+	}
+
+	if err := os.Rename(tempName, path); err != nil {
+		return err
+	}
+	sync_directory(directory)
+	return nil
+}
+
+// ensure the rename itself survives a power loss, not supported on every platform
+func sync_directory(directory string) {
+	handle, err := os.Open(directory)
+	if err != nil {
+		return
+	}
+	handle.Sync()
+	handle.Close()
+}
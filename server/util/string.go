@@ -16,19 +16,20 @@ func NewFormatableString(data string, args ...any) FormatableString {
 	return FormatableString{Data: data, Arguments: args}
 }
 
-func SendMultipleString(connection *websocket.Conn, formatables []FormatableString) {
+func SendMultipleString(connection *websocket.Conn, formatables []FormatableString) error {
 	for _, formatable := range formatables {
-		arguments := formatable.Arguments
-		if arguments == nil {
-			SendString(connection, formatable.Data)
-			continue
+		if err := SendString(connection, formatable.Data, formatable.Arguments...); err != nil {
+			return err
 		}
-		SendString(connection, formatable.Data, arguments...)
 	}
+	return nil
 }
 
-func SendString(connection *websocket.Conn, data string, args ...any) {
-	websocket.Message.Send(connection, fmt.Sprintf(data, args...))
+func SendString(connection *websocket.Conn, data string, args ...any) error {
+	if len(args) == 0 {
+		return websocket.Message.Send(connection, data)
+	}
+	return websocket.Message.Send(connection, fmt.Sprintf(data, args...))
 }
 
 func Log(prefix string, message string, args ...any) {
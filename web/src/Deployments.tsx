@@ -1,7 +1,7 @@
 import Axios, { AxiosResponse } from 'axios';
 import { useEffect, useState } from 'react';
 import { w3cwebsocket as WebSocket } from 'websocket';
-import { Deployed } from './util/shared';
+import { ApiURL, Deployed, DeploymentURL } from './util/shared';
 import { TitleDeploy } from './util/TitleDeploy';
 
 type UserState = {
@@ -11,8 +11,20 @@ type UserState = {
 type Deployment = Deployed & {
   userstate?: UserState;
   tester?: string;
+  text?: string; // of a message read aloud
+  audio?: string; // of a message read aloud, as a data url
 };
 
+// chatters choose what's read aloud, so it must not be taken as markup
+function escapeHtml(content: string): string {
+  return content
+    .replaceAll("&", "&amp;")
+    .replaceAll("<", "&lt;")
+    .replaceAll(">", "&gt;")
+    .replaceAll("\"", "&quot;")
+    .replaceAll("'", "&#39;");
+}
+
 function processInnerAlert(content: string, next: Deployment): string {
   const [real, test] = content.split("<< OR ELSE IF TEST >>");
   let it;
@@ -28,7 +40,23 @@ function processInnerAlert(content: string, next: Deployment): string {
 
   return it
     .replaceAll("{{id}}", next.id)
-    .replaceAll("{{price}}", next.price.toString());
+    .replaceAll("{{price}}", next.price.toString())
+    .replaceAll("{{text}}", escapeHtml(next.text ?? ""));
+}
+
+// the element's own volume can't exceed 100%, so anything louder is amplified through a gain node
+function applyVolume(audio: HTMLAudioElement, volume: number | undefined) {
+  const percent = volume ?? 100;
+  if (percent <= 100) {
+    audio.volume = percent / 100;
+    return;
+  }
+
+  const context = new AudioContext();
+  const gain = context.createGain();
+  gain.gain.value = percent / 100;
+  context.createMediaElementSource(audio).connect(gain).connect(context.destination);
+  audio.addEventListener("ended", () => context.close());
 }
 
 export default function Deployments() {
@@ -61,9 +89,10 @@ export default function Deployments() {
     child.innerHTML = processInnerAlert(alertContent, next);
 
     window["deploymentStart"](alertContainer, child).then(() => {
-      const audio = new Audio(`sounds/${next.file_name}`);
+      const audio = new Audio(next.audio ?? `${ApiURL}/sound/files/${encodeURIComponent(next.file_name)}`);
       audio.load();
       audio.loop = false;
+      applyVolume(audio, next.volume);
       audio.onended = () => { 
         audio.remove();
         window["deploymentEnd"](alertContainer, child).then(() => {
@@ -97,13 +126,13 @@ export default function Deployments() {
       document.body.appendChild(alertScript);
     }
 
-    const socket = new WebSocket("ws://127.0.0.1:9999/sound/deployment");
+    const socket = new WebSocket(DeploymentURL);
     socket.onopen = () => setConnected(true);
     socket.onclose = () => setConnected(false);
     socket.onmessage = message => {
       const obj = JSON.parse(message.data.toString());
       
-      if (obj === undefined || !obj.file_name) {
+      if (obj === undefined || (!obj.file_name && !obj.audio)) {
         return;
       }
 
@@ -15,7 +15,7 @@ import {
   Title,
 } from "../style/dashboard";
 import { TitleDeploy } from "../util/TitleDeploy";
-import { SoundMap, formatNumber, pageOf, notEmptyOrElse, Deployed } from "../util/shared";
+import { ApiURL, SoundMap, formatNumber, pageOf, notEmptyOrElse, Deployed, TokenStatus } from "../util/shared";
 import "react-toastify/dist/ReactToastify.css";
 import { ToastContainer, toast } from "react-toastify";
 import Axios, { AxiosResponse } from "axios";
@@ -50,9 +50,9 @@ const Upload = async (
   cooldown: number,
   name: string,
   formData: FormData
-): Promise<boolean> => {
+): Promise<Deployed | string> => {
   return new Promise((resolve) => {
-    Axios.post(`http://localhost:9999/sound`, formData, {
+    Axios.post(`${ApiURL}/sound`, formData, {
       headers: {
         "Content-Type": "multipart/form-data",
       },
@@ -62,11 +62,13 @@ const Upload = async (
         name,
       },
     })
-      .catch(() => resolve(false))
-      .then(() => resolve(true));
+      .then(it => resolve({ ...it.data, id: name.toLowerCase() }))
+      .catch(error => resolve(error?.response?.data?.toString() ?? "Failed to upload the new Audio... Perhaps it already exists?"));
   });
 };
 
+const UPLOAD_EXTENSIONS = ["mp3", "ogg", "wav", "flac"];
+
 const ToastError = (child: JSX.Element) => {
   toast(child, {
     style: {
@@ -134,7 +136,7 @@ export default function Dashboard() {
 
   useEffect(() => {
     Axios
-      .get("http://localhost:9999/sounds", {
+      .get(`${ApiURL}/sounds`, {
         timeout: 1_000,
       })
       .catch(() => setServerStarted(false))
@@ -152,6 +154,41 @@ export default function Dashboard() {
         });
         setServerStarted(true);
       });
+
+    Axios
+      .get<TokenStatus[]>(`${ApiURL}/auth/status`, {
+        timeout: 1_000,
+      })
+      .catch(() => undefined)
+      .then((it) => {
+        if (it === undefined || it.data === undefined) {
+          return;
+        }
+
+        for (const status of it.data) {
+          if (status.status === "valid" && status.needs_reauthorization) {
+            ToastError(
+              <p>
+                The {status.account} token lacks the scopes {status.missing_scopes?.join(", ")}.{" "}
+                <a href={status.login_url}>Authorize again</a>
+              </p>
+            );
+            continue;
+          }
+
+          if (status.status === "valid") {
+            continue;
+          }
+
+          ToastError(
+            <p>
+              The {status.account} token is {status.status}
+              {status.last_error ? ` (${status.last_error})` : ""}.{" "}
+              <a href={status.login_url}>Authorize again</a>
+            </p>
+          );
+        }
+      });
   }, []);
 
   const updateMaxSoundsPage = (it: SoundMap) => {
@@ -195,7 +232,7 @@ export default function Dashboard() {
     });
 
     Axios
-      .delete(`http://localhost:9999/sound/${id}`)
+      .delete(`${ApiURL}/sound/${id}`)
       .catch(() => ToastError(<p>Failed deleting sound. Try refreshing the page.</p>))
       .then(res => {
         if (res !== undefined) {
@@ -207,7 +244,7 @@ export default function Dashboard() {
 
   const testSound = (id: string) => {
     Axios
-      .post(`http://localhost:9999/sound/test/${id}`)
+      .post(`${ApiURL}/sound/test/${id}`)
       .catch(() => ToastError(<p>Failed during deployment of test sound. Try refreshing the page.</p>))
       .then(res => {
         if (res !== undefined) {
@@ -303,16 +340,16 @@ export default function Dashboard() {
               </div>
             </header>
             <div>
-              <input type="file" accept="Audio/mp3" id="choose-sound-to-upload" hidden onChange={element => {
+              <input type="file" accept="audio/mpeg,audio/ogg,audio/wav,audio/flac" id="choose-sound-to-upload" hidden onChange={element => {
                 const files = element.target.files;
                 if (!files || files.length == 0) {
                   return;
                 }
 
-                // ensure it's an "mp3" audio file
+                // the server checks the content itself, this merely catches a wrong pick early
                 const file = files[0];
-                if (!file.name.endsWith("mp3")) {
-                  ToastError(<p>The file type must be of <span style={{fontWeight: "bold"}}>MP3</span>.</p>);
+                if (!UPLOAD_EXTENSIONS.some(extension => file.name.toLowerCase().endsWith(`.${extension}`))) {
+                  ToastError(<p>The file type must be one of <span style={{fontWeight: "bold"}}>MP3, OGG, WAV or FLAC</span>.</p>);
                   return;
                 }
 
@@ -366,22 +403,16 @@ export default function Dashboard() {
                 const formData = new FormData();
                 formData.append("file", selectedFile);
 
-                const audio: Deployed = {
-                  price: parseInt(newAudioPrice),
-                  id: newAudioName,
-                  file_name: selectedFile.name,
-                  cooldown: TranslateUnit(newAudio.cooldownUnit, parseInt(newAudioCooldown)),
-                  last_used: 0
-                };
-
+                // the file is named by the server, so what it stored is taken as the sound
                 const result = await Upload(
-                  audio.price, 
-                  audio.cooldown, 
+                  parseInt(newAudioPrice), 
+                  TranslateUnit(newAudio.cooldownUnit, parseInt(newAudioCooldown)), 
                   newAudioName,
                   formData
                 );
 
-                if (result) {
+                if (typeof result !== "string") {
+                  const audio = result;
                   setSounds((old) => {
                     const sounds = {
                       ...old,
@@ -392,7 +423,7 @@ export default function Dashboard() {
                   });
                   ToastSuccess(<p>You have added the Audio <span style={BoldSuccessStyle}>{newAudioName}</span> to the roster with a price of <span style={BoldSuccessStyle}>{newAudioPrice}</span>.</p>)
                 } else {
-                  ToastError(<p>Failed to upload the new Audio... Perhaps it already exists?</p>)
+                  ToastError(<p>{result}</p>)
                 }
               }}>{newAudio.file !== null ? `Add "${newAudio.file.name}" to the roster` : "None Selected"}</button>
             </div>
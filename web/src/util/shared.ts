@@ -1,11 +1,72 @@
 export const TitleBase = "Sound Point Twitch Bot |";
 
+// where the bot serves its api, configured through REACT_APP_API_URL (see .env) when built
+export const ApiURL = (process.env.REACT_APP_API_URL || "http://localhost:9999").replace(/\/+$/, "");
+export const DeploymentURL = `${ApiURL.replace(/^http/, "ws")}/sound/deployment`;
+
+export type HappyHour = {
+  days?: string[]; // e.g. "saturday", every day if absent
+  start: string; // "15:04"
+  end: string; // "15:04", before start to span midnight
+  timezone?: string;
+  discount_percent: number;
+};
+
+export type Pricing = {
+  surge?: { step_percent: number; max_percent?: number; half_life?: number }; // half life in milliseconds
+  subscriber_discounts?: { tier1?: number; tier2?: number; tier3?: number }; // percent off
+  happy_hours?: HappyHour[];
+  first_play_free?: boolean;
+};
+
 export type Deployed = {
   price: number;
+  effective_price?: number; // as currently surged or discounted, for those not subscribed
   id: string;
   file_name: string;
-  cooldown: number;
-  last_used: number;
+  cooldown: number; // milliseconds before anyone may play it again
+  user_cooldown?: number; // milliseconds before the same user may play it again
+  last_used: number; // unix milliseconds
+  cooldown_remaining_seconds?: number;
+  volume?: number; // percent, 0 to 200
+  duration_seconds?: number;
+  tags?: string[];
+  description?: string;
+  enabled?: boolean;
+  permission?: "everyone" | "subscriber" | "vip" | "moderator" | "broadcaster";
+  max_plays_per_stream?: number;
+  pricing?: Pricing;
+  format?: "mp3" | "ogg" | "wav" | "flac";
+  sample_rate?: number;
+  bitrate?: number;
+  loudness_lufs?: number;
+  original_file_name?: string;
+  processing?: "pending" | "processing" | "done" | "failed";
+  processing_error?: string;
+};
+
+export type SoundGroup = {
+  sounds: { sound: string; weight?: number }[];
+  selection?: "random" | "round_robin" | "shuffle";
+};
+
+export type SoundPack = {
+  sounds: string[]; // sounds or groups
+  price: number;
+  description?: string;
+  owners?: number;
+};
+
+export type TokenStatus = {
+  account: string;
+  status: "unknown" | "valid" | "retrying" | "invalid";
+  expires_at: string;
+  last_refresh: string;
+  last_error?: string;
+  attempts: number;
+  missing_scopes?: string[];
+  needs_reauthorization: boolean;
+  login_url: string;
 };
 
 export type SoundMap = {